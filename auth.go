@@ -0,0 +1,181 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	sessionTTL        = 30 * 24 * time.Hour
+	minPasswordLength = 8
+	userIDKey         = "userID"
+)
+
+type User struct {
+	ID        int    `json:"id"`
+	Email     string `json:"email"`
+	CreatedAt string `json:"createdAt,omitempty"`
+}
+
+type Credentials struct {
+	Email    string `json:"email" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type AuthResponse struct {
+	Token     string `json:"token"`
+	ExpiresAt string `json:"expiresAt"`
+	User      User   `json:"user"`
+}
+
+// Tables whose rows belong to a user. Rows created before accounts existed have no owner
+// and are claimed by the first user to register.
+var ownedTables = []string{"meals", "ingredients", "ingredient_templates", "meal_templates", "daily_targets"}
+
+// requireAuth rejects requests without a valid bearer token and stores the user's ID in the context
+func requireAuth(c *gin.Context) {
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	userID, err := store.GetSessionUserID(hashToken(token), time.Now())
+	if errors.Is(err, errNotFound) {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired session"})
+		return
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Set(userIDKey, userID)
+	c.Next()
+}
+
+// currentUserID returns the authenticated user's ID; only valid behind requireAuth
+func currentUserID(c *gin.Context) int {
+	return c.GetInt(userIDKey)
+}
+
+func register(c *gin.Context) {
+	var creds Credentials
+	if err := c.ShouldBindJSON(&creds); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	email := normalizeEmail(creds.Email)
+	if !strings.Contains(email, "@") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email address"})
+		return
+	}
+	if len(creds.Password) < minPasswordLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password must be at least 8 characters"})
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(creds.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := store.CreateUser(email, string(hash))
+	if errors.Is(err, errEmailTaken) {
+		c.JSON(http.StatusConflict, gin.H{"error": "An account with this email already exists"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := createSession(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+func login(c *gin.Context) {
+	var creds Credentials
+	if err := c.ShouldBindJSON(&creds); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, passwordHash, err := store.GetUserByEmail(normalizeEmail(creds.Email))
+	if err != nil && !errors.Is(err, errNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil || bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(creds.Password)) != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+		return
+	}
+
+	response, err := createSession(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func logout(c *gin.Context) {
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+
+	if err := store.DeleteSession(hashToken(token)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+func getCurrentUser(c *gin.Context) {
+	user, err := store.GetUser(currentUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// createSession issues a new random token for the user. Only its SHA-256 hash is stored.
+func createSession(user User) (AuthResponse, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return AuthResponse{}, err
+	}
+	token := hex.EncodeToString(raw)
+	expiresAt := time.Now().UTC().Add(sessionTTL)
+
+	if err := store.CreateSession(user.ID, hashToken(token), expiresAt); err != nil {
+		return AuthResponse{}, err
+	}
+
+	return AuthResponse{Token: token, ExpiresAt: expiresAt.Format(time.RFC3339), User: user}, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,521 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// backupVersion is the version of the backup document format. Restores accept any version up to it.
+const backupVersion = 3
+
+// Restore modes: merge adds the backup to the user's data, replace deletes the user's data first
+const (
+	restoreModeMerge   = "merge"
+	restoreModeReplace = "replace"
+)
+
+// Backup is all of a user's data, as returned by GET /api/backup. IDs are those of the database
+// the backup was taken from and only link rows within the document; restoring assigns new ones.
+type Backup struct {
+	Version             int                  `json:"version"`
+	CreatedAt           string               `json:"createdAt,omitempty"`
+	IngredientTemplates []IngredientTemplate `json:"ingredientTemplates"`
+	MealTemplates       []MealTemplate       `json:"mealTemplates"` // Ingredients refer to ingredientTemplates by ID, with a quantity
+	Recipes             []Recipe             `json:"recipes"`       // Added in version 2
+	Meals               []Meal               `json:"meals"`
+	DailyTargets        []DailyTargets       `json:"dailyTargets"`
+	TargetProfiles      []TargetProfile      `json:"targetProfiles"` // Versions added in version 3; older profiles restore as one version
+	TargetSchedule      TargetSchedule       `json:"targetSchedule"`
+	TargetOverrides     []TargetOverride     `json:"targetOverrides"`
+	Measurements        []BodyMeasurement    `json:"measurements"`
+}
+
+// RestoreResult counts the rows a restore created, and the rows it skipped because the user
+// already had an ingredient template, meal template, recipe or target profile with the same name,
+// a meal with the same name and datetime, daily targets effective from the same date, a measurement
+// or override on the same date, or a profile scheduled on the same weekday
+type RestoreResult struct {
+	Mode    string        `json:"mode"`
+	Created RestoreCounts `json:"created"`
+	Skipped RestoreCounts `json:"skipped"`
+}
+
+type RestoreCounts struct {
+	IngredientTemplates int `json:"ingredientTemplates"`
+	MealTemplates       int `json:"mealTemplates"`
+	Recipes             int `json:"recipes"`
+	Meals               int `json:"meals"`
+	DailyTargets        int `json:"dailyTargets"`
+	TargetProfiles      int `json:"targetProfiles"`
+	ScheduleDays        int `json:"scheduleDays"`
+	TargetOverrides     int `json:"targetOverrides"`
+	Measurements        int `json:"measurements"`
+}
+
+// Backup handlers
+
+func getBackup(c *gin.Context) {
+	backup, err := buildBackup(currentUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="macro-tracker-backup-%s.json"`, time.Now().Format(dateLayout)))
+	c.JSON(http.StatusOK, backup)
+}
+
+// restoreBackup loads a backup document into the user's account in one transaction, in
+// ?mode=merge (default) or ?mode=replace. In merge mode ingredient templates and target profiles
+// are matched by name, and rows in the backup that refer to them are linked to the existing ones.
+func restoreBackup(c *gin.Context) {
+	mode := c.DefaultQuery("mode", restoreModeMerge)
+	if mode != restoreModeMerge && mode != restoreModeReplace {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid mode %q, expected %s or %s", mode, restoreModeMerge, restoreModeReplace)})
+		return
+	}
+	var backup Backup
+	if err := c.ShouldBindJSON(&backup); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := backup.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := store.Restore(currentUserID(c), &backup, mode == restoreModeReplace)
+	if err != nil {
+		respondStoreError(c, err, "Backup data not found")
+		return
+	}
+
+	result.Mode = mode
+	c.JSON(http.StatusOK, result)
+}
+
+// buildBackup collects all of the user's data
+func buildBackup(userID int) (*Backup, error) {
+	backup := &Backup{Version: backupVersion, CreatedAt: time.Now().UTC().Format(time.RFC3339)}
+	var err error
+	if backup.IngredientTemplates, err = store.ListIngredientTemplates(userID); err != nil {
+		return nil, err
+	}
+	if backup.MealTemplates, err = store.ListMealTemplates(userID); err != nil {
+		return nil, err
+	}
+	if backup.Recipes, err = store.ListRecipes(userID); err != nil {
+		return nil, err
+	}
+	if backup.Meals, _, err = store.ListMeals(userID, mealQuery{SortBy: mealSortColumns["datetime"]}); err != nil {
+		return nil, err
+	}
+	if backup.DailyTargets, err = store.ListDailyTargets(userID); err != nil {
+		return nil, err
+	}
+	if backup.TargetProfiles, err = store.ListTargetProfiles(userID); err != nil {
+		return nil, err
+	}
+	if backup.TargetSchedule, err = store.GetTargetSchedule(userID); err != nil {
+		return nil, err
+	}
+	if backup.TargetOverrides, err = store.ListTargetOverrides(userID, "", ""); err != nil {
+		return nil, err
+	}
+	if backup.Measurements, err = store.ListMeasurements(userID, "", ""); err != nil {
+		return nil, err
+	}
+	return backup, nil
+}
+
+// validate checks a backup before it is restored: the version, the values the database would
+// reject and the IDs rows refer to each other by. Meal datetimes are normalised for storage.
+func (b *Backup) validate() error {
+	if b.Version < 1 || b.Version > backupVersion {
+		return fmt.Errorf("unsupported backup version %d, expected 1-%d", b.Version, backupVersion)
+	}
+
+	templateIDs := make(map[int]bool, len(b.IngredientTemplates))
+	templateNames := make(map[string]bool, len(b.IngredientTemplates))
+	for i, template := range b.IngredientTemplates {
+		switch {
+		case template.Name == "":
+			return fmt.Errorf("ingredientTemplates[%d]: name is required", i)
+		case templateNames[template.Name]:
+			return fmt.Errorf("ingredientTemplates[%d]: duplicate name %q", i, template.Name)
+		case templateIDs[template.ID]:
+			return fmt.Errorf("ingredientTemplates[%d]: duplicate id %d", i, template.ID)
+		}
+		if err := b.IngredientTemplates[i].validate(); err != nil {
+			return fmt.Errorf("ingredientTemplates[%d]: %v", i, err)
+		}
+		for j := range template.Portions {
+			if err := template.Portions[j].validate(); err != nil {
+				return fmt.Errorf("ingredientTemplates[%d]: portions[%d]: %v", i, j, err)
+			}
+		}
+		templateIDs[template.ID] = true
+		templateNames[template.Name] = true
+	}
+
+	for i, template := range b.MealTemplates {
+		if template.Name == "" {
+			return fmt.Errorf("mealTemplates[%d]: name is required", i)
+		}
+		for _, ingredient := range template.Ingredients {
+			if !templateIDs[ingredient.ID] {
+				return fmt.Errorf("mealTemplates[%d]: unknown ingredient template %d", i, ingredient.ID)
+			}
+		}
+	}
+
+	recipeNames := make(map[string]bool, len(b.Recipes))
+	for i := range b.Recipes {
+		recipe := &b.Recipes[i]
+		if err := recipe.validate(); err != nil {
+			return fmt.Errorf("recipes[%d]: %v", i, err)
+		}
+		if recipeNames[recipe.Name] {
+			return fmt.Errorf("recipes[%d]: duplicate name %q", i, recipe.Name)
+		}
+		recipeNames[recipe.Name] = true
+		for j := range recipe.Ingredients {
+			ingredient := &recipe.Ingredients[j]
+			if err := ingredient.validate(); err != nil {
+				return fmt.Errorf("recipes[%d]: ingredient %q: %v", i, ingredient.Name, err)
+			}
+			if ingredient.IngredientTemplateID != nil && !templateIDs[*ingredient.IngredientTemplateID] {
+				return fmt.Errorf("recipes[%d]: unknown ingredient template %d", i, *ingredient.IngredientTemplateID)
+			}
+		}
+	}
+
+	for i := range b.Meals {
+		meal := &b.Meals[i]
+		t, _, err := parseDateTimeParam(meal.DateTime)
+		if err != nil {
+			return fmt.Errorf("meals[%d]: invalid datetime: %v", i, err)
+		}
+		meal.DateTime = t.Format(timestampLayout)
+		for j := range meal.Ingredients {
+			ingredient := &meal.Ingredients[j]
+			if err := ingredient.validate(); err != nil {
+				return fmt.Errorf("meals[%d]: ingredient %q: %v", i, ingredient.Name, err)
+			}
+			if ingredient.IngredientTemplateID != nil && !templateIDs[*ingredient.IngredientTemplateID] {
+				return fmt.Errorf("meals[%d]: unknown ingredient template %d", i, *ingredient.IngredientTemplateID)
+			}
+		}
+	}
+
+	for i := range b.DailyTargets {
+		targets := &b.DailyTargets[i]
+		if _, err := time.Parse(dateLayout, targets.EffectiveFrom); err != nil {
+			return fmt.Errorf("dailyTargets[%d]: invalid effectiveFrom, expected YYYY-MM-DD", i)
+		}
+		if err := resolveTargetMode(targets); err != nil {
+			return fmt.Errorf("dailyTargets[%d]: %v", i, err)
+		}
+	}
+
+	profileIDs := make(map[int]bool, len(b.TargetProfiles))
+	profileNames := make(map[string]bool, len(b.TargetProfiles))
+	for i := range b.TargetProfiles {
+		profile := &b.TargetProfiles[i]
+		switch {
+		case profile.Name == "":
+			return fmt.Errorf("targetProfiles[%d]: name is required", i)
+		case profileNames[profile.Name]:
+			return fmt.Errorf("targetProfiles[%d]: duplicate name %q", i, profile.Name)
+		case profileIDs[profile.ID]:
+			return fmt.Errorf("targetProfiles[%d]: duplicate id %d", i, profile.ID)
+		}
+		profileIDs[profile.ID] = true
+		profileNames[profile.Name] = true
+		if len(profile.Versions) == 0 {
+			if err := resolveTargetMode(&profile.DailyTargets); err != nil {
+				return fmt.Errorf("targetProfiles[%d]: %v", i, err)
+			}
+		}
+		versionDates := make(map[string]bool, len(profile.Versions))
+		for j := range profile.Versions {
+			version := &profile.Versions[j]
+			if _, err := time.Parse(dateLayout, version.EffectiveFrom); err != nil {
+				return fmt.Errorf("targetProfiles[%d]: versions[%d]: invalid effectiveFrom, expected YYYY-MM-DD", i, j)
+			}
+			if versionDates[version.EffectiveFrom] {
+				return fmt.Errorf("targetProfiles[%d]: versions[%d]: duplicate effectiveFrom %s", i, j, version.EffectiveFrom)
+			}
+			versionDates[version.EffectiveFrom] = true
+			if err := resolveTargetMode(version); err != nil {
+				return fmt.Errorf("targetProfiles[%d]: versions[%d]: %v", i, j, err)
+			}
+		}
+	}
+	for name, profileID := range b.TargetSchedule {
+		if _, ok := parseWeekday(name); !ok {
+			return fmt.Errorf("targetSchedule: invalid weekday %q", name)
+		}
+		if !profileIDs[profileID] {
+			return fmt.Errorf("targetSchedule: unknown target profile %d", profileID)
+		}
+	}
+	overrideDates := make(map[string]bool, len(b.TargetOverrides))
+	for i, override := range b.TargetOverrides {
+		if _, err := time.Parse(dateLayout, override.Date); err != nil {
+			return fmt.Errorf("targetOverrides[%d]: invalid date, expected YYYY-MM-DD", i)
+		}
+		if overrideDates[override.Date] {
+			return fmt.Errorf("targetOverrides[%d]: duplicate date %s", i, override.Date)
+		}
+		if !profileIDs[override.ProfileID] {
+			return fmt.Errorf("targetOverrides[%d]: unknown target profile %d", i, override.ProfileID)
+		}
+		overrideDates[override.Date] = true
+	}
+
+	measurementDates := make(map[string]bool, len(b.Measurements))
+	for i, measurement := range b.Measurements {
+		if _, err := time.Parse(dateLayout, measurement.Date); err != nil {
+			return fmt.Errorf("measurements[%d]: invalid date, expected YYYY-MM-DD", i)
+		}
+		if measurementDates[measurement.Date] {
+			return fmt.Errorf("measurements[%d]: duplicate date %s", i, measurement.Date)
+		}
+		measurementDates[measurement.Date] = true
+	}
+	return nil
+}
+
+// Restore loads a validated backup into the user's account, remapping the IDs rows refer to each
+// other by. With replace, the user's existing data is deleted first; otherwise see RestoreResult
+// for which rows are skipped.
+func (s *sqlStore) Restore(userID int, backup *Backup, replace bool) (RestoreResult, error) {
+	var result RestoreResult
+	tx, err := s.db.Begin()
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+
+	if replace {
+		if err = deleteUserData(tx, userID); err != nil {
+			return result, err
+		}
+	}
+
+	templateIDs := make(map[int]int, len(backup.IngredientTemplates))
+	for _, template := range backup.IngredientTemplates {
+		backupID := template.ID
+		existingID, err := lookupID(tx, "SELECT id FROM ingredient_templates WHERE user_id = $1 AND name = $2", userID, template.Name)
+		if err != nil {
+			return result, err
+		}
+		if existingID != 0 {
+			templateIDs[backupID] = existingID
+			result.Skipped.IngredientTemplates++
+			continue
+		}
+		if err = insertIngredientTemplate(tx, userID, &template); err != nil {
+			return result, err
+		}
+		if err = insertIngredientPortions(tx, template.ID, template.Portions); err != nil {
+			return result, err
+		}
+		templateIDs[backupID] = template.ID
+		result.Created.IngredientTemplates++
+	}
+
+	for _, template := range backup.MealTemplates {
+		if !replace {
+			existingID, err := lookupID(tx, "SELECT id FROM meal_templates WHERE user_id = $1 AND name = $2", userID, template.Name)
+			if err != nil {
+				return result, err
+			}
+			if existingID != 0 {
+				result.Skipped.MealTemplates++
+				continue
+			}
+		}
+		ingredients := make([]IngredientTemplate, len(template.Ingredients))
+		for i, ingredient := range template.Ingredients {
+			ingredients[i] = IngredientTemplate{ID: templateIDs[ingredient.ID], Quantity: ingredient.Quantity}
+		}
+		template.Ingredients = ingredients
+		if _, err = insertMealTemplate(tx, userID, &template); err != nil {
+			return result, err
+		}
+		result.Created.MealTemplates++
+	}
+
+	for _, recipe := range backup.Recipes {
+		existingID, err := lookupID(tx, "SELECT id FROM recipes WHERE user_id = $1 AND name = $2", userID, recipe.Name)
+		if err != nil {
+			return result, err
+		}
+		if existingID != 0 {
+			result.Skipped.Recipes++
+			continue
+		}
+		recipe.Ingredients = remapIngredientTemplates(recipe.Ingredients, templateIDs)
+		if err = insertRecipe(tx, userID, &recipe); err != nil {
+			return result, err
+		}
+		result.Created.Recipes++
+	}
+
+	for _, meal := range backup.Meals {
+		if !replace {
+			existingID, err := lookupID(tx, "SELECT id FROM meals WHERE user_id = $1 AND name = $2 AND datetime = $3", userID, meal.Name, meal.DateTime)
+			if err != nil {
+				return result, err
+			}
+			if existingID != 0 {
+				result.Skipped.Meals++
+				continue
+			}
+		}
+		meal.Ingredients = remapIngredientTemplates(meal.Ingredients, templateIDs)
+		if _, err = insertMeal(tx, userID, &meal); err != nil {
+			return result, err
+		}
+		result.Created.Meals++
+	}
+
+	for _, targets := range backup.DailyTargets {
+		if !replace {
+			existingID, err := lookupID(tx, "SELECT id FROM daily_targets WHERE user_id = $1 AND effective_from = $2", userID, targets.EffectiveFrom)
+			if err != nil {
+				return result, err
+			}
+			if existingID != 0 {
+				result.Skipped.DailyTargets++
+				continue
+			}
+		}
+		if err = insertDailyTargets(tx, userID, &targets); err != nil {
+			return result, err
+		}
+		result.Created.DailyTargets++
+	}
+
+	profileIDs := make(map[int]int, len(backup.TargetProfiles))
+	for _, profile := range backup.TargetProfiles {
+		backupID := profile.ID
+		existingID, err := lookupID(tx, "SELECT id FROM target_profiles WHERE user_id = $1 AND name = $2", userID, profile.Name)
+		if err != nil {
+			return result, err
+		}
+		if existingID != 0 {
+			profileIDs[backupID] = existingID
+			result.Skipped.TargetProfiles++
+			continue
+		}
+		if err = insertTargetProfile(tx, userID, &profile); err != nil {
+			return result, err
+		}
+		profileIDs[backupID] = profile.ID
+		result.Created.TargetProfiles++
+	}
+
+	for name, profileID := range backup.TargetSchedule {
+		day, _ := parseWeekday(name)
+		inserted, err := tx.Exec(`
+			INSERT INTO target_schedule (user_id, weekday, profile_id) VALUES ($1, $2, $3)
+			ON CONFLICT (user_id, weekday) DO NOTHING
+		`, userID, int(day), profileIDs[profileID])
+		if err != nil {
+			return result, err
+		}
+		countInsert(inserted, &result.Created.ScheduleDays, &result.Skipped.ScheduleDays)
+	}
+
+	for _, override := range backup.TargetOverrides {
+		inserted, err := tx.Exec(`
+			INSERT INTO target_overrides (user_id, date, profile_id) VALUES ($1, $2, $3)
+			ON CONFLICT (user_id, date) DO NOTHING
+		`, userID, override.Date, profileIDs[override.ProfileID])
+		if err != nil {
+			return result, err
+		}
+		countInsert(inserted, &result.Created.TargetOverrides, &result.Skipped.TargetOverrides)
+	}
+
+	for _, measurement := range backup.Measurements {
+		existingID, err := lookupID(tx, "SELECT id FROM body_measurements WHERE user_id = $1 AND date = $2", userID, measurement.Date)
+		if err != nil {
+			return result, err
+		}
+		if existingID != 0 {
+			result.Skipped.Measurements++
+			continue
+		}
+		if err = insertMeasurement(tx, userID, &measurement); err != nil {
+			return result, err
+		}
+		result.Created.Measurements++
+	}
+
+	return result, tx.Commit()
+}
+
+// deleteUserData deletes everything a backup covers from the user's account
+func deleteUserData(tx *sql.Tx, userID int) error {
+	for _, statement := range []string{
+		// Ingredients belong to a single meal, so they go with it
+		"DELETE FROM ingredients WHERE id IN (SELECT mi.ingredient_id FROM meal_ingredients mi JOIN meals m ON m.id = mi.meal_id WHERE m.user_id = $1)",
+		"DELETE FROM meals WHERE user_id = $1",
+		"DELETE FROM ingredients WHERE id IN (SELECT ri.ingredient_id FROM recipe_ingredients ri JOIN recipes r ON r.id = ri.recipe_id WHERE r.user_id = $1)",
+		"DELETE FROM recipes WHERE user_id = $1",
+		// Whatever is left was logged on its own
+		"DELETE FROM ingredients WHERE user_id = $1",
+		"DELETE FROM meal_templates WHERE user_id = $1",
+		"DELETE FROM ingredient_templates WHERE user_id = $1",
+		"DELETE FROM daily_targets WHERE user_id = $1",
+		"DELETE FROM target_overrides WHERE user_id = $1",
+		"DELETE FROM target_schedule WHERE user_id = $1",
+		"DELETE FROM target_profiles WHERE user_id = $1",
+		"DELETE FROM body_measurements WHERE user_id = $1",
+	} {
+		if _, err := tx.Exec(statement, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Helper function to copy ingredients with their ingredient template links changed from backup IDs to restored ones
+func remapIngredientTemplates(ingredients []Ingredient, templateIDs map[int]int) []Ingredient {
+	remapped := make([]Ingredient, len(ingredients))
+	for i, ingredient := range ingredients {
+		if ingredient.IngredientTemplateID != nil {
+			templateID := templateIDs[*ingredient.IngredientTemplateID]
+			ingredient.IngredientTemplateID = &templateID
+		}
+		remapped[i] = ingredient
+	}
+	return remapped
+}
+
+// Helper function to look up the ID of a row, returning 0 if there is none
+func lookupID(tx *sql.Tx, query string, args ...interface{}) (int, error) {
+	var id int
+	err := tx.QueryRow(query, args...).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return id, err
+}
+
+// Helper function to count an INSERT ... ON CONFLICT DO NOTHING as created or skipped
+func countInsert(result sql.Result, created, skipped *int) {
+	if affected, _ := result.RowsAffected(); affected > 0 {
+		*created++
+	} else {
+		*skipped++
+	}
+}
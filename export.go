@@ -0,0 +1,158 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// mealsCSVHeader is the header row of GET /api/export/meals.csv, which POST /api/import/meals
+// also accepts as is
+var mealsCSVHeader = []string{
+	"meal_id", "meal_name", "datetime", "ingredient", "quantity", "quantity_unit", "macro_unit", "serving_size",
+	"carbs", "fat", "protein", "kcal", "fibre", "sugar", "saturated_fat", "sodium", "alcohol",
+	"total_carbs", "total_fat", "total_protein", "total_kcal",
+	"total_fibre", "total_sugar", "total_saturated_fat", "total_sodium", "total_alcohol",
+}
+
+// exportMealsCSV streams one CSV row per ingredient of the meals matching the same from, to, sort
+// and order parameters as GET /api/meals (oldest first by default). Rows are written as they are
+// read from the database, so the export is never held in memory.
+func exportMealsCSV(c *gin.Context) {
+	q, err := parseMealQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if c.Query("order") == "" {
+		q.Desc = false
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="meals.csv"`)
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	if err := w.Write(mealsCSVHeader); err != nil {
+		return
+	}
+	err = store.ForEachMealIngredient(currentUserID(c), q, func(meal Meal, ingredient *Ingredient) error {
+		return w.Write(mealCSVRecord(meal, ingredient))
+	})
+	w.Flush()
+	if err == nil {
+		err = w.Error()
+	}
+	if err != nil {
+		// The response has already started, so the client only sees a truncated file
+		log.Printf("Error exporting meals: %v", err)
+	}
+}
+
+// Helper function to build the CSV record of one ingredient of a meal. A meal without
+// ingredients gets a single record with only the meal columns filled in.
+func mealCSVRecord(meal Meal, ingredient *Ingredient) []string {
+	record := []string{strconv.Itoa(meal.ID), meal.Name, csvDateTime(meal.DateTime)}
+	if ingredient == nil {
+		return append(record, make([]string, len(mealsCSVHeader)-len(record))...)
+	}
+
+	servingSize := ""
+	if ingredient.ServingSize != nil {
+		servingSize = formatCSVFloat(*ingredient.ServingSize)
+	}
+	totals := ingredient.macros().rounded()
+	return append(record,
+		ingredient.Name, formatCSVFloat(ingredient.Quantity), ingredient.QuantityUnit, ingredient.MacroUnit, servingSize,
+		formatCSVFloat(ingredient.Carbs), formatCSVFloat(ingredient.Fat), formatCSVFloat(ingredient.Protein), formatCSVFloat(ingredient.Kcal),
+		formatCSVFloat(ingredient.Fibre), formatCSVFloat(ingredient.Sugar), formatCSVFloat(ingredient.SaturatedFat),
+		formatCSVFloat(ingredient.Sodium), formatCSVFloat(ingredient.Alcohol),
+		formatCSVFloat(totals.Carbs), formatCSVFloat(totals.Fat), formatCSVFloat(totals.Protein), formatCSVFloat(totals.Kcal),
+		formatCSVFloat(totals.Fibre), formatCSVFloat(totals.Sugar), formatCSVFloat(totals.SaturatedFat),
+		formatCSVFloat(totals.Sodium), formatCSVFloat(totals.Alcohol),
+	)
+}
+
+// Helper function to format a stored meal datetime (returned by the drivers as RFC 3339) as
+// YYYY-MM-DD HH:MM:SS
+func csvDateTime(value string) string {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.Format(timestampLayout)
+	}
+	return value
+}
+
+func formatCSVFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// ForEachMealIngredient calls fn for every ingredient of the user's meals matching the query, in
+// the query's order, reading straight from the cursor. Meals without ingredients are passed once
+// with a nil ingredient. fn must not use the store, as SQLite has a single connection.
+func (s *sqlStore) ForEachMealIngredient(userID int, q mealQuery, fn func(meal Meal, ingredient *Ingredient) error) error {
+	where, args := q.whereClause(userID)
+	limit := ""
+	if q.PageSize > 0 {
+		args = append(args, q.PageSize, (q.Page-1)*q.PageSize)
+		limit = fmt.Sprintf("LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+	}
+
+	rows, err := s.db.Query(fmt.Sprintf(`
+		SELECT m.id, m.name, m.datetime,
+		       i.id, i.name, i.quantity, i.quantity_unit, i.carbs, i.fat, i.protein, i.kcal,
+		       i.fibre, i.sugar, i.saturated_fat, i.sodium, i.alcohol, i.macro_unit, i.serving_size
+		FROM (SELECT m.id, m.name, m.datetime FROM meals m %s ORDER BY %s %s) m
+		LEFT JOIN meal_ingredients mi ON m.id = mi.meal_id
+		LEFT JOIN ingredients i ON mi.ingredient_id = i.id
+		ORDER BY %s, i.id
+	`, where, q.orderClause(), limit, q.orderClause()), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var meal Meal
+		var mealDateTime sql.NullString
+		var ingredientID sql.NullInt64
+		var name, quantityUnit, macroUnit sql.NullString
+		var quantity, carbs, fat, protein, kcal sql.NullFloat64
+		var fibre, sugar, saturatedFat, sodium, alcohol, servingSize sql.NullFloat64
+		err := rows.Scan(&meal.ID, &meal.Name, &mealDateTime,
+			&ingredientID, &name, &quantity, &quantityUnit, &carbs, &fat, &protein, &kcal,
+			&fibre, &sugar, &saturatedFat, &sodium, &alcohol, &macroUnit, &servingSize)
+		if err != nil {
+			return err
+		}
+		meal.DateTime = mealDateTime.String
+
+		var ingredient *Ingredient
+		if ingredientID.Valid {
+			ingredient = &Ingredient{
+				ID:           int(ingredientID.Int64),
+				Name:         name.String,
+				Quantity:     quantity.Float64,
+				QuantityUnit: quantityUnit.String,
+				Carbs:        carbs.Float64,
+				Fat:          fat.Float64,
+				Protein:      protein.Float64,
+				Kcal:         kcal.Float64,
+				Nutrients:    nullNutrients(fibre, sugar, saturatedFat, sodium, alcohol),
+				MacroUnit:    macroUnit.String,
+			}
+			if servingSize.Valid {
+				ingredient.ServingSize = &servingSize.Float64
+			}
+		}
+		if err := fn(meal, ingredient); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
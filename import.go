@@ -0,0 +1,472 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxImportBytes limits the size of an uploaded CSV file
+	maxImportBytes = 10 << 20
+	// maxImportErrors stops reporting row errors after this many
+	maxImportErrors = 100
+	// defaultImportTime is used for meals whose rows have no time
+	defaultImportTime = "12:00:00"
+)
+
+// ImportMapping names the CSV column holding each field. Either DateTime or Date (optionally
+// with Time) is required, as is Meal. Without an Ingredient column the meal name is used; without
+// Quantity and MacroUnit columns each row counts once with its macros as totals. Time, unit and
+// nutrient columns missing from the file are skipped, as older exports and other apps leave them out.
+type ImportMapping struct {
+	DateTime     string `json:"datetime,omitempty"`
+	Date         string `json:"date,omitempty"`
+	Time         string `json:"time,omitempty"`
+	Meal         string `json:"meal,omitempty"`
+	MealID       string `json:"mealId,omitempty"` // Keeps meals with the same date and name apart
+	Ingredient   string `json:"ingredient,omitempty"`
+	Quantity     string `json:"quantity,omitempty"`
+	QuantityUnit string `json:"quantityUnit,omitempty"`
+	MacroUnit    string `json:"macroUnit,omitempty"`
+	ServingSize  string `json:"servingSize,omitempty"`
+	Carbs        string `json:"carbs,omitempty"`
+	Fat          string `json:"fat,omitempty"`
+	Protein      string `json:"protein,omitempty"`
+	Kcal         string `json:"kcal,omitempty"`
+	Fibre        string `json:"fibre,omitempty"`
+	Sugar        string `json:"sugar,omitempty"`
+	SaturatedFat string `json:"saturatedFat,omitempty"`
+	Sodium       string `json:"sodium,omitempty"` // mg
+	Alcohol      string `json:"alcohol,omitempty"`
+}
+
+// importPresets are the column mappings of the supported export formats: our own meals.csv,
+// MyFitnessPal's nutrition export (one row of totals per meal) and Cronometer's servings export
+// (one row of totals per food)
+var importPresets = map[string]ImportMapping{
+	"macro-tracker": {
+		DateTime: "datetime", Meal: "meal_name", MealID: "meal_id", Ingredient: "ingredient",
+		Quantity: "quantity", QuantityUnit: "quantity_unit", MacroUnit: "macro_unit", ServingSize: "serving_size",
+		Carbs: "carbs", Fat: "fat", Protein: "protein", Kcal: "kcal",
+		Fibre: "fibre", Sugar: "sugar", SaturatedFat: "saturated_fat", Sodium: "sodium", Alcohol: "alcohol",
+	},
+	"myfitnesspal": {
+		Date: "Date", Time: "Time", Meal: "Meal",
+		Carbs: "Carbohydrates (g)", Fat: "Fat (g)", Protein: "Protein (g)", Kcal: "Calories",
+		Fibre: "Fiber", Sugar: "Sugar", SaturatedFat: "Saturated Fat", Sodium: "Sodium (mg)",
+	},
+	"cronometer": {
+		Date: "Day", Time: "Time", Meal: "Group", Ingredient: "Food Name",
+		Carbs: "Carbs (g)", Fat: "Fat (g)", Protein: "Protein (g)", Kcal: "Energy (kcal)",
+		Fibre: "Fiber (g)", Sugar: "Sugars (g)", SaturatedFat: "Saturated (g)", Sodium: "Sodium (mg)", Alcohol: "Alcohol (g)",
+	},
+}
+
+// ImportResult is the response of POST /api/import/meals
+type ImportResult struct {
+	DryRun      bool             `json:"dryRun"`
+	Rows        int              `json:"rows"`
+	Meals       []ImportedMeal   `json:"meals"`
+	Ingredients int              `json:"ingredients"`
+	Errors      []ImportRowError `json:"errors,omitempty"`
+}
+
+// ImportedMeal summarises a meal created (or, in a dry run, that would be created) by an import
+type ImportedMeal struct {
+	ID          int         `json:"id,omitempty"`
+	Name        string      `json:"name"`
+	DateTime    string      `json:"datetime"`
+	Ingredients int         `json:"ingredients"`
+	Totals      MacroTotals `json:"totals"`
+}
+
+// ImportRowError reports a CSV row that could not be imported, by its line number in the file
+type ImportRowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+// importMeals imports meals from a CSV file uploaded as the "file" form field. The columns are
+// given by a "preset" (macro-tracker, myfitnesspal or cronometer) and/or a JSON "mapping" that
+// overrides it. Rows are grouped into meals by date and meal name and created in one transaction;
+// nothing is created if any row is invalid. With dryRun=true nothing is created, and the
+// response reports what would be.
+func importMeals(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes)
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A CSV file is required in the file field"})
+		return
+	}
+
+	var mapping ImportMapping
+	if preset := c.PostForm("preset"); preset != "" {
+		var ok bool
+		if mapping, ok = importPresets[preset]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid preset %q, expected macro-tracker, myfitnesspal or cronometer", preset)})
+			return
+		}
+	}
+	if mappingJSON := c.PostForm("mapping"); mappingJSON != "" {
+		if err := json.Unmarshal([]byte(mappingJSON), &mapping); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid mapping: %v", err)})
+			return
+		}
+	}
+	dryRun, _ := strconv.ParseBool(c.PostForm("dryRun"))
+
+	f, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer f.Close()
+
+	meals, result, err := parseImportCSV(f, mapping)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	result.DryRun = dryRun
+	if dryRun {
+		c.JSON(http.StatusOK, result)
+		return
+	}
+	if len(result.Errors) > 0 {
+		c.JSON(http.StatusBadRequest, result)
+		return
+	}
+
+	if err := store.CreateMeals(currentUserID(c), meals); err != nil {
+		respondStoreError(c, err, "")
+		return
+	}
+	for i := range meals {
+		result.Meals[i].ID = meals[i].ID
+	}
+
+	c.JSON(http.StatusCreated, result)
+}
+
+// importColumns holds the index of each mapped column in the CSV header, or -1
+type importColumns struct {
+	dateTime, date, time, meal, mealID, ingredient, quantity, quantityUnit, macroUnit, servingSize int
+	carbs, fat, protein, kcal, fibre, sugar, saturatedFat, sodium, alcohol                         int
+}
+
+// optionalImportColumns are the fields whose mapped column may be missing from the file
+var optionalImportColumns = map[string]bool{
+	"time": true, "quantityUnit": true, "servingSize": true,
+	"fibre": true, "sugar": true, "saturatedFat": true, "sodium": true, "alcohol": true,
+}
+
+// Helper function to find the mapped columns in the header row
+func findImportColumns(header []string, mapping ImportMapping) (importColumns, error) {
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		// Spreadsheet exports often start with a byte order mark
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		index[strings.ToLower(name)] = i
+	}
+	var missing []string
+	find := func(field, column string, required bool) int {
+		if column == "" {
+			if required {
+				missing = append(missing, field)
+			}
+			return -1
+		}
+		i, ok := index[strings.ToLower(column)]
+		if !ok && !optionalImportColumns[field] {
+			missing = append(missing, fmt.Sprintf("%s (column %q)", field, column))
+		}
+		if !ok {
+			return -1
+		}
+		return i
+	}
+
+	cols := importColumns{
+		dateTime:     find("datetime", mapping.DateTime, false),
+		date:         find("date", mapping.Date, false),
+		time:         find("time", mapping.Time, false),
+		meal:         find("meal", mapping.Meal, true),
+		mealID:       find("mealId", mapping.MealID, false),
+		ingredient:   find("ingredient", mapping.Ingredient, false),
+		quantity:     find("quantity", mapping.Quantity, false),
+		quantityUnit: find("quantityUnit", mapping.QuantityUnit, false),
+		macroUnit:    find("macroUnit", mapping.MacroUnit, false),
+		servingSize:  find("servingSize", mapping.ServingSize, false),
+		carbs:        find("carbs", mapping.Carbs, false),
+		fat:          find("fat", mapping.Fat, false),
+		protein:      find("protein", mapping.Protein, false),
+		kcal:         find("kcal", mapping.Kcal, false),
+		fibre:        find("fibre", mapping.Fibre, false),
+		sugar:        find("sugar", mapping.Sugar, false),
+		saturatedFat: find("saturatedFat", mapping.SaturatedFat, false),
+		sodium:       find("sodium", mapping.Sodium, false),
+		alcohol:      find("alcohol", mapping.Alcohol, false),
+	}
+	if cols.dateTime < 0 && cols.date < 0 {
+		missing = append(missing, "datetime or date")
+	}
+	if len(missing) > 0 {
+		return cols, fmt.Errorf("missing columns: %s", strings.Join(missing, ", "))
+	}
+	return cols, nil
+}
+
+// parseImportCSV reads the CSV and groups its rows into meals, in order of their datetime. Invalid
+// rows are reported in the result rather than returned as an error.
+func parseImportCSV(r io.Reader, mapping ImportMapping) ([]Meal, ImportResult, error) {
+	result := ImportResult{Meals: []ImportedMeal{}}
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, result, errors.New("the CSV file is empty")
+	}
+	if err != nil {
+		return nil, result, err
+	}
+	cols, err := findImportColumns(header, mapping)
+	if err != nil {
+		return nil, result, err
+	}
+
+	var meals []Meal
+	byKey := make(map[string]int)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, result, err
+			}
+			result.addError(parseErr.StartLine, err.Error())
+			continue
+		}
+		row, _ := reader.FieldPos(0)
+		if isBlankRecord(record) {
+			continue
+		}
+		result.Rows++
+
+		dateTime, mealName, ingredient, err := parseImportRecord(record, cols)
+		if err != nil {
+			result.addError(row, err.Error())
+			continue
+		}
+
+		// Group by date and meal name (and meal ID, when mapped)
+		key := dateTime[:len(dateLayout)] + "\x00" + strings.ToLower(mealName) + "\x00" + csvField(record, cols.mealID)
+		i, ok := byKey[key]
+		if !ok {
+			i = len(meals)
+			byKey[key] = i
+			meals = append(meals, Meal{Name: mealName, DateTime: dateTime})
+		}
+		if ingredient != nil {
+			meals[i].Ingredients = append(meals[i].Ingredients, *ingredient)
+		}
+	}
+
+	sort.SliceStable(meals, func(i, j int) bool { return meals[i].DateTime < meals[j].DateTime })
+	for _, meal := range meals {
+		var totals MacroTotals
+		for _, ingredient := range meal.Ingredients {
+			totals.add(ingredient.macros())
+		}
+		result.Ingredients += len(meal.Ingredients)
+		result.Meals = append(result.Meals, ImportedMeal{
+			Name:        meal.Name,
+			DateTime:    meal.DateTime,
+			Ingredients: len(meal.Ingredients),
+			Totals:      totals.rounded(),
+		})
+	}
+	return meals, result, nil
+}
+
+// Helper function to parse one CSV record into its meal's datetime and name and an ingredient.
+// The ingredient is nil if the ingredient column is mapped but empty, as for meals exported
+// without ingredients.
+func parseImportRecord(record []string, cols importColumns) (string, string, *Ingredient, error) {
+	mealName := csvField(record, cols.meal)
+	if mealName == "" {
+		return "", "", nil, errors.New("meal name is empty")
+	}
+	dateTime, err := importDateTime(record, cols)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	ingredient := &Ingredient{Name: csvField(record, cols.ingredient)}
+	if ingredient.Name == "" {
+		if cols.ingredient >= 0 {
+			return dateTime, mealName, nil, nil
+		}
+		ingredient.Name = mealName
+	}
+	ingredient.Quantity = 1
+	if cols.quantity >= 0 {
+		if ingredient.Quantity, err = importNumber(record, cols.quantity, "quantity"); err != nil {
+			return "", "", nil, err
+		}
+		if ingredient.Quantity <= 0 {
+			return "", "", nil, errors.New("quantity must be greater than 0")
+		}
+	}
+	ingredient.MacroUnit = "per_unit"
+	if unit := csvField(record, cols.macroUnit); unit != "" {
+		ingredient.MacroUnit = unit
+	}
+	ingredient.QuantityUnit = csvField(record, cols.quantityUnit)
+	if csvField(record, cols.servingSize) != "" {
+		servingSize, err := importNumber(record, cols.servingSize, "serving size")
+		if err != nil {
+			return "", "", nil, err
+		}
+		ingredient.ServingSize = &servingSize
+	}
+	for _, macro := range []struct {
+		value  *float64
+		column int
+		name   string
+	}{
+		{&ingredient.Carbs, cols.carbs, "carbs"},
+		{&ingredient.Fat, cols.fat, "fat"},
+		{&ingredient.Protein, cols.protein, "protein"},
+		{&ingredient.Kcal, cols.kcal, "kcal"},
+		{&ingredient.Fibre, cols.fibre, "fibre"},
+		{&ingredient.Sugar, cols.sugar, "sugar"},
+		{&ingredient.SaturatedFat, cols.saturatedFat, "saturated fat"},
+		{&ingredient.Sodium, cols.sodium, "sodium"},
+		{&ingredient.Alcohol, cols.alcohol, "alcohol"},
+	} {
+		if *macro.value, err = importNumber(record, macro.column, macro.name); err != nil {
+			return "", "", nil, err
+		}
+		if *macro.value < 0 {
+			return "", "", nil, fmt.Errorf("%s must not be negative", macro.name)
+		}
+	}
+	if err := ingredient.validateUnits(); err != nil {
+		return "", "", nil, err
+	}
+	return dateTime, mealName, ingredient, nil
+}
+
+// Helper function to read a record's datetime, from a datetime column or a date and optional time column
+func importDateTime(record []string, cols importColumns) (string, error) {
+	if value := csvField(record, cols.dateTime); value != "" {
+		t, _, err := parseDateTimeParam(value)
+		if err != nil {
+			return "", err
+		}
+		return t.Format(timestampLayout), nil
+	}
+
+	value := csvField(record, cols.date)
+	if value == "" {
+		return "", errors.New("date is empty")
+	}
+	date, err := time.Parse(dateLayout, value)
+	if err != nil {
+		return "", fmt.Errorf("invalid date %q, expected YYYY-MM-DD", value)
+	}
+	clock := defaultImportTime
+	if value := csvField(record, cols.time); value != "" {
+		t, err := parseImportTime(value)
+		if err != nil {
+			return "", err
+		}
+		clock = t.Format("15:04:05")
+	}
+	return date.Format(dateLayout) + " " + clock, nil
+}
+
+// Helper function to parse a time of day in 24-hour or 12-hour (AM/PM) form
+func parseImportTime(value string) (time.Time, error) {
+	for _, layout := range []string{"15:04", "15:04:05", "3:04 PM", "3:04PM", "3:04:05 PM"} {
+		if t, err := time.Parse(layout, strings.ToUpper(value)); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", value)
+}
+
+// Helper function to read a numeric column, treating a missing column or empty value as 0
+func importNumber(record []string, column int, name string) (float64, error) {
+	value := csvField(record, column)
+	if value == "" {
+		return 0, nil
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", name, value)
+	}
+	return v, nil
+}
+
+// Helper function to read a trimmed column value, or "" if the column is not mapped or missing
+func csvField(record []string, column int) string {
+	if column < 0 || column >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[column])
+}
+
+func isBlankRecord(record []string) bool {
+	for _, value := range record {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *ImportResult) addError(row int, message string) {
+	if len(r.Errors) < maxImportErrors {
+		r.Errors = append(r.Errors, ImportRowError{Row: row, Error: message})
+	}
+}
+
+// CreateMeals creates all of the meals in one transaction, setting their IDs
+func (s *sqlStore) CreateMeals(userID int, meals []Meal) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	ids := make([]int, len(meals))
+	for i := range meals {
+		if ids[i], err = insertMeal(tx, userID, &meals[i]); err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	for i := range meals {
+		meals[i].ID = ids[i]
+	}
+	return nil
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultTemplateSearchPageSize = 20
+	maxTemplateSearchPageSize     = 100
+	// minTrigramSimilarity is how similar a misspelt search word must be to a name word to match it
+	minTrigramSimilarity = 0.3
+)
+
+// templateSearchQuery is a fuzzy search of the user's ingredient templates
+type templateSearchQuery struct {
+	Terms    []string // Lower-case search words, all of which must match the name
+	Page     int
+	PageSize int
+}
+
+// templateCandidate is an ingredient template considered by a search, with how often and how
+// recently meals used it
+type templateCandidate struct {
+	id       int
+	name     string
+	uses     int
+	lastUsed time.Time // Zero if never used
+	score    float64
+}
+
+// getIngredientTemplateSearch handles GET /api/ingredient-templates/search?q=&page=&pageSize=,
+// returning the best matches first with the total number matching in X-Total-Count
+func getIngredientTemplateSearch(c *gin.Context) {
+	q := templateSearchQuery{Terms: searchWords(c.Query("q"))}
+	var err error
+	if q.Page, q.PageSize, err = parsePagination(c, defaultTemplateSearchPageSize, maxTemplateSearchPageSize); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	templates, total, err := store.SearchIngredientTemplates(currentUserID(c), q)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("X-Total-Count", strconv.Itoa(total))
+	c.JSON(http.StatusOK, templates)
+}
+
+// rankTemplates scores the candidates against the search words, dropping those that do not
+// match, and sorts them best first. Name relevance counts most, then how often and how recently
+// the template was used in meals; without search words only use counts.
+func rankTemplates(candidates []templateCandidate, terms []string, now time.Time) []templateCandidate {
+	ranked := candidates[:0]
+	for _, candidate := range candidates {
+		relevance := nameRelevance(candidate.name, terms)
+		if relevance == 0 {
+			continue
+		}
+		// Frequency saturates at 50 uses; recency halves after two weeks
+		frequency := math.Min(math.Log1p(float64(candidate.uses))/math.Log1p(50), 1)
+		recency := 0.0
+		if !candidate.lastUsed.IsZero() {
+			days := math.Max(now.Sub(candidate.lastUsed).Hours()/24, 0)
+			recency = 1 / (1 + days/14)
+		}
+		candidate.score = 0.75*relevance + 0.15*frequency + 0.1*recency
+		ranked = append(ranked, candidate)
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].score != ranked[j].score {
+			return ranked[i].score > ranked[j].score
+		}
+		return strings.ToLower(ranked[i].name) < strings.ToLower(ranked[j].name)
+	})
+	return ranked
+}
+
+// nameRelevance returns how well a name matches the search words, from 0 (a word does not
+// match) to 1 (every word is a word of the name)
+func nameRelevance(name string, terms []string) float64 {
+	if len(terms) == 0 {
+		return 1
+	}
+	words := searchWords(name)
+	total := 0.0
+	for _, term := range terms {
+		best := 0.0
+		for _, word := range words {
+			best = math.Max(best, wordRelevance(term, word))
+		}
+		if best == 0 {
+			return 0
+		}
+		total += best
+	}
+	return total / float64(len(terms))
+}
+
+// wordRelevance scores a search word against a word of a name: exact and prefix matches first,
+// then abbreviations ("chik" for "chicken", "brst" for "breast") and then misspellings by
+// trigram similarity
+func wordRelevance(term, word string) float64 {
+	switch {
+	case term == word:
+		return 1
+	case strings.HasPrefix(word, term):
+		return 0.9
+	case strings.Contains(word, term):
+		return 0.7
+	case len(term) >= 2 && term[0] == word[0] && isSubsequence(term, word):
+		return 0.5 + 0.3*float64(len(term))/float64(len(word))
+	}
+	if similarity := trigramSimilarity(term, word); similarity >= minTrigramSimilarity {
+		return 0.6 * similarity
+	}
+	return 0
+}
+
+// Helper function to report whether the letters of a appear in b in order
+func isSubsequence(a, b string) bool {
+	i := 0
+	for j := 0; i < len(a) && j < len(b); j++ {
+		if a[i] == b[j] {
+			i++
+		}
+	}
+	return i == len(a)
+}
+
+// trigramSimilarity is the share of trigrams two words have in common, padded as pg_trgm does
+func trigramSimilarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	common := 0
+	for t := range ta {
+		if tb[t] {
+			common++
+		}
+	}
+	union := len(ta) + len(tb) - common
+	if union == 0 {
+		return 0
+	}
+	return float64(common) / float64(union)
+}
+
+func trigrams(word string) map[string]bool {
+	runes := []rune("  " + word + " ")
+	set := make(map[string]bool, len(runes))
+	for i := 0; i+3 <= len(runes); i++ {
+		set[string(runes[i:i+3])] = true
+	}
+	return set
+}
+
+// searchWords splits text into lower-case words of letters and digits
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// SearchIngredientTemplates shortlists the user's ingredient templates in SQL, ranks the
+// shortlist in Go, as both fuzzy matching and use-based ranking are beyond what Postgres and
+// SQLite share, and returns the requested page with portions along with the number matching
+func (s *sqlStore) SearchIngredientTemplates(userID int, q templateSearchQuery) ([]IngredientTemplate, int, error) {
+	candidates, err := s.listTemplateCandidates(userID, q.Terms)
+	if err != nil {
+		return nil, 0, err
+	}
+	ranked := rankTemplates(candidates, q.Terms, time.Now())
+
+	start := min((q.Page-1)*q.PageSize, len(ranked))
+	page := ranked[start:min(start+q.PageSize, len(ranked))]
+	if len(page) == 0 {
+		return []IngredientTemplate{}, len(ranked), nil
+	}
+
+	args := make([]interface{}, len(page))
+	position := make(map[int]int, len(page))
+	for i, candidate := range page {
+		args[i] = candidate.id
+		position[candidate.id] = i
+	}
+	condition := fmt.Sprintf("t.id IN (%s)", placeholders(1, len(args)))
+	templates, err := s.queryIngredientTemplates(condition, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err = s.attachPortions(templates, condition, args...); err != nil {
+		return nil, 0, err
+	}
+	sort.Slice(templates, func(i, j int) bool { return position[templates[i].ID] < position[templates[j].ID] })
+	return templates, len(ranked), nil
+}
+
+// listTemplateCandidates returns the user's ingredient templates that may match the search
+// words, with their use counts. A name can only match a word that it contains or that starts
+// with the same letter as one of its words (at its start or after a space, hyphen or
+// parenthesis); on Postgres, names it is similar to by trigrams are kept for misspellings.
+func (s *sqlStore) listTemplateCandidates(userID int, terms []string) ([]templateCandidate, error) {
+	conditions := []string{"t.user_id = $1"}
+	args := []interface{}{userID}
+	for _, term := range terms {
+		first := string([]rune(term)[0])
+		var matches []string
+		for _, pattern := range []string{first + "%", "% " + first + "%", "%-" + first + "%", "%(" + first + "%", "%" + term + "%"} {
+			args = append(args, pattern)
+			matches = append(matches, fmt.Sprintf("LOWER(t.name) LIKE $%d", len(args)))
+		}
+		if s.dialect.wordSimilarityExpr != "" {
+			args = append(args, term)
+			similarity := fmt.Sprintf(s.dialect.wordSimilarityExpr, fmt.Sprintf("$%d", len(args)), "LOWER(t.name)")
+			matches = append(matches, fmt.Sprintf("%s >= %v", similarity, minTrigramSimilarity))
+		}
+		conditions = append(conditions, "("+strings.Join(matches, " OR ")+")")
+	}
+
+	rows, err := s.db.Query("SELECT t.id, t.name, t.use_count, t.last_used_at FROM ingredient_templates t WHERE "+strings.Join(conditions, " AND "), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var candidates []templateCandidate
+	for rows.Next() {
+		var candidate templateCandidate
+		var lastUsed sql.NullString
+		if err := rows.Scan(&candidate.id, &candidate.name, &candidate.uses, &lastUsed); err != nil {
+			return nil, err
+		}
+		if lastUsed.Valid {
+			candidate.lastUsed, _, _ = parseDateTimeParam(lastUsed.String)
+		}
+		candidates = append(candidates, candidate)
+	}
+	return candidates, rows.Err()
+}
+
+// refreshTemplateUses recomputes the use counts search ranks ingredient templates by, for the
+// templates with the given IDs. Statements that add, remove or relink meal ingredients or move
+// meals call it in the same transaction.
+func refreshTemplateUses(q queryer, templateIDs []int) error {
+	if len(templateIDs) == 0 {
+		return nil
+	}
+	args := make([]interface{}, len(templateIDs))
+	for i, id := range templateIDs {
+		args[i] = id
+	}
+	_, err := q.Exec(`
+		UPDATE ingredient_templates SET
+			use_count = (
+				SELECT COUNT(*) FROM ingredients i
+				JOIN meal_ingredients mi ON mi.ingredient_id = i.id
+				WHERE i.ingredient_template_id = ingredient_templates.id
+			),
+			last_used_at = (
+				SELECT MAX(m.datetime) FROM ingredients i
+				JOIN meal_ingredients mi ON mi.ingredient_id = i.id
+				JOIN meals m ON m.id = mi.meal_id
+				WHERE i.ingredient_template_id = ingredient_templates.id
+			)
+		WHERE id IN (`+placeholders(1, len(args))+`)
+	`, args...)
+	return err
+}
+
+// Helper function to list the ingredient templates ingredients were added from
+func ingredientTemplateIDs(ingredients []Ingredient) []int {
+	var ids []int
+	for _, ingredient := range ingredients {
+		if ingredient.IngredientTemplateID != nil {
+			ids = append(ids, *ingredient.IngredientTemplateID)
+		}
+	}
+	return ids
+}
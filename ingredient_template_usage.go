@@ -0,0 +1,83 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IngredientTemplateUsage is a logged ingredient that was added from an ingredient template,
+// along with the meal it belongs to
+type IngredientTemplateUsage struct {
+	MealID     int        `json:"mealId"`
+	MealName   string     `json:"mealName"`
+	DateTime   string     `json:"datetime"`
+	Ingredient Ingredient `json:"ingredient"`
+}
+
+// getIngredientTemplateUsage lists the meals that used an ingredient template, newest first
+func getIngredientTemplateUsage(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	usage, err := store.ListIngredientTemplateUsage(currentUserID(c), id)
+	if err != nil {
+		respondStoreError(c, err, "Ingredient template not found")
+		return
+	}
+
+	for i := range usage {
+		usage[i].Ingredient.computeTotals()
+	}
+	c.JSON(http.StatusOK, usage)
+}
+
+func (s *sqlStore) ListIngredientTemplateUsage(userID, templateID int) ([]IngredientTemplateUsage, error) {
+	var exists bool
+	err := s.db.QueryRow("SELECT EXISTS (SELECT 1 FROM ingredient_templates WHERE id = $1 AND user_id = $2)", templateID, userID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errNotFound
+	}
+
+	rows, err := s.db.Query(`
+		SELECT m.id, m.name, m.datetime,
+		       i.id, i.name, i.quantity, i.quantity_unit, i.carbs, i.fat, i.protein, i.kcal,
+		       i.fibre, i.sugar, i.saturated_fat, i.sodium, i.alcohol, i.macro_unit, i.serving_size
+		FROM ingredients i
+		JOIN meal_ingredients mi ON mi.ingredient_id = i.id
+		JOIN meals m ON m.id = mi.meal_id
+		WHERE i.ingredient_template_id = $1 AND m.user_id = $2
+		ORDER BY m.datetime DESC, m.id DESC, i.id
+	`, templateID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usage := []IngredientTemplateUsage{}
+	for rows.Next() {
+		var u IngredientTemplateUsage
+		var mealDateTime sql.NullString
+		var servingSize sql.NullFloat64
+		i := &u.Ingredient
+		err := rows.Scan(&u.MealID, &u.MealName, &mealDateTime, &i.ID, &i.Name, &i.Quantity, &i.QuantityUnit, &i.Carbs, &i.Fat, &i.Protein, &i.Kcal,
+			&i.Fibre, &i.Sugar, &i.SaturatedFat, &i.Sodium, &i.Alcohol, &i.MacroUnit, &servingSize)
+		if err != nil {
+			return nil, err
+		}
+		if servingSize.Valid {
+			i.ServingSize = &servingSize.Float64
+		}
+		u.DateTime = mealDateTime.String
+		id := templateID
+		i.IngredientTemplateID = &id
+		usage = append(usage, u)
+	}
+	return usage, rows.Err()
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// kcalPerKg is the energy stored in a kilogram of body weight, mostly fat tissue
+	kcalPerKg = 7700
+	// defaultTDEEDays is the rolling window TDEE is estimated over
+	defaultTDEEDays = 28
+	minTDEEDays     = 7
+	maxTDEEDays     = 365
+	// maxWeeklyRate caps the suggested rate of loss or gain, in kg per week
+	maxWeeklyRate = 1.5
+	// defaultKcalMargin is the +/- range around a suggested kcal target when it is applied
+	defaultKcalMargin = 100
+)
+
+// DailyIntake is the total of the meals logged on one day
+type DailyIntake struct {
+	Date      string      `json:"date"`
+	MealCount int         `json:"mealCount"`
+	Totals    MacroTotals `json:"totals"`
+}
+
+// TDEEEstimate is the response of GET /api/insights/tdee. TDEE is the average logged intake
+// corrected by the energy equivalent of the trend weight change over the window. It is left out,
+// with a Reason, when there is not enough data.
+type TDEEEstimate struct {
+	From          string   `json:"from"`
+	To            string   `json:"to"`
+	Days          int      `json:"days"`
+	LoggedDays    int      `json:"loggedDays"`
+	AverageIntake float64  `json:"averageIntake"`
+	StartWeight   *float64 `json:"startWeight,omitempty"` // Trend weight at the first weigh-in in the window
+	EndWeight     *float64 `json:"endWeight,omitempty"`   // Trend weight at the last weigh-in in the window
+	WeeklyChange  *float64 `json:"weeklyChange,omitempty"`
+	TDEE          *float64 `json:"tdee,omitempty"`
+	WeeklyRate    *float64 `json:"weeklyRate,omitempty"`
+	SuggestedKcal *float64 `json:"suggestedKcal,omitempty"` // Daily kcal to change weight at WeeklyRate
+	Reason        string   `json:"reason,omitempty"`
+}
+
+// ApplyTDEERequest is the body of POST /api/insights/tdee/apply
+type ApplyTDEERequest struct {
+	WeeklyRate    *float64 `json:"weeklyRate" binding:"required"` // kg per week, negative to lose weight
+	Days          int      `json:"days,omitempty"`
+	To            string   `json:"to,omitempty"`
+	EffectiveFrom string   `json:"effectiveFrom,omitempty"` // Defaults to today
+	KcalMargin    *float64 `json:"kcalMargin,omitempty"`    // Defaults to 100
+}
+
+// getTDEEEstimate estimates TDEE over ?days (default 28) ending on ?to (default yesterday, the last
+// complete day). With ?weeklyRate (kg/week) it also suggests a daily kcal target.
+func getTDEEEstimate(c *gin.Context) {
+	days := defaultTDEEDays
+	if daysParam := c.Query("days"); daysParam != "" {
+		value, err := strconv.Atoi(daysParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid days"})
+			return
+		}
+		days = value
+	}
+	var weeklyRate *float64
+	if rateParam := c.Query("weeklyRate"); rateParam != "" {
+		value, err := strconv.ParseFloat(rateParam, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid weeklyRate"})
+			return
+		}
+		weeklyRate = &value
+	}
+
+	end, err := parseTDEEWindow(c.Query("to"), days, weeklyRate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	estimate, err := estimateTDEE(currentUserID(c), end, days, weeklyRate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, estimate)
+}
+
+// applyTDEETarget creates daily targets using the kcal suggested for a weekly rate. The other
+// macro targets are carried over from the daily targets in effect on the same day.
+func applyTDEETarget(c *gin.Context) {
+	var req ApplyTDEERequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Days == 0 {
+		req.Days = defaultTDEEDays
+	}
+	if req.EffectiveFrom == "" {
+		req.EffectiveFrom = time.Now().Format(dateLayout)
+	} else if _, err := time.Parse(dateLayout, req.EffectiveFrom); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid effectiveFrom, expected YYYY-MM-DD"})
+		return
+	}
+	margin := float64(defaultKcalMargin)
+	if req.KcalMargin != nil {
+		if *req.KcalMargin < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "kcalMargin must not be negative"})
+			return
+		}
+		margin = *req.KcalMargin
+	}
+
+	end, err := parseTDEEWindow(req.To, req.Days, req.WeeklyRate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	estimate, err := estimateTDEE(currentUserID(c), end, req.Days, req.WeeklyRate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if estimate.SuggestedKcal == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot estimate TDEE: " + estimate.Reason})
+		return
+	}
+
+	current, err := store.GetDailyTargets(currentUserID(c), req.EffectiveFrom)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	// Dated targets would not apply on a day a target profile covers
+	if current != nil && current.ProfileID != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Target profile %q applies on %s; update the profile instead", current.ProfileName, req.EffectiveFrom)})
+		return
+	}
+	targets := DailyTargets{}
+	if current != nil {
+		targets = *current
+		targets.ID, targets.CreatedAt, targets.UpdatedAt = 0, "", ""
+	}
+	targets.EffectiveFrom = req.EffectiveFrom
+	kcalMin, kcalMax := math.Max(0, *estimate.SuggestedKcal-margin), *estimate.SuggestedKcal+margin
+	targets.Kcal = &MacroTarget{Min: &kcalMin, Max: &kcalMax}
+	// Percentage targets follow the new kcal target
+	if err := resolveTargetMode(&targets); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := store.CreateDailyTargets(currentUserID(c), &targets); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, targets)
+}
+
+// Helper function to validate the TDEE window and weekly rate, returning the window's last day.
+// An empty to means yesterday, the last complete day.
+func parseTDEEWindow(to string, days int, weeklyRate *float64) (time.Time, error) {
+	end := time.Now().AddDate(0, 0, -1)
+	if to != "" {
+		var err error
+		if end, err = time.Parse(dateLayout, to); err != nil {
+			return end, errors.New("invalid to, expected YYYY-MM-DD")
+		}
+	}
+	if days < minTDEEDays || days > maxTDEEDays {
+		return end, fmt.Errorf("days must be between %d and %d", minTDEEDays, maxTDEEDays)
+	}
+	if weeklyRate != nil && math.Abs(*weeklyRate) > maxWeeklyRate {
+		return end, fmt.Errorf("weeklyRate must be between -%v and %v kg per week", maxWeeklyRate, maxWeeklyRate)
+	}
+	return end, nil
+}
+
+// estimateTDEE computes the TDEE estimate for the window of days ending on end
+func estimateTDEE(userID int, end time.Time, days int, weeklyRate *float64) (TDEEEstimate, error) {
+	estimate := TDEEEstimate{
+		From:       end.AddDate(0, 0, 1-days).Format(dateLayout),
+		To:         end.Format(dateLayout),
+		Days:       days,
+		WeeklyRate: weeklyRate,
+	}
+
+	intake, err := store.ListDailyIntake(userID, estimate.From, estimate.To)
+	if err != nil {
+		return estimate, err
+	}
+	var totalKcal float64
+	for _, day := range intake {
+		totalKcal += day.Totals.Kcal
+	}
+	estimate.LoggedDays = len(intake)
+	if estimate.LoggedDays > 0 {
+		estimate.AverageIntake = round2(totalKcal / float64(estimate.LoggedDays))
+	}
+
+	// The trend depends on every earlier weight, so compute it from the first measurement
+	measurements, err := store.ListMeasurements(userID, "", estimate.To)
+	if err != nil {
+		return estimate, err
+	}
+	var first, last *WeightTrendPoint
+	points := weightTrend(measurements, defaultTrendAlpha)
+	for i := range points {
+		if points[i].Date < estimate.From {
+			continue
+		}
+		if first == nil {
+			first = &points[i]
+		}
+		last = &points[i]
+	}
+
+	// Require most of the window to be logged, and weigh-ins spanning at least half of it
+	if estimate.LoggedDays*2 < days {
+		estimate.Reason = fmt.Sprintf("meals were logged on %d of %d days; at least half are needed", estimate.LoggedDays, days)
+		return estimate, nil
+	}
+	if first == nil || first == last {
+		estimate.Reason = "at least two weigh-ins in the window are needed"
+		return estimate, nil
+	}
+	firstDate, _ := time.Parse(dateLayout, first.Date)
+	lastDate, _ := time.Parse(dateLayout, last.Date)
+	span := lastDate.Sub(firstDate).Hours() / 24
+	if span*2 < float64(days) {
+		estimate.Reason = fmt.Sprintf("weigh-ins only span %.0f of %d days; at least half are needed", span, days)
+		return estimate, nil
+	}
+
+	change := last.Trend - first.Trend
+	weeklyChange := round2(change / span * 7)
+	tdee := math.Round(estimate.AverageIntake - change*kcalPerKg/span)
+	estimate.StartWeight = &first.Trend
+	estimate.EndWeight = &last.Trend
+	estimate.WeeklyChange = &weeklyChange
+	estimate.TDEE = &tdee
+	if weeklyRate != nil {
+		// Rounded to 10 kcal, as nobody tracks more precisely than that
+		suggested := math.Round((tdee+*weeklyRate*kcalPerKg/7)/10) * 10
+		estimate.SuggestedKcal = &suggested
+	}
+	return estimate, nil
+}
+
+// ListDailyIntake returns the macro totals of the meals logged on each day between from and to
+// (YYYY-MM-DD, inclusive). Days without meals are left out.
+func (s *sqlStore) ListDailyIntake(userID int, from, to string) ([]DailyIntake, error) {
+	args, err := dailyIntakeArgs(userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query("SELECT day, meal_count, carbs, fat, protein, kcal, fibre, sugar, saturated_fat, sodium, alcohol FROM ("+s.dailyIntakeSQL()+") d ORDER BY day", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	intake := []DailyIntake{}
+	for rows.Next() {
+		var d DailyIntake
+		t := &d.Totals
+		err := rows.Scan(&d.Date, &d.MealCount, &t.Carbs, &t.Fat, &t.Protein, &t.Kcal, &t.Fibre, &t.Sugar, &t.SaturatedFat, &t.Sodium, &t.Alcohol)
+		if err != nil {
+			return nil, err
+		}
+		d.Date = dateString(d.Date)
+		d.Totals = d.Totals.rounded()
+		intake = append(intake, d)
+	}
+	return intake, rows.Err()
+}
+
+// dailyIntakeSQL sums the macros of user $1's meals per day, for meals from $2 up to (but not
+// including) $3. It selects day, meal_count, carbs, fat, protein, kcal, fibre, sugar,
+// saturated_fat, sodium and alcohol.
+func (s *sqlStore) dailyIntakeSQL() string {
+	day := fmt.Sprintf(s.dialect.dateExpr, "m.datetime")
+	return fmt.Sprintf(`
+		SELECT %[1]s AS day, COUNT(DISTINCT m.id) AS meal_count,
+		       COALESCE(SUM(%[2]s), 0) AS carbs, COALESCE(SUM(%[3]s), 0) AS fat,
+		       COALESCE(SUM(%[4]s), 0) AS protein, COALESCE(SUM(%[5]s), 0) AS kcal,
+		       COALESCE(SUM(%[6]s), 0) AS fibre, COALESCE(SUM(%[7]s), 0) AS sugar,
+		       COALESCE(SUM(%[8]s), 0) AS saturated_fat, COALESCE(SUM(%[9]s), 0) AS sodium,
+		       COALESCE(SUM(%[10]s), 0) AS alcohol
+		FROM meals m
+		LEFT JOIN meal_ingredients mi ON mi.meal_id = m.id
+		LEFT JOIN ingredients i ON i.id = mi.ingredient_id
+		WHERE m.user_id = $1 AND m.datetime >= $2 AND m.datetime < $3
+		GROUP BY %[1]s
+	`, day, scaledMacroSQL("carbs"), scaledMacroSQL("fat"), scaledMacroSQL("protein"), scaledMacroSQL("kcal"),
+		scaledMacroSQL("fibre"), scaledMacroSQL("sugar"), scaledMacroSQL("saturated_fat"), scaledMacroSQL("sodium"), scaledMacroSQL("alcohol"))
+}
+
+// Helper function to build the arguments of dailyIntakeSQL for the days from and to (inclusive)
+func dailyIntakeArgs(userID int, from, to string) ([]interface{}, error) {
+	end, err := time.Parse(dateLayout, to)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{userID, from + " 00:00:00", end.AddDate(0, 0, 1).Format(timestampLayout)}, nil
+}
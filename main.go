@@ -1,27 +1,35 @@
 package main
 
 import (
-	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
-	_ "github.com/lib/pq"
 )
 
 type Ingredient struct {
 	ID         int     `json:"id,omitempty"`
 	Name       string  `json:"name"`
 	Quantity   float64 `json:"quantity"`
+	QuantityUnit string `json:"quantityUnit,omitempty"` // Unit the quantity is in, see units.go; defaults to the macro unit's (g for per_100g)
 	Carbs      float64 `json:"carbs"`
 	Fat        float64 `json:"fat"`
 	Protein    float64 `json:"protein"`
 	Kcal       float64 `json:"kcal"`
+	Nutrients
 	MacroUnit  string  `json:"macroUnit"`
+	ServingSize *float64 `json:"servingSize,omitempty"` // Grams per serving, to convert between servings and weights
+	IngredientTemplateID *int `json:"ingredientTemplateId,omitempty"` // Template the ingredient was added from, if any
+	Portion    string  `json:"portion,omitempty"` // On input only: Quantity is a number of this portion of the template, see portions.go
+	RecipeID   *int    `json:"recipeId,omitempty"` // On input only: the ingredient is Quantity (in QuantityUnit, default serving) of this recipe, see recipes.go
+	Totals     *MacroTotals `json:"totals,omitempty"` // Computed macros for the quantity eaten
 }
 
 type IngredientTemplate struct {
@@ -31,9 +39,13 @@ type IngredientTemplate struct {
 	Fat             float64 `json:"fat"`
 	Protein         float64 `json:"protein"`
 	Kcal            float64 `json:"kcal"`
+	Nutrients
 	MacroUnit       string  `json:"macroUnit"`
+	ServingSize     *float64 `json:"servingSize,omitempty"` // Grams per serving, for per_serving macros and servings of per-weight ones
 	DefaultQuantity float64 `json:"defaultQuantity,omitempty"` // Default quantity when used in meals
 	Quantity        float64 `json:"quantity,omitempty"`         // Quantity when used in meal templates
+	Totals          *MacroTotals `json:"totals,omitempty"`    // Computed macros for Quantity when used in meal templates
+	Portions        []IngredientPortion `json:"portions,omitempty"` // Named portions, e.g. 1 slice = 30g
 	CreatedAt       string  `json:"createdAt,omitempty"`
 	UpdatedAt       string  `json:"updatedAt,omitempty"`
 }
@@ -43,6 +55,7 @@ type Meal struct {
 	Name        string        `json:"name"`
 	DateTime    string        `json:"datetime"`
 	Ingredients []Ingredient  `json:"ingredients"`
+	Totals      *MacroTotals  `json:"totals,omitempty"`
 }
 
 type MealIngredient struct {
@@ -55,6 +68,7 @@ type MealTemplate struct {
 	Name        string        `json:"name"`
 	Description string        `json:"description,omitempty"`
 	Ingredients []IngredientTemplate `json:"ingredients"`
+	Totals      *MacroTotals  `json:"totals,omitempty"`
 	CreatedAt   string        `json:"createdAt,omitempty"`
 	UpdatedAt   string        `json:"updatedAt,omitempty"`
 }
@@ -67,10 +81,17 @@ type MealTemplateIngredient struct {
 
 type DailyTargets struct {
 	ID        int     `json:"id,omitempty"`
+	EffectiveFrom string `json:"effectiveFrom,omitempty"` // First day (YYYY-MM-DD) the targets apply; defaults to today
+	ProfileID *int    `json:"profileId,omitempty"`   // Set when the targets were resolved from a target profile
+	ProfileName string `json:"profileName,omitempty"`
+	Mode      string  `json:"mode,omitempty"`       // grams (default), percent_kcal or g_per_kg; see target_modes.go
+	BodyWeight *float64 `json:"bodyWeight,omitempty"` // kg, used by g_per_kg targets
+	Relative  *RelativeTargets `json:"relative,omitempty"` // Targets as entered in a relative mode; Carbs/Fat/Protein hold them in grams
 	Carbs     *MacroTarget `json:"carbs,omitempty"`
 	Fat       *MacroTarget `json:"fat,omitempty"`
 	Protein   *MacroTarget `json:"protein,omitempty"`
 	Kcal      *MacroTarget `json:"kcal,omitempty"`
+	NutrientTargets
 	CreatedAt string  `json:"createdAt,omitempty"`
 	UpdatedAt string  `json:"updatedAt,omitempty"`
 }
@@ -80,7 +101,7 @@ type MacroTarget struct {
 	Max *float64 `json:"max,omitempty"`
 }
 
-var db *sql.DB
+var store Store
 
 // Helper function to safely get float value or nil
 func getFloatOrNil(f *float64) interface{} {
@@ -118,24 +139,57 @@ func main() {
 	}
 	log.Print("Connecting to database with connection string:", connStr)
 
-	var err error
-	db, err = sql.Open("postgres", connStr)
+	dbStore, err := openStore(connStr)
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer db.Close()
+	defer dbStore.Close()
+	store = dbStore
 
-	// Test database connection
-	if err = db.Ping(); err != nil {
-		log.Fatal(err)
+	// `macro-tracker migrate [up | down [N] | status]` manages the schema and exits
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err = runMigrateCommand(dbStore, os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// Apply any pending schema migrations
+	if err = dbStore.migrateUp(); err != nil {
+		log.Fatal(fmt.Errorf("failed to migrate database: %w", err))
+	}
+
+	// `macro-tracker import-products FILE` loads an Open Food Facts dump and exits
+	if len(os.Args) > 1 && os.Args[1] == "import-products" {
+		if err = runImportProductsCommand(dbStore, os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// `macro-tracker import-foods usda DIR | cofid FILE.csv...` loads a food composition database and exits
+	if len(os.Args) > 1 && os.Args[1] == "import-foods" {
+		if err = runImportFoodsCommand(dbStore, os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	r := setupRouter()
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
 	}
 
-	// Initialize database tables
-	if err = initDB(); err != nil {
-		log.Fatal(fmt.Errorf("failed to initialize database: %w", err))
+	log.Printf("Server starting on port %s", port)
+	if err := r.Run(":" + port); err != nil {
+		log.Fatal(err)
 	}
+}
 
-	// Setup Gin router
+// setupRouter registers the API routes and the static frontend
+func setupRouter() *gin.Engine {
 	r := gin.Default()
 
 	// CORS configuration
@@ -143,6 +197,7 @@ func main() {
 	config.AllowOrigins = []string{"http://localhost:5173", "http://localhost:5174", "http://127.0.0.1:5173", "http://127.0.0.1:5174"}
 	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
 	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization"}
+	config.ExposeHeaders = []string{"X-Total-Count"}
 	r.Use(cors.New(config))
 
 	// Serve static files from the dist directory
@@ -161,434 +216,321 @@ func main() {
 		c.File("./dist/index.html")
 	})
 
-	// API routes
-	api := r.Group("/api")
+	// Public auth routes
+	auth := r.Group("/api/auth")
+	{
+		auth.POST("/register", register)
+		auth.POST("/login", login)
+	}
+
+	// API routes, scoped to the authenticated user
+	api := r.Group("/api", requireAuth)
 	{
+		api.POST("/auth/logout", logout)
+		api.GET("/auth/me", getCurrentUser)
 		api.GET("/meals", getMeals)
 		api.GET("/meals/:id", getMeal)
 		api.POST("/meals", createMeal)
 		api.PUT("/meals/:id", updateMeal)
 		api.DELETE("/meals/:id", deleteMeal)
+		api.POST("/meals/:id/save-as-template", saveMealAsTemplate)
 		api.GET("/ingredients", getIngredients)
 		api.POST("/ingredients", createIngredient)
 		api.GET("/ingredient-templates", getIngredientTemplates)
+		api.GET("/ingredient-templates/search", getIngredientTemplateSearch)
 		api.POST("/ingredient-templates", createIngredientTemplate)
 		api.PUT("/ingredient-templates/:id", updateIngredientTemplate)
 		api.DELETE("/ingredient-templates/:id", deleteIngredientTemplate)
+		api.GET("/ingredient-templates/:id/usage", getIngredientTemplateUsage)
+		api.GET("/ingredient-templates/:id/portions", getIngredientPortions)
+		api.POST("/ingredient-templates/:id/portions", createIngredientPortion)
+		api.PUT("/ingredient-templates/:id/portions/:portionId", updateIngredientPortion)
+		api.DELETE("/ingredient-templates/:id/portions/:portionId", deleteIngredientPortion)
+
+		api.GET("/products/barcode/:ean", getProductByBarcode)
+		api.POST("/products/barcode/:ean/ingredient-template", createIngredientTemplateFromProduct)
+		api.GET("/reference-foods", getReferenceFoods)
+		api.GET("/reference-foods/:id", getReferenceFood)
+		api.POST("/reference-foods/:id/ingredient-template", createIngredientTemplateFromReferenceFood)
+		api.GET("/recipes", getRecipes)
+		api.GET("/recipes/:id", getRecipe)
+		api.POST("/recipes", createRecipe)
+		api.PUT("/recipes/:id", updateRecipe)
+		api.DELETE("/recipes/:id", deleteRecipe)
+		api.POST("/recipes/:id/log", logRecipe)
 		api.GET("/meal-templates", getMealTemplates)
 		api.GET("/meal-templates/:id", getMealTemplate)
 		api.POST("/meal-templates", createMealTemplate)
 		api.PUT("/meal-templates/:id", updateMealTemplate)
 		api.DELETE("/meal-templates/:id", deleteMealTemplate)
+		api.POST("/meal-templates/:id/log", logMealTemplate)
 		api.GET("/daily-targets", getDailyTargets)
+		api.GET("/daily-targets/timeline", getDailyTargetsTimeline)
 		api.POST("/daily-targets", createDailyTargets)
 		api.PUT("/daily-targets/:id", updateDailyTargets)
 		api.DELETE("/daily-targets/:id", deleteDailyTargets)
-	}
-
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
+		api.GET("/target-profiles", getTargetProfiles)
+		api.POST("/target-profiles", createTargetProfile)
+		api.PUT("/target-profiles/:id", updateTargetProfile)
+		api.DELETE("/target-profiles/:id", deleteTargetProfile)
+		api.GET("/target-schedule", getTargetSchedule)
+		api.PUT("/target-schedule", updateTargetSchedule)
+		api.GET("/target-overrides", getTargetOverrides)
+		api.PUT("/target-overrides/:date", setTargetOverride)
+		api.DELETE("/target-overrides/:date", deleteTargetOverride)
+		api.GET("/measurements", getMeasurements)
+		api.GET("/measurements/trend", getWeightTrend)
+		api.GET("/measurements/:id", getMeasurement)
+		api.POST("/measurements", createMeasurement)
+		api.PUT("/measurements/:id", updateMeasurement)
+		api.DELETE("/measurements/:id", deleteMeasurement)
+		api.GET("/insights/tdee", getTDEEEstimate)
+		api.POST("/insights/tdee/apply", applyTDEETarget)
+		api.GET("/reports", getReport)
+		api.GET("/export/meals.csv", exportMealsCSV)
+		api.POST("/import/meals", importMeals)
+		api.GET("/backup", getBackup)
+		api.POST("/restore", restoreBackup)
+		api.GET("/summary/daily", getDailySummary)
+	}
+
+	return r
+}
+
+// Sortable columns for GET /api/meals
+var mealSortColumns = map[string]string{
+	"datetime": "m.datetime",
+	"name":     "m.name",
+}
+
+const (
+	defaultMealPageSize = 50
+	maxMealPageSize     = 500
+)
 
-	log.Printf("Server starting on port %s", port)
-	if err := r.Run(":" + port); err != nil {
-		log.Fatal(err)
-	}
+// mealQuery holds the filtering, sorting and pagination options for listing meals
+type mealQuery struct {
+	From     string // inclusive lower bound on datetime
+	To       string // exclusive upper bound on datetime
+	SortBy   string
+	Desc     bool
+	Page     int
+	PageSize int // 0 means no limit
 }
 
-func initDB() error {
-	// Create meals table
-	_, err := db.Exec(`
-		CREATE TABLE IF NOT EXISTS meals (
-			id SERIAL PRIMARY KEY,
-			name VARCHAR(255) NOT NULL,
-			datetime TIMESTAMP NOT NULL
-		)
-	`)
-	if err != nil {
-		return err
-	}
-
-	// Create ingredients table
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS ingredients (
-			id SERIAL PRIMARY KEY,
-			name VARCHAR(255) NOT NULL,
-			quantity DECIMAL(8,2) NOT NULL DEFAULT 1,
-			carbs DECIMAL(8,2) NOT NULL DEFAULT 0,
-			fat DECIMAL(8,2) NOT NULL DEFAULT 0,
-			protein DECIMAL(8,2) NOT NULL DEFAULT 0,
-			kcal DECIMAL(8,2) NOT NULL DEFAULT 0,
-			macro_unit VARCHAR(20) NOT NULL DEFAULT 'per_unit'
-		)
-	`)
-	if err != nil {
-		return err
-	}
+// parseMealQuery reads from, to, sort, order, page and pageSize from the query string
+func parseMealQuery(c *gin.Context) (mealQuery, error) {
+	q := mealQuery{SortBy: mealSortColumns["datetime"], Desc: true}
 
-	// Create meal_ingredients junction table
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS meal_ingredients (
-			meal_id INTEGER REFERENCES meals(id) ON DELETE CASCADE,
-			ingredient_id INTEGER REFERENCES ingredients(id) ON DELETE CASCADE,
-			PRIMARY KEY (meal_id, ingredient_id)
-		)
-	`)
-	if err != nil {
-		return err
-	}
-
-	// Create ingredient_templates table
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS ingredient_templates (
-			id SERIAL PRIMARY KEY,
-			name VARCHAR(255) NOT NULL UNIQUE,
-			carbs DECIMAL(8,2) NOT NULL DEFAULT 0,
-			fat DECIMAL(8,2) NOT NULL DEFAULT 0,
-			protein DECIMAL(8,2) NOT NULL DEFAULT 0,
-			kcal DECIMAL(8,2) NOT NULL DEFAULT 0,
-			macro_unit VARCHAR(20) NOT NULL DEFAULT 'per_unit',
-			default_quantity DECIMAL(8,2) DEFAULT 1,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		)
-	`)
-	if err != nil {
-		return err
-	}
-
-	// Create meal_templates table
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS meal_templates (
-			id SERIAL PRIMARY KEY,
-			name VARCHAR(255) NOT NULL,
-			description TEXT,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		)
-	`)
-	if err != nil {
-		return err
-	}
-
-	// Create meal_template_ingredients junction table
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS meal_template_ingredients (
-			meal_template_id INTEGER REFERENCES meal_templates(id) ON DELETE CASCADE,
-			ingredient_template_id INTEGER REFERENCES ingredient_templates(id) ON DELETE CASCADE,
-			quantity DECIMAL(8,2) NOT NULL DEFAULT 1,
-			PRIMARY KEY (meal_template_id, ingredient_template_id)
-		)
-	`)
-	if err != nil {
-		return err
-	}
-
-	// Add check constraint for macro_unit in ingredient_templates
-	_, err = db.Exec(`
-		DO $$
-		BEGIN
-			IF NOT EXISTS (SELECT 1 FROM information_schema.table_constraints WHERE constraint_name = 'check_ingredient_templates_macro_unit') THEN
-				ALTER TABLE ingredient_templates ADD CONSTRAINT check_ingredient_templates_macro_unit CHECK (macro_unit IN ('per_unit', 'per_100g'));
-			END IF;
-		END $$;
-	`)
-	if err != nil {
-		return err
-	}
-
-	// Create daily_targets table
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS daily_targets (
-			id SERIAL PRIMARY KEY,
-			carbs_min DECIMAL(8,2),
-			carbs_max DECIMAL(8,2),
-			fat_min DECIMAL(8,2),
-			fat_max DECIMAL(8,2),
-			protein_min DECIMAL(8,2),
-			protein_max DECIMAL(8,2),
-			kcal_min DECIMAL(8,2),
-			kcal_max DECIMAL(8,2),
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		)
-	`)
-	if err != nil {
-		return err
-	}
-
-	// Insert default ingredient templates if table is empty
-	var count int
-	err = db.QueryRow("SELECT COUNT(*) FROM ingredient_templates").Scan(&count)
-	if err != nil {
-		return err
-	}
-
-	if count == 0 {
-		_, err = db.Exec(`
-			INSERT INTO ingredient_templates (name, carbs, fat, protein, kcal, macro_unit, default_quantity) VALUES
-				('Chicken Breast', 0, 3.6, 31, 165, 'per_100g', 150),
-				('Brown Rice', 23, 0.9, 2.7, 111, 'per_100g', 100),
-				('Broccoli', 7, 0.4, 2.8, 34, 'per_100g', 100),
-				('Salmon', 0, 13, 20, 208, 'per_100g', 150),
-				('Sweet Potato', 20, 0.1, 1.6, 86, 'per_100g', 150),
-				('Eggs', 1.1, 5.3, 6.3, 74, 'per_unit', 1),
-				('Greek Yogurt', 3.6, 0.4, 10, 59, 'per_100g', 100),
-				('Oatmeal', 12, 1.8, 2.4, 68, 'per_100g', 200),
-				('Banana', 23, 0.3, 1.1, 89, 'per_unit', 1),
-				('Almonds', 6, 49, 21, 579, 'per_100g', 30)
-		`)
+	if from := c.Query("from"); from != "" {
+		t, _, err := parseDateTimeParam(from)
 		if err != nil {
-			return err
+			return q, fmt.Errorf("invalid from: %w", err)
 		}
+		q.From = t.Format(timestampLayout)
 	}
-
-	return nil
-}
-
-func getMeals(c *gin.Context) {
-	rows, err := db.Query(`
-		SELECT m.id, m.name, m.datetime, 
-		       i.id, i.name, i.quantity, i.carbs, i.fat, i.protein, i.kcal, i.macro_unit
-		FROM meals m
-		LEFT JOIN meal_ingredients mi ON m.id = mi.meal_id
-		LEFT JOIN ingredients i ON mi.ingredient_id = i.id
-		ORDER BY m.datetime DESC
-	`)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	if to := c.Query("to"); to != "" {
+		t, dateOnly, err := parseDateTimeParam(to)
+		if err != nil {
+			return q, fmt.Errorf("invalid to: %w", err)
+		}
+		// A bare date includes the whole of that day
+		if dateOnly {
+			t = t.AddDate(0, 0, 1)
+		}
+		q.To = t.Format(timestampLayout)
 	}
-	defer rows.Close()
-
-	mealsMap := make(map[int]*Meal)
-	for rows.Next() {
-		var mealID int
-		var mealName, mealDateTime string
-		var ingredientID sql.NullInt64
-		var ingredientName sql.NullString
-		var quantity, carbs, fat, protein, kcal sql.NullFloat64
-		var macroUnit sql.NullString
 
-		err := rows.Scan(&mealID, &mealName, &mealDateTime, &ingredientID, &ingredientName, &quantity, &carbs, &fat, &protein, &kcal, &macroUnit)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
+	if sortBy := c.Query("sort"); sortBy != "" {
+		column, ok := mealSortColumns[sortBy]
+		if !ok {
+			return q, fmt.Errorf("invalid sort %q, expected datetime or name", sortBy)
 		}
+		q.SortBy = column
+	}
+	switch c.DefaultQuery("order", "desc") {
+	case "asc":
+		q.Desc = false
+	case "desc":
+		q.Desc = true
+	default:
+		return q, fmt.Errorf("invalid order %q, expected asc or desc", c.Query("order"))
+	}
 
-		meal, exists := mealsMap[mealID]
-		if !exists {
-			meal = &Meal{
-				ID:          mealID,
-				Name:        mealName,
-				DateTime:    mealDateTime,
-				Ingredients: []Ingredient{},
+	pageParam, pageSizeParam := c.Query("page"), c.Query("pageSize")
+	if pageParam != "" || pageSizeParam != "" {
+		q.Page = 1
+		q.PageSize = defaultMealPageSize
+		if pageParam != "" {
+			page, err := strconv.Atoi(pageParam)
+			if err != nil || page < 1 {
+				return q, fmt.Errorf("invalid page %q", pageParam)
 			}
-			mealsMap[mealID] = meal
+			q.Page = page
 		}
-
-		if ingredientID.Valid {
-			ingredient := Ingredient{
-				ID:        int(ingredientID.Int64),
-				Name:      ingredientName.String,
-				Quantity:  quantity.Float64,
-				Carbs:     carbs.Float64,
-				Fat:       fat.Float64,
-				Protein:   protein.Float64,
-				Kcal:      kcal.Float64,
-				MacroUnit: macroUnit.String,
+		if pageSizeParam != "" {
+			pageSize, err := strconv.Atoi(pageSizeParam)
+			if err != nil || pageSize < 1 || pageSize > maxMealPageSize {
+				return q, fmt.Errorf("invalid pageSize %q, expected 1-%d", pageSizeParam, maxMealPageSize)
 			}
-			meal.Ingredients = append(meal.Ingredients, ingredient)
+			q.PageSize = pageSize
 		}
 	}
 
-	meals := make([]Meal, 0, len(mealsMap))
-	for _, meal := range mealsMap {
-		meals = append(meals, *meal)
-	}
-
-	c.JSON(http.StatusOK, meals)
+	return q, nil
 }
 
-func getMeal(c *gin.Context) {
-	id := c.Param("id")
-	
-	rows, err := db.Query(`
-		SELECT m.id, m.name, m.datetime, 
-		       i.id, i.name, i.quantity, i.carbs, i.fat, i.protein, i.kcal, i.macro_unit
-		FROM meals m
-		LEFT JOIN meal_ingredients mi ON m.id = mi.meal_id
-		LEFT JOIN ingredients i ON mi.ingredient_id = i.id
-		WHERE m.id = $1
-	`, id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+// parseDateTimeParam accepts a date (YYYY-MM-DD), a datetime-local value or an RFC 3339 timestamp.
+// The returned bool reports whether only a date was given.
+func parseDateTimeParam(value string) (time.Time, bool, error) {
+	if t, err := time.Parse(dateLayout, value); err == nil {
+		return t, true, nil
 	}
-	defer rows.Close()
-
-	var meal *Meal
-	for rows.Next() {
-		var mealID int
-		var mealName, mealDateTime string
-		var ingredientID sql.NullInt64
-		var ingredientName sql.NullString
-		var quantity, carbs, fat, protein, kcal sql.NullFloat64
-		var macroUnit sql.NullString
-
-		err := rows.Scan(&mealID, &mealName, &mealDateTime, &ingredientID, &ingredientName, &quantity, &carbs, &fat, &protein, &kcal, &macroUnit)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-
-		if meal == nil {
-			meal = &Meal{
-				ID:          mealID,
-				Name:        mealName,
-				DateTime:    mealDateTime,
-				Ingredients: []Ingredient{},
-			}
-		}
-
-		if ingredientID.Valid {
-			ingredient := Ingredient{
-				ID:        int(ingredientID.Int64),
-				Name:      ingredientName.String,
-				Quantity:  quantity.Float64,
-				Carbs:     carbs.Float64,
-				Fat:       fat.Float64,
-				Protein:   protein.Float64,
-				Kcal:      kcal.Float64,
-				MacroUnit: macroUnit.String,
-			}
-			meal.Ingredients = append(meal.Ingredients, ingredient)
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04", timestampLayout} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, false, nil
 		}
 	}
+	return time.Time{}, false, fmt.Errorf("unrecognised datetime %q", value)
+}
 
-	if meal == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Meal not found"})
-		return
+// Helper function to read a numeric :id path parameter, responding with 400 if it is not one
+func parseIDParam(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid ID %q", c.Param("id"))})
+		return 0, false
 	}
+	return id, true
+}
 
-	c.JSON(http.StatusOK, meal)
+// Helper function to respond with the status matching a store error
+func respondStoreError(c *gin.Context, err error, notFoundMessage string) {
+	switch {
+	case errors.Is(err, errNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": notFoundMessage})
+	case errors.Is(err, errInvalidReference):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, errDuplicate):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
 }
 
-func createMeal(c *gin.Context) {
+// Helper function to bind a meal from the request body and normalise its datetime for storage
+func bindMeal(c *gin.Context) (Meal, bool) {
 	var meal Meal
 	if err := c.ShouldBindJSON(&meal); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+		return meal, false
 	}
-
-	tx, err := db.Begin()
+	t, _, err := parseDateTimeParam(meal.DateTime)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	defer tx.Rollback()
-
-	// Insert meal
-	var mealID int
-	err = tx.QueryRow("INSERT INTO meals (name, datetime) VALUES ($1, $2) RETURNING id", meal.Name, meal.DateTime).Scan(&mealID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid datetime: %v", err)})
+		return meal, false
 	}
+	meal.DateTime = t.Format(timestampLayout)
+	return meal, prepareIngredients(c, meal.Ingredients)
+}
 
-	// Insert ingredients and link them to meal
-	for _, ingredient := range meal.Ingredients {
-		var ingredientID int
-		err = tx.QueryRow(`
-			INSERT INTO ingredients (name, quantity, carbs, fat, protein, kcal, macro_unit) 
-			VALUES ($1, $2, $3, $4, $5, $6, $7) 
-			RETURNING id
-		`, ingredient.Name, ingredient.Quantity, ingredient.Carbs, ingredient.Fat, ingredient.Protein, ingredient.Kcal, ingredient.MacroUnit).Scan(&ingredientID)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
+// Helper function to resolve ingredients given as portions or recipes and validate them,
+// responding with an error if one is invalid
+func prepareIngredients(c *gin.Context, ingredients []Ingredient) bool {
+	for i := range ingredients {
+		var err error
+		switch {
+		case ingredients[i].RecipeID != nil:
+			err = resolveRecipe(currentUserID(c), &ingredients[i])
+		case ingredients[i].Portion != "":
+			err = resolvePortion(currentUserID(c), &ingredients[i])
 		}
-
-		_, err = tx.Exec("INSERT INTO meal_ingredients (meal_id, ingredient_id) VALUES ($1, $2)", mealID, ingredientID)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
+			respondStoreError(c, err, "Ingredient template not found")
+			return false
+		}
+		if err := ingredients[i].validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("ingredient %q: %v", ingredients[i].Name, err)})
+			return false
 		}
 	}
+	return true
+}
+
+func getMeals(c *gin.Context) {
+	q, err := parseMealQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	if err = tx.Commit(); err != nil {
+	meals, total, err := store.ListMeals(currentUserID(c), q)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	meal.ID = mealID
-	c.JSON(http.StatusCreated, meal)
+	c.Header("X-Total-Count", strconv.Itoa(total))
+	c.JSON(http.StatusOK, meals)
 }
 
-func updateMeal(c *gin.Context) {
-	id := c.Param("id")
-	var meal Meal
-	if err := c.ShouldBindJSON(&meal); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+func getMeal(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
 		return
 	}
 
-	tx, err := db.Begin()
+	meal, err := store.GetMeal(currentUserID(c), id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondStoreError(c, err, "Meal not found")
 		return
 	}
-	defer tx.Rollback()
 
-	// Update meal
-	_, err = tx.Exec("UPDATE meals SET name = $1, datetime = $2 WHERE id = $3", meal.Name, meal.DateTime, id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	c.JSON(http.StatusOK, meal)
+}
+
+func createMeal(c *gin.Context) {
+	meal, ok := bindMeal(c)
+	if !ok {
 		return
 	}
 
-	// Delete existing meal-ingredient relationships
-	_, err = tx.Exec("DELETE FROM meal_ingredients WHERE meal_id = $1", id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := store.CreateMeal(currentUserID(c), &meal); err != nil {
+		respondStoreError(c, err, "Meal not found")
 		return
 	}
 
-	// Insert new ingredients and link them to meal
-	for _, ingredient := range meal.Ingredients {
-		var ingredientID int
-		err = tx.QueryRow(`
-			INSERT INTO ingredients (name, quantity, carbs, fat, protein, kcal, macro_unit) 
-			VALUES ($1, $2, $3, $4, $5, $6, $7) 
-			RETURNING id
-		`, ingredient.Name, ingredient.Quantity, ingredient.Carbs, ingredient.Fat, ingredient.Protein, ingredient.Kcal, ingredient.MacroUnit).Scan(&ingredientID)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
+	meal.computeTotals()
+	c.JSON(http.StatusCreated, meal)
+}
 
-		_, err = tx.Exec("INSERT INTO meal_ingredients (meal_id, ingredient_id) VALUES ($1, $2)", id, ingredientID)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
+func updateMeal(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+	meal, ok := bindMeal(c)
+	if !ok {
+		return
 	}
 
-	if err = tx.Commit(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := store.UpdateMeal(currentUserID(c), id, &meal); err != nil {
+		respondStoreError(c, err, "Meal not found")
 		return
 	}
 
+	meal.computeTotals()
 	c.JSON(http.StatusOK, meal)
 }
 
 func deleteMeal(c *gin.Context) {
-	id := c.Param("id")
-	
-	_, err := db.Exec("DELETE FROM meals WHERE id = $1", id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := store.DeleteMeal(currentUserID(c), id); err != nil {
+		respondStoreError(c, err, "Meal not found")
 		return
 	}
 
@@ -596,24 +538,15 @@ func deleteMeal(c *gin.Context) {
 }
 
 func getIngredients(c *gin.Context) {
-	rows, err := db.Query("SELECT id, name, quantity, carbs, fat, protein, kcal, macro_unit FROM ingredients ORDER BY name")
+	ingredients, err := store.ListIngredients(currentUserID(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	defer rows.Close()
 
-	var ingredients []Ingredient
-	for rows.Next() {
-		var ingredient Ingredient
-		err := rows.Scan(&ingredient.ID, &ingredient.Name, &ingredient.Quantity, &ingredient.Carbs, &ingredient.Fat, &ingredient.Protein, &ingredient.Kcal, &ingredient.MacroUnit)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		ingredients = append(ingredients, ingredient)
+	for i := range ingredients {
+		ingredients[i].computeTotals()
 	}
-
 	c.JSON(http.StatusOK, ingredients)
 }
 
@@ -623,48 +556,27 @@ func createIngredient(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := ingredient.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	var id int
-	err := db.QueryRow(`
-		INSERT INTO ingredients (name, quantity, carbs, fat, protein, kcal, macro_unit) 
-		VALUES ($1, $2, $3, $4, $5, $6, $7) 
-		RETURNING id
-	`, ingredient.Name, ingredient.Quantity, ingredient.Carbs, ingredient.Fat, ingredient.Protein, ingredient.Kcal, ingredient.MacroUnit).Scan(&id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := store.CreateIngredient(currentUserID(c), &ingredient); err != nil {
+		respondStoreError(c, err, "Ingredient template not found")
 		return
 	}
 
-	ingredient.ID = id
+	ingredient.computeTotals()
 	c.JSON(http.StatusCreated, ingredient)
 }
 
 // Ingredient Template handlers
 func getIngredientTemplates(c *gin.Context) {
-	rows, err := db.Query("SELECT id, name, carbs, fat, protein, kcal, macro_unit, default_quantity, created_at, updated_at FROM ingredient_templates ORDER BY name")
+	templates, err := store.ListIngredientTemplates(currentUserID(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	defer rows.Close()
-
-	var templates []IngredientTemplate
-	for rows.Next() {
-		var template IngredientTemplate
-		var createdAt, updatedAt sql.NullString
-		err := rows.Scan(&template.ID, &template.Name, &template.Carbs, &template.Fat, &template.Protein, &template.Kcal, &template.MacroUnit, &template.DefaultQuantity, &createdAt, &updatedAt)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		if createdAt.Valid {
-			template.CreatedAt = createdAt.String
-		}
-		if updatedAt.Valid {
-			template.UpdatedAt = updatedAt.String
-		}
-		templates = append(templates, template)
-	}
 
 	c.JSON(http.StatusOK, templates)
 }
@@ -675,37 +587,36 @@ func createIngredientTemplate(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := template.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	var id int
-	err := db.QueryRow(`
-		INSERT INTO ingredient_templates (name, carbs, fat, protein, kcal, macro_unit, default_quantity) 
-		VALUES ($1, $2, $3, $4, $5, $6, $7) 
-		RETURNING id
-	`, template.Name, template.Carbs, template.Fat, template.Protein, template.Kcal, template.MacroUnit, template.DefaultQuantity).Scan(&id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := store.CreateIngredientTemplate(currentUserID(c), &template); err != nil {
+		respondStoreError(c, err, "Ingredient template not found")
 		return
 	}
 
-	template.ID = id
 	c.JSON(http.StatusCreated, template)
 }
 
 func updateIngredientTemplate(c *gin.Context) {
-	id := c.Param("id")
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
 	var template IngredientTemplate
 	if err := c.ShouldBindJSON(&template); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := template.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	_, err := db.Exec(`
-		UPDATE ingredient_templates 
-		SET name = $1, carbs = $2, fat = $3, protein = $4, kcal = $5, macro_unit = $6, default_quantity = $7, updated_at = CURRENT_TIMESTAMP
-		WHERE id = $8
-	`, template.Name, template.Carbs, template.Fat, template.Protein, template.Kcal, template.MacroUnit, template.DefaultQuantity, id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := store.UpdateIngredientTemplate(currentUserID(c), id, &template); err != nil {
+		respondStoreError(c, err, "Ingredient template not found")
 		return
 	}
 
@@ -713,11 +624,13 @@ func updateIngredientTemplate(c *gin.Context) {
 }
 
 func deleteIngredientTemplate(c *gin.Context) {
-	id := c.Param("id")
-	
-	_, err := db.Exec("DELETE FROM ingredient_templates WHERE id = $1", id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := store.DeleteIngredientTemplate(currentUserID(c), id); err != nil {
+		respondStoreError(c, err, "Ingredient template not found")
 		return
 	}
 
@@ -726,142 +639,24 @@ func deleteIngredientTemplate(c *gin.Context) {
 
 // Meal Template handlers
 func getMealTemplates(c *gin.Context) {
-	rows, err := db.Query(`
-		SELECT mt.id, mt.name, mt.description, mt.created_at, mt.updated_at,
-		       it.id, it.name, it.carbs, it.fat, it.protein, it.kcal, it.macro_unit,
-		       mti.quantity
-		FROM meal_templates mt
-		LEFT JOIN meal_template_ingredients mti ON mt.id = mti.meal_template_id
-		LEFT JOIN ingredient_templates it ON mti.ingredient_template_id = it.id
-		ORDER BY mt.name, it.name
-	`)
+	templates, err := store.ListMealTemplates(currentUserID(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	defer rows.Close()
-
-	templatesMap := make(map[int]*MealTemplate)
-	for rows.Next() {
-		var templateID int
-		var templateName, templateDescription, createdAt, updatedAt sql.NullString
-		var ingredientID sql.NullInt64
-		var ingredientName sql.NullString
-		var carbs, fat, protein, kcal sql.NullFloat64
-		var macroUnit sql.NullString
-		var quantity sql.NullFloat64
-
-		err := rows.Scan(&templateID, &templateName, &templateDescription, &createdAt, &updatedAt,
-			&ingredientID, &ingredientName, &carbs, &fat, &protein, &kcal, &macroUnit, &quantity)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-
-		template, exists := templatesMap[templateID]
-		if !exists {
-			template = &MealTemplate{
-				ID:          templateID,
-				Name:        templateName.String,
-				Description: templateDescription.String,
-				Ingredients: []IngredientTemplate{},
-				CreatedAt:   createdAt.String,
-				UpdatedAt:   updatedAt.String,
-			}
-			templatesMap[templateID] = template
-		}
-
-		if ingredientID.Valid {
-			ingredient := IngredientTemplate{
-				ID:         int(ingredientID.Int64),
-				Name:       ingredientName.String,
-				Carbs:      carbs.Float64,
-				Fat:        fat.Float64,
-				Protein:    protein.Float64,
-				Kcal:       kcal.Float64,
-				MacroUnit:  macroUnit.String,
-				Quantity:   quantity.Float64,
-				CreatedAt:  "",
-				UpdatedAt:  "",
-			}
-			template.Ingredients = append(template.Ingredients, ingredient)
-		}
-	}
-
-	templates := make([]MealTemplate, 0, len(templatesMap))
-	for _, template := range templatesMap {
-		templates = append(templates, *template)
-	}
 
 	c.JSON(http.StatusOK, templates)
 }
 
 func getMealTemplate(c *gin.Context) {
-	id := c.Param("id")
-	
-	rows, err := db.Query(`
-		SELECT mt.id, mt.name, mt.description, mt.created_at, mt.updated_at,
-		       it.id, it.name, it.carbs, it.fat, it.protein, it.kcal, it.macro_unit,
-		       mti.quantity
-		FROM meal_templates mt
-		LEFT JOIN meal_template_ingredients mti ON mt.id = mti.meal_template_id
-		LEFT JOIN ingredient_templates it ON mti.ingredient_template_id = it.id
-		WHERE mt.id = $1
-		ORDER BY it.name
-	`, id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	id, ok := parseIDParam(c)
+	if !ok {
 		return
 	}
-	defer rows.Close()
-
-	var template *MealTemplate
-	for rows.Next() {
-		var templateID int
-		var templateName, templateDescription, createdAt, updatedAt sql.NullString
-		var ingredientID sql.NullInt64
-		var ingredientName sql.NullString
-		var carbs, fat, protein, kcal sql.NullFloat64
-		var macroUnit sql.NullString
-		var quantity sql.NullFloat64
-
-		err := rows.Scan(&templateID, &templateName, &templateDescription, &createdAt, &updatedAt,
-			&ingredientID, &ingredientName, &carbs, &fat, &protein, &kcal, &macroUnit, &quantity)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
 
-		if template == nil {
-			template = &MealTemplate{
-				ID:          templateID,
-				Name:        templateName.String,
-				Description: templateDescription.String,
-				Ingredients: []IngredientTemplate{},
-				CreatedAt:   createdAt.String,
-				UpdatedAt:   updatedAt.String,
-			}
-		}
-
-		if ingredientID.Valid {
-			ingredient := IngredientTemplate{
-				ID:         int(ingredientID.Int64),
-				Name:       ingredientName.String,
-				Carbs:      carbs.Float64,
-				Fat:        fat.Float64,
-				Protein:    protein.Float64,
-				Kcal:       kcal.Float64,
-				MacroUnit:  macroUnit.String,
-				Quantity:   quantity.Float64,
-				CreatedAt:  "",
-				UpdatedAt:  "",
-			}
-			template.Ingredients = append(template.Ingredients, ingredient)
-		}
-	}
-
-	if template == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Meal template not found"})
+	template, err := store.GetMealTemplate(currentUserID(c), id)
+	if err != nil {
+		respondStoreError(c, err, "Meal template not found")
 		return
 	}
 
@@ -875,103 +670,54 @@ func createMealTemplate(c *gin.Context) {
 		return
 	}
 
-	tx, err := db.Begin()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := store.CreateMealTemplate(currentUserID(c), &template); err != nil {
+		respondStoreError(c, err, "Meal template not found")
 		return
 	}
-	defer tx.Rollback()
 
-	// Insert meal template
-	var templateID int
-	err = tx.QueryRow("INSERT INTO meal_templates (name, description) VALUES ($1, $2) RETURNING id", 
-		template.Name, template.Description).Scan(&templateID)
+	// Respond with the stored template so totals reflect the ingredient templates' macros
+	created, err := store.GetMealTemplate(currentUserID(c), template.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Insert meal template ingredients
-	for _, ingredient := range template.Ingredients {
-		quantity := ingredient.Quantity
-		if quantity == 0 {
-			quantity = 1.0 // Default to 1 if not specified
-		}
-		_, err = tx.Exec("INSERT INTO meal_template_ingredients (meal_template_id, ingredient_template_id, quantity) VALUES ($1, $2, $3)", 
-			templateID, ingredient.ID, quantity)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-	}
-
-	if err = tx.Commit(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	template.ID = templateID
-	c.JSON(http.StatusCreated, template)
+	c.JSON(http.StatusCreated, created)
 }
 
 func updateMealTemplate(c *gin.Context) {
-	id := c.Param("id")
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
 	var template MealTemplate
 	if err := c.ShouldBindJSON(&template); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	tx, err := db.Begin()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	defer tx.Rollback()
-
-	// Update meal template
-	_, err = tx.Exec("UPDATE meal_templates SET name = $1, description = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $3", 
-		template.Name, template.Description, id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := store.UpdateMealTemplate(currentUserID(c), id, &template); err != nil {
+		respondStoreError(c, err, "Meal template not found")
 		return
 	}
 
-	// Delete existing meal template ingredients
-	_, err = tx.Exec("DELETE FROM meal_template_ingredients WHERE meal_template_id = $1", id)
+	updated, err := store.GetMealTemplate(currentUserID(c), id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondStoreError(c, err, "Meal template not found")
 		return
 	}
 
-	// Insert new meal template ingredients
-	for _, ingredient := range template.Ingredients {
-		quantity := ingredient.Quantity
-		if quantity == 0 {
-			quantity = 1.0 // Default to 1 if not specified
-		}
-		_, err = tx.Exec("INSERT INTO meal_template_ingredients (meal_template_id, ingredient_template_id, quantity) VALUES ($1, $2, $3)", 
-			id, ingredient.ID, quantity)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-	}
+	c.JSON(http.StatusOK, updated)
+}
 
-	if err = tx.Commit(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+func deleteMealTemplate(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
 		return
 	}
 
-	c.JSON(http.StatusOK, template)
-}
-
-func deleteMealTemplate(c *gin.Context) {
-	id := c.Param("id")
-	
-	_, err := db.Exec("DELETE FROM meal_templates WHERE id = $1", id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := store.DeleteMealTemplate(currentUserID(c), id); err != nil {
+		respondStoreError(c, err, "Meal template not found")
 		return
 	}
 
@@ -979,131 +725,120 @@ func deleteMealTemplate(c *gin.Context) {
 }
 
 // Daily Targets handlers
+
+// TargetPeriod is a daily targets row with the day its successor took over (exclusive), if any
+type TargetPeriod struct {
+	DailyTargets
+	EffectiveUntil string `json:"effectiveUntil,omitempty"`
+}
+
+// getDailyTargets returns the targets in effect on ?date=YYYY-MM-DD, defaulting to today
 func getDailyTargets(c *gin.Context) {
-	rows, err := db.Query("SELECT id, carbs_min, carbs_max, fat_min, fat_max, protein_min, protein_max, kcal_min, kcal_max, created_at, updated_at FROM daily_targets ORDER BY id DESC LIMIT 1")
+	date := time.Now().Format(dateLayout)
+	if dateParam := c.Query("date"); dateParam != "" {
+		if _, err := time.Parse(dateLayout, dateParam); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date, expected YYYY-MM-DD"})
+			return
+		}
+		date = dateParam
+	}
+
+	targets, err := store.GetDailyTargets(currentUserID(c), date)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	defer rows.Close()
-
-	if !rows.Next() {
+	if targets == nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Daily targets not found"})
 		return
 	}
 
-	var targets DailyTargets
-	var carbsMin, carbsMax, fatMin, fatMax, proteinMin, proteinMax, kcalMin, kcalMax sql.NullFloat64
-	var createdAt, updatedAt sql.NullString
+	c.JSON(http.StatusOK, targets)
+}
 
-	err = rows.Scan(&targets.ID, &carbsMin, &carbsMax, &fatMin, &fatMax, &proteinMin, &proteinMax, &kcalMin, &kcalMax, &createdAt, &updatedAt)
+// getDailyTargetsTimeline lists every set of daily targets and the period it was in effect
+func getDailyTargetsTimeline(c *gin.Context) {
+	list, err := store.ListDailyTargets(currentUserID(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Convert to MacroTarget structs
-	if carbsMin.Valid || carbsMax.Valid {
-		var min, max *float64
-		if carbsMin.Valid {
-			min = &carbsMin.Float64
-		}
-		if carbsMax.Valid {
-			max = &carbsMax.Float64
+	timeline := make([]TargetPeriod, len(list))
+	for i, targets := range list {
+		timeline[i].DailyTargets = targets
+		if i+1 < len(list) {
+			timeline[i].EffectiveUntil = list[i+1].EffectiveFrom
 		}
-		targets.Carbs = &MacroTarget{Min: min, Max: max}
 	}
-	if fatMin.Valid || fatMax.Valid {
-		var min, max *float64
-		if fatMin.Valid {
-			min = &fatMin.Float64
-		}
-		if fatMax.Valid {
-			max = &fatMax.Float64
-		}
-		targets.Fat = &MacroTarget{Min: min, Max: max}
+
+	c.JSON(http.StatusOK, timeline)
+}
+
+// Helper function to bind daily targets from the request body, validating effectiveFrom and
+// resolving relative targets to grams. g_per_kg targets default to the latest logged weight.
+func bindDailyTargets(c *gin.Context) (DailyTargets, bool) {
+	var targets DailyTargets
+	if err := c.ShouldBindJSON(&targets); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return targets, false
 	}
-	if proteinMin.Valid || proteinMax.Valid {
-		var min, max *float64
-		if proteinMin.Valid {
-			min = &proteinMin.Float64
-		}
-		if proteinMax.Valid {
-			max = &proteinMax.Float64
+	return targets, validateDailyTargets(c, &targets)
+}
+
+// Helper function to validate effectiveFrom and resolve relative targets to grams, shared with
+// target profiles
+func validateDailyTargets(c *gin.Context, targets *DailyTargets) bool {
+	if targets.EffectiveFrom != "" {
+		if _, err := time.Parse(dateLayout, targets.EffectiveFrom); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid effectiveFrom, expected YYYY-MM-DD"})
+			return false
 		}
-		targets.Protein = &MacroTarget{Min: min, Max: max}
 	}
-	if kcalMin.Valid || kcalMax.Valid {
-		var min, max *float64
-		if kcalMin.Valid {
-			min = &kcalMin.Float64
-		}
-		if kcalMax.Valid {
-			max = &kcalMax.Float64
+	if targets.Mode == targetModeGramsPerKg && targets.BodyWeight == nil {
+		weight, err := latestWeight(currentUserID(c), targets.EffectiveFrom)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return false
 		}
-		targets.Kcal = &MacroTarget{Min: min, Max: max}
-	}
-
-	if createdAt.Valid {
-		targets.CreatedAt = createdAt.String
+		targets.BodyWeight = weight
 	}
-	if updatedAt.Valid {
-		targets.UpdatedAt = updatedAt.String
+	if err := resolveTargetMode(targets); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
 	}
-
-	c.JSON(http.StatusOK, targets)
+	return true
 }
 
 func createDailyTargets(c *gin.Context) {
-	var targets DailyTargets
-	if err := c.ShouldBindJSON(&targets); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	targets, ok := bindDailyTargets(c)
+	if !ok {
 		return
 	}
+	if targets.EffectiveFrom == "" {
+		targets.EffectiveFrom = time.Now().Format(dateLayout)
+	}
 
-	var id int
-	err := db.QueryRow(`
-		INSERT INTO daily_targets (carbs_min, carbs_max, fat_min, fat_max, protein_min, protein_max, kcal_min, kcal_max) 
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) 
-		RETURNING id
-	`, 
-		getMacroTargetFloat(targets.Carbs, "min"), getMacroTargetFloat(targets.Carbs, "max"), 
-		getMacroTargetFloat(targets.Fat, "min"), getMacroTargetFloat(targets.Fat, "max"), 
-		getMacroTargetFloat(targets.Protein, "min"), getMacroTargetFloat(targets.Protein, "max"), 
-		getMacroTargetFloat(targets.Kcal, "min"), getMacroTargetFloat(targets.Kcal, "max"),
-	).Scan(&id)
-	if err != nil {
+	if err := store.CreateDailyTargets(currentUserID(c), &targets); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	targets.ID = id
 	c.JSON(http.StatusCreated, targets)
 }
 
 func updateDailyTargets(c *gin.Context) {
-	id := c.Param("id")
-	var targets DailyTargets
-	if err := c.ShouldBindJSON(&targets); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+	targets, ok := bindDailyTargets(c)
+	if !ok {
 		return
 	}
 
-	_, err := db.Exec(`
-		UPDATE daily_targets 
-		SET carbs_min = $1, carbs_max = $2, fat_min = $3, fat_max = $4, 
-		    protein_min = $5, protein_max = $6, kcal_min = $7, kcal_max = $8, 
-		    updated_at = CURRENT_TIMESTAMP
-		WHERE id = $9
-	`, 
-		getMacroTargetFloat(targets.Carbs, "min"), getMacroTargetFloat(targets.Carbs, "max"), 
-		getMacroTargetFloat(targets.Fat, "min"), getMacroTargetFloat(targets.Fat, "max"), 
-		getMacroTargetFloat(targets.Protein, "min"), getMacroTargetFloat(targets.Protein, "max"), 
-		getMacroTargetFloat(targets.Kcal, "min"), getMacroTargetFloat(targets.Kcal, "max"), 
-		id,
-	)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := store.UpdateDailyTargets(currentUserID(c), id, &targets); err != nil {
+		respondStoreError(c, err, "Daily targets not found")
 		return
 	}
 
@@ -1111,11 +846,13 @@ func updateDailyTargets(c *gin.Context) {
 }
 
 func deleteDailyTargets(c *gin.Context) {
-	id := c.Param("id")
-	
-	_, err := db.Exec("DELETE FROM daily_targets WHERE id = $1", id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := store.DeleteDailyTargets(currentUserID(c), id); err != nil {
+		respondStoreError(c, err, "Daily targets not found")
 		return
 	}
 
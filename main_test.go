@@ -0,0 +1,1682 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+	log.SetOutput(io.Discard)
+}
+
+// testServer is the router backed by a fresh in-memory SQLite store
+type testServer struct {
+	t      *testing.T
+	router *gin.Engine
+	token  string
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	return newTestServerFor(t, "sqlite://:memory:")
+}
+
+// newTestServerFor is newTestServer backed by the database at databaseURL, migrated up
+func newTestServerFor(t *testing.T, databaseURL string) *testServer {
+	t.Helper()
+
+	s, err := openStore(databaseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	if err := s.migrateUp(); err != nil {
+		t.Fatal(err)
+	}
+	store = s
+
+	srv := &testServer{t: t, router: setupRouter()}
+	srv.token = srv.register("test@example.com")
+	return srv
+}
+
+// register creates an account and returns its bearer token
+func (s *testServer) register(email string) string {
+	s.t.Helper()
+	var auth AuthResponse
+	s.decode(s.requestAs("", "POST", "/api/auth/register", `{"email":"`+email+`","password":"password123"}`), http.StatusCreated, &auth)
+	return auth.Token
+}
+
+// request sends a request as the test user. body may be a string (sent as-is) or a value to encode as JSON.
+func (s *testServer) request(method, path string, body interface{}) *httptest.ResponseRecorder {
+	s.t.Helper()
+	return s.requestAs(s.token, method, path, body)
+}
+
+func (s *testServer) requestAs(token, method, path string, body interface{}) *httptest.ResponseRecorder {
+	s.t.Helper()
+	var reader *bytes.Reader
+	switch b := body.(type) {
+	case nil:
+		reader = bytes.NewReader(nil)
+	case string:
+		reader = bytes.NewReader([]byte(b))
+	default:
+		encoded, err := json.Marshal(b)
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+// upload sends a multipart form as the test user, with content as the "file" field
+func (s *testServer) upload(path, content string, fields map[string]string) *httptest.ResponseRecorder {
+	s.t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for name, value := range fields {
+		form.WriteField(name, value)
+	}
+	file, err := form.CreateFormFile("file", "upload.csv")
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	file.Write([]byte(content))
+	form.Close()
+
+	req := httptest.NewRequest("POST", path, &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+s.token)
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+// decode checks the response status and unmarshals the body into v (if non-nil)
+func (s *testServer) decode(w *httptest.ResponseRecorder, status int, v interface{}) {
+	s.t.Helper()
+	if w.Code != status {
+		s.t.Fatalf("expected status %d, got %d: %s", status, w.Code, w.Body.String())
+	}
+	if v != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			s.t.Fatalf("decoding %s: %v", w.Body.String(), err)
+		}
+	}
+}
+
+// expectError checks the response status and that the body is an {"error": ...} object
+func (s *testServer) expectError(w *httptest.ResponseRecorder, status int) {
+	s.t.Helper()
+	var body map[string]string
+	s.decode(w, status, &body)
+	if body["error"] == "" {
+		s.t.Fatalf("expected an error message, got %s", w.Body.String())
+	}
+}
+
+func assertFloat(t *testing.T, name string, got, want float64) {
+	t.Helper()
+	if round2(got) != round2(want) {
+		t.Errorf("%s: expected %v, got %v", name, want, got)
+	}
+}
+
+const lunchJSON = `{
+	"name": "Lunch",
+	"datetime": "2024-05-01T12:30",
+	"ingredients": [
+		{"name": "Rice", "quantity": 150, "carbs": 28, "fat": 0.3, "protein": 2.7, "kcal": 130, "macroUnit": "per_100g"},
+		{"name": "Egg", "quantity": 2, "carbs": 0.6, "fat": 5, "protein": 6, "kcal": 78, "macroUnit": "per_unit"}
+	]
+}`
+
+func TestAuth(t *testing.T) {
+	s := newTestServer(t)
+
+	s.expectError(s.requestAs("", "GET", "/api/meals", nil), http.StatusUnauthorized)
+	s.expectError(s.requestAs("not-a-token", "GET", "/api/meals", nil), http.StatusUnauthorized)
+
+	s.expectError(s.requestAs("", "POST", "/api/auth/register", `{"email":"test@example.com","password":"password123"}`), http.StatusConflict)
+	s.expectError(s.requestAs("", "POST", "/api/auth/register", `{"email":"nope","password":"password123"}`), http.StatusBadRequest)
+	s.expectError(s.requestAs("", "POST", "/api/auth/register", `{"email":"short@example.com","password":"short"}`), http.StatusBadRequest)
+	s.expectError(s.requestAs("", "POST", "/api/auth/register", `{"email":`), http.StatusBadRequest)
+
+	s.expectError(s.requestAs("", "POST", "/api/auth/login", `{"email":"test@example.com","password":"wrong-password"}`), http.StatusUnauthorized)
+	s.expectError(s.requestAs("", "POST", "/api/auth/login", `{"email":"missing@example.com","password":"password123"}`), http.StatusUnauthorized)
+	s.expectError(s.requestAs("", "POST", "/api/auth/login", `not json`), http.StatusBadRequest)
+
+	var auth AuthResponse
+	s.decode(s.requestAs("", "POST", "/api/auth/login", `{"email":" Test@Example.com ","password":"password123"}`), http.StatusOK, &auth)
+
+	var user User
+	s.decode(s.requestAs(auth.Token, "GET", "/api/auth/me", nil), http.StatusOK, &user)
+	if user.Email != "test@example.com" {
+		t.Errorf("expected test@example.com, got %q", user.Email)
+	}
+
+	s.decode(s.requestAs(auth.Token, "POST", "/api/auth/logout", nil), http.StatusOK, nil)
+	s.expectError(s.requestAs(auth.Token, "GET", "/api/auth/me", nil), http.StatusUnauthorized)
+
+	// The original session is unaffected by logging out another one
+	s.decode(s.request("GET", "/api/auth/me", nil), http.StatusOK, nil)
+}
+
+// TestMigrateDown reverts every migration over two accounts' data, which leaves repeated
+// ingredient template names and rows that later tables refer to. Set TEST_POSTGRES_URL to an
+// empty database to run it against Postgres as well.
+func TestMigrateDown(t *testing.T) {
+	databaseURLs := []string{"sqlite://:memory:"}
+	if url := os.Getenv("TEST_POSTGRES_URL"); url != "" {
+		databaseURLs = append(databaseURLs, url)
+	}
+	for _, databaseURL := range databaseURLs {
+		t.Run(strings.SplitN(databaseURL, ":", 2)[0], func(t *testing.T) {
+			s := newTestServerFor(t, databaseURL)
+			other := s.register("other@example.com")
+			for _, token := range []string{s.token, other} {
+				s.decode(s.requestAs(token, "POST", "/api/meals", lunchJSON), http.StatusCreated, nil)
+				s.decode(s.requestAs(token, "POST", "/api/daily-targets", `{"kcal": {"max": 2200}}`), http.StatusCreated, nil)
+				s.decode(s.requestAs(token, "POST", "/api/measurements", `{"date": "2024-05-01", "weight": 80}`), http.StatusCreated, nil)
+				s.decode(s.requestAs(token, "POST", "/api/recipes", `{"name": "Stew", "cookedWeight": 800, "ingredients": [
+					{"name": "Beef", "quantity": 500, "fat": 10, "protein": 20, "kcal": 180, "macroUnit": "per_100g"}]}`), http.StatusCreated, nil)
+			}
+
+			sqlStore := store.(*sqlStore)
+			migrations, err := loadMigrations(sqlStore.dialect)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := sqlStore.migrateDown(len(migrations)); err != nil {
+				t.Fatal(err)
+			}
+			applied, err := sqlStore.appliedMigrations()
+			if err != nil || len(applied) != 0 {
+				t.Fatalf("expected no applied migrations, got %v (%v)", applied, err)
+			}
+
+			if err := sqlStore.migrateUp(); err != nil {
+				t.Fatal(err)
+			}
+			s.register("again@example.com")
+		})
+	}
+}
+
+func TestMeals(t *testing.T) {
+	s := newTestServer(t)
+
+	var created Meal
+	s.decode(s.request("POST", "/api/meals", lunchJSON), http.StatusCreated, &created)
+	if created.ID == 0 || len(created.Ingredients) != 2 {
+		t.Fatalf("unexpected meal %+v", created)
+	}
+	assertFloat(t, "created kcal", created.Totals.Kcal, 130*1.5+78*2)
+
+	var meal Meal
+	s.decode(s.request("GET", fmt.Sprintf("/api/meals/%d", created.ID), nil), http.StatusOK, &meal)
+	if meal.Name != "Lunch" || !strings.HasPrefix(meal.DateTime, "2024-05-01T12:30") {
+		t.Errorf("unexpected meal %+v", meal)
+	}
+	assertFloat(t, "carbs", meal.Totals.Carbs, 28*1.5+0.6*2)
+
+	update := `{"name": "Late lunch", "datetime": "2024-05-01 14:00:00", "ingredients": [
+		{"name": "Rice", "quantity": 200, "carbs": 28, "fat": 0.3, "protein": 2.7, "kcal": 130, "macroUnit": "per_100g"}
+	]}`
+	s.decode(s.request("PUT", fmt.Sprintf("/api/meals/%d", created.ID), update), http.StatusOK, nil)
+	s.decode(s.request("GET", fmt.Sprintf("/api/meals/%d", created.ID), nil), http.StatusOK, &meal)
+	if meal.Name != "Late lunch" || len(meal.Ingredients) != 1 {
+		t.Errorf("update not applied: %+v", meal)
+	}
+	assertFloat(t, "updated kcal", meal.Totals.Kcal, 260)
+
+	// Replaced ingredients are deleted rather than left orphaned
+	var ingredients []Ingredient
+	s.decode(s.request("GET", "/api/ingredients", nil), http.StatusOK, &ingredients)
+	if len(ingredients) != 1 {
+		t.Errorf("expected 1 ingredient after update, got %d", len(ingredients))
+	}
+
+	s.decode(s.request("DELETE", fmt.Sprintf("/api/meals/%d", created.ID), nil), http.StatusOK, nil)
+	s.expectError(s.request("GET", fmt.Sprintf("/api/meals/%d", created.ID), nil), http.StatusNotFound)
+	s.decode(s.request("GET", "/api/ingredients", nil), http.StatusOK, &ingredients)
+	if len(ingredients) != 0 {
+		t.Errorf("expected no ingredients after delete, got %d", len(ingredients))
+	}
+}
+
+func TestMealsErrors(t *testing.T) {
+	s := newTestServer(t)
+
+	for _, path := range []string{"/api/meals/abc", "/api/meals/0", "/api/meals/-1"} {
+		s.expectError(s.request("GET", path, nil), http.StatusBadRequest)
+		s.expectError(s.request("PUT", path, lunchJSON), http.StatusBadRequest)
+		s.expectError(s.request("DELETE", path, nil), http.StatusBadRequest)
+	}
+
+	s.expectError(s.request("GET", "/api/meals/999", nil), http.StatusNotFound)
+	s.expectError(s.request("PUT", "/api/meals/999", lunchJSON), http.StatusNotFound)
+	s.expectError(s.request("DELETE", "/api/meals/999", nil), http.StatusNotFound)
+
+	s.expectError(s.request("POST", "/api/meals", `{"name": "Lunch",`), http.StatusBadRequest)
+	s.expectError(s.request("POST", "/api/meals", `{"name": "Lunch", "datetime": "yesterday", "ingredients": []}`), http.StatusBadRequest)
+	s.expectError(s.request("PUT", "/api/meals/1", `[]`), http.StatusBadRequest)
+
+	for _, query := range []string{"from=nope", "to=2024-13-01", "sort=kcal", "order=up", "page=0", "pageSize=0", "pageSize=100000"} {
+		s.expectError(s.request("GET", "/api/meals?"+query, nil), http.StatusBadRequest)
+	}
+}
+
+func TestMealsListing(t *testing.T) {
+	s := newTestServer(t)
+
+	for i, name := range []string{"Breakfast", "Lunch", "Dinner"} {
+		meal := Meal{Name: name, DateTime: fmt.Sprintf("2024-05-0%d 12:00:00", i+1), Ingredients: []Ingredient{}}
+		s.decode(s.request("POST", "/api/meals", meal), http.StatusCreated, nil)
+	}
+
+	var meals []Meal
+	w := s.request("GET", "/api/meals", nil)
+	s.decode(w, http.StatusOK, &meals)
+	if len(meals) != 3 || meals[0].Name != "Dinner" {
+		t.Errorf("expected newest first, got %+v", meals)
+	}
+
+	w = s.request("GET", "/api/meals?sort=name&order=asc&page=2&pageSize=2", nil)
+	s.decode(w, http.StatusOK, &meals)
+	if len(meals) != 1 || meals[0].Name != "Lunch" {
+		t.Errorf("expected second page to hold Lunch, got %+v", meals)
+	}
+	if total := w.Header().Get("X-Total-Count"); total != "3" {
+		t.Errorf("expected X-Total-Count 3, got %q", total)
+	}
+
+	s.decode(s.request("GET", "/api/meals?from=2024-05-02&to=2024-05-02", nil), http.StatusOK, &meals)
+	if len(meals) != 1 || meals[0].Name != "Lunch" {
+		t.Errorf("expected only Lunch on 2024-05-02, got %+v", meals)
+	}
+}
+
+func TestIngredients(t *testing.T) {
+	s := newTestServer(t)
+
+	var ingredient Ingredient
+	s.decode(s.request("POST", "/api/ingredients", `{"name": "Oats", "quantity": 50, "carbs": 60, "fat": 7, "protein": 13, "kcal": 380, "macroUnit": "per_100g"}`), http.StatusCreated, &ingredient)
+	if ingredient.ID == 0 {
+		t.Fatal("expected an ID")
+	}
+	assertFloat(t, "kcal", ingredient.Totals.Kcal, 190)
+
+	var ingredients []Ingredient
+	s.decode(s.request("GET", "/api/ingredients", nil), http.StatusOK, &ingredients)
+	if len(ingredients) != 1 || ingredients[0].Name != "Oats" {
+		t.Errorf("unexpected ingredients %+v", ingredients)
+	}
+
+	s.expectError(s.request("POST", "/api/ingredients", `{"name": 5}`), http.StatusBadRequest)
+}
+
+func TestIngredientTemplates(t *testing.T) {
+	s := newTestServer(t)
+
+	// New accounts start with the seeded templates
+	var templates []IngredientTemplate
+	s.decode(s.request("GET", "/api/ingredient-templates", nil), http.StatusOK, &templates)
+	if len(templates) != 10 {
+		t.Fatalf("expected 10 seeded templates, got %d", len(templates))
+	}
+
+	var template IngredientTemplate
+	s.decode(s.request("POST", "/api/ingredient-templates", `{"name": "Tofu", "carbs": 2, "fat": 5, "protein": 8, "kcal": 76, "macroUnit": "per_100g", "defaultQuantity": 200}`), http.StatusCreated, &template)
+	path := fmt.Sprintf("/api/ingredient-templates/%d", template.ID)
+
+	s.decode(s.request("PUT", path, `{"name": "Firm tofu", "carbs": 2, "fat": 8, "protein": 15, "kcal": 144, "macroUnit": "per_100g", "defaultQuantity": 150}`), http.StatusOK, nil)
+	s.decode(s.request("GET", "/api/ingredient-templates", nil), http.StatusOK, &templates)
+	found := false
+	for _, it := range templates {
+		if it.ID == template.ID {
+			found = it.Name == "Firm tofu" && it.DefaultQuantity == 150
+		}
+	}
+	if !found {
+		t.Errorf("update not applied: %+v", templates)
+	}
+	s.expectError(s.request("PUT", path, `{"name": "Salmon", "macroUnit": "per_100g"}`), http.StatusConflict)
+
+	s.decode(s.request("DELETE", path, nil), http.StatusOK, nil)
+	s.expectError(s.request("DELETE", path, nil), http.StatusNotFound)
+	s.expectError(s.request("PUT", path, `{"name": "Tofu", "macroUnit": "per_100g"}`), http.StatusNotFound)
+
+	s.expectError(s.request("PUT", "/api/ingredient-templates/abc", `{"name": "Tofu"}`), http.StatusBadRequest)
+	s.expectError(s.request("DELETE", "/api/ingredient-templates/abc", nil), http.StatusBadRequest)
+	s.expectError(s.request("POST", "/api/ingredient-templates", `{`), http.StatusBadRequest)
+	s.expectError(s.request("PUT", "/api/ingredient-templates/1", `{`), http.StatusBadRequest)
+}
+
+func TestMealTemplates(t *testing.T) {
+	s := newTestServer(t)
+
+	var templates []IngredientTemplate
+	s.decode(s.request("GET", "/api/ingredient-templates", nil), http.StatusOK, &templates)
+	ids := make(map[string]int)
+	for _, it := range templates {
+		ids[it.Name] = it.ID
+	}
+
+	body := fmt.Sprintf(`{"name": "Breakfast", "description": "Usual", "ingredients": [{"id": %d, "quantity": 2}, {"id": %d, "quantity": 200}]}`, ids["Eggs"], ids["Oatmeal"])
+	var created MealTemplate
+	s.decode(s.request("POST", "/api/meal-templates", body), http.StatusCreated, &created)
+	if len(created.Ingredients) != 2 {
+		t.Fatalf("unexpected template %+v", created)
+	}
+	assertFloat(t, "kcal", created.Totals.Kcal, 74*2+68*2)
+	path := fmt.Sprintf("/api/meal-templates/%d", created.ID)
+
+	var template MealTemplate
+	s.decode(s.request("GET", path, nil), http.StatusOK, &template)
+	if template.Name != "Breakfast" || template.Description != "Usual" {
+		t.Errorf("unexpected template %+v", template)
+	}
+
+	var list []MealTemplate
+	s.decode(s.request("GET", "/api/meal-templates", nil), http.StatusOK, &list)
+	if len(list) != 1 {
+		t.Errorf("expected 1 meal template, got %d", len(list))
+	}
+
+	body = fmt.Sprintf(`{"name": "Light breakfast", "ingredients": [{"id": %d}]}`, ids["Banana"])
+	s.decode(s.request("PUT", path, body), http.StatusOK, &template)
+	if template.Name != "Light breakfast" || len(template.Ingredients) != 1 || template.Ingredients[0].Quantity != 1 {
+		t.Errorf("update not applied: %+v", template)
+	}
+
+	// Ingredient templates must exist and belong to the user; a failed update leaves the template as it was
+	s.expectError(s.request("POST", "/api/meal-templates", `{"name": "Bad", "ingredients": [{"id": 999}]}`), http.StatusBadRequest)
+	s.expectError(s.request("PUT", path, `{"name": "Bad", "ingredients": [{"id": 999}]}`), http.StatusBadRequest)
+	s.decode(s.request("GET", path, nil), http.StatusOK, &template)
+	if template.Name != "Light breakfast" {
+		t.Errorf("failed update was applied: %+v", template)
+	}
+
+	s.decode(s.request("DELETE", path, nil), http.StatusOK, nil)
+	s.expectError(s.request("GET", path, nil), http.StatusNotFound)
+	s.expectError(s.request("PUT", path, `{"name": "Gone", "ingredients": []}`), http.StatusNotFound)
+	s.expectError(s.request("DELETE", path, nil), http.StatusNotFound)
+
+	s.expectError(s.request("GET", "/api/meal-templates/abc", nil), http.StatusBadRequest)
+	s.expectError(s.request("PUT", "/api/meal-templates/abc", `{"name": "X"}`), http.StatusBadRequest)
+	s.expectError(s.request("DELETE", "/api/meal-templates/abc", nil), http.StatusBadRequest)
+	s.expectError(s.request("POST", "/api/meal-templates", `{"name": }`), http.StatusBadRequest)
+}
+
+func TestDailyTargets(t *testing.T) {
+	s := newTestServer(t)
+
+	s.expectError(s.request("GET", "/api/daily-targets", nil), http.StatusNotFound)
+
+	var created DailyTargets
+	s.decode(s.request("POST", "/api/daily-targets", `{"protein": {"min": 120}, "kcal": {"min": 1800, "max": 2200}}`), http.StatusCreated, &created)
+	path := fmt.Sprintf("/api/daily-targets/%d", created.ID)
+
+	var targets DailyTargets
+	s.decode(s.request("GET", "/api/daily-targets", nil), http.StatusOK, &targets)
+	if targets.Protein == nil || *targets.Protein.Min != 120 || targets.Protein.Max != nil || targets.Carbs != nil {
+		t.Errorf("unexpected targets %+v", targets)
+	}
+
+	s.decode(s.request("PUT", path, `{"kcal": {"max": 2000}}`), http.StatusOK, nil)
+	targets = DailyTargets{}
+	s.decode(s.request("GET", "/api/daily-targets", nil), http.StatusOK, &targets)
+	if targets.Protein != nil || targets.Kcal == nil || *targets.Kcal.Max != 2000 {
+		t.Errorf("update not applied: %+v", targets)
+	}
+
+	s.decode(s.request("DELETE", path, nil), http.StatusOK, nil)
+	s.expectError(s.request("DELETE", path, nil), http.StatusNotFound)
+	s.expectError(s.request("PUT", path, `{}`), http.StatusNotFound)
+
+	s.expectError(s.request("PUT", "/api/daily-targets/abc", `{}`), http.StatusBadRequest)
+	s.expectError(s.request("DELETE", "/api/daily-targets/abc", nil), http.StatusBadRequest)
+	s.expectError(s.request("POST", "/api/daily-targets", `{"kcal": {"min": "lots"}}`), http.StatusBadRequest)
+	s.expectError(s.request("PUT", path, `{`), http.StatusBadRequest)
+}
+
+func TestDailySummary(t *testing.T) {
+	s := newTestServer(t)
+
+	s.decode(s.request("POST", "/api/meals", lunchJSON), http.StatusCreated, nil)
+	s.decode(s.request("POST", "/api/daily-targets", `{"effectiveFrom": "2024-01-01", "kcal": {"min": 300, "max": 400}, "protein": {"min": 50}}`), http.StatusCreated, nil)
+
+	var summary DailySummary
+	s.decode(s.request("GET", "/api/summary/daily?date=2024-05-01", nil), http.StatusOK, &summary)
+	if summary.MealCount != 1 {
+		t.Errorf("expected 1 meal, got %d", summary.MealCount)
+	}
+	assertFloat(t, "kcal", summary.Totals.Kcal, 351)
+	if summary.Progress.Kcal.Status != statusWithinRange || summary.Progress.Protein.Status != statusBelowMin || summary.Progress.Fat.Status != statusNoTarget {
+		t.Errorf("unexpected progress %+v", summary.Progress)
+	}
+
+	s.decode(s.request("GET", "/api/summary/daily?date=2024-05-02", nil), http.StatusOK, &summary)
+	if summary.MealCount != 0 || summary.Totals.Kcal != 0 {
+		t.Errorf("expected an empty day, got %+v", summary)
+	}
+
+	s.expectError(s.request("GET", "/api/summary/daily?date=01/05/2024", nil), http.StatusBadRequest)
+
+	// A zero bound with nothing logged is met rather than NaN
+	s.decode(s.request("POST", "/api/daily-targets", `{"effectiveFrom": "2024-05-02", "alcohol": {"max": 0}, "fibre": {"min": 0}}`), http.StatusCreated, nil)
+	summary = DailySummary{}
+	s.decode(s.request("GET", "/api/summary/daily?date=2024-05-02", nil), http.StatusOK, &summary)
+	if summary.Progress.Alcohol.Status != statusWithinRange || summary.Progress.Alcohol.Percentage != 100 ||
+		summary.Progress.Fibre.Status != statusWithinRange || summary.Progress.Fibre.Percentage != 100 {
+		t.Errorf("unexpected zero-target progress %+v", summary.Progress)
+	}
+}
+
+func TestNutrientsAndUnits(t *testing.T) {
+	s := newTestServer(t)
+
+	var meal Meal
+	s.decode(s.request("POST", "/api/meals", `{"name": "Dinner", "datetime": "2024-05-01T19:00", "ingredients": [
+		{"name": "Milk", "quantity": 1, "quantityUnit": "cup", "kcal": 64, "sugar": 4.8, "macroUnit": "per_100ml"},
+		{"name": "Chicken", "quantity": 6, "quantityUnit": "oz", "kcal": 165, "sodium": 74, "macroUnit": "per_100g"},
+		{"name": "Bar", "quantity": 25, "quantityUnit": "g", "kcal": 200, "fibre": 10, "macroUnit": "per_serving", "servingSize": 50},
+		{"name": "Rice", "quantity": 2, "quantityUnit": "serving", "kcal": 130, "macroUnit": "per_100g", "servingSize": 150},
+		{"name": "Egg", "quantity": 2, "kcal": 78, "macroUnit": "per_unit"}]}`), http.StatusCreated, &meal)
+	assertFloat(t, "milk kcal", meal.Ingredients[0].Totals.Kcal, 151.42)
+	assertFloat(t, "chicken kcal", meal.Ingredients[1].Totals.Kcal, 280.66)
+	assertFloat(t, "bar kcal", meal.Ingredients[2].Totals.Kcal, 100)
+	assertFloat(t, "rice kcal", meal.Ingredients[3].Totals.Kcal, 390)
+	if meal.Ingredients[4].QuantityUnit != "unit" {
+		t.Errorf("expected the quantity unit to default to unit, got %q", meal.Ingredients[4].QuantityUnit)
+	}
+	assertFloat(t, "kcal", meal.Totals.Kcal, 1078.08)
+	assertFloat(t, "sugar", meal.Totals.Sugar, 11.36)
+	assertFloat(t, "sodium", meal.Totals.Sodium, 125.87)
+	assertFloat(t, "fibre", meal.Totals.Fibre, 5)
+
+	// Totals aggregated in SQL match the ones computed in Go
+	var report Report
+	s.decode(s.request("GET", "/api/reports?period=week&from=2024-05-01&to=2024-05-01", nil), http.StatusOK, &report)
+	if len(report.Periods) != 1 || report.Periods[0].Totals != *meal.Totals {
+		t.Errorf("expected report totals %+v, got %+v", meal.Totals, report.Periods)
+	}
+
+	s.decode(s.request("POST", "/api/daily-targets", `{"effectiveFrom": "2024-01-01", "fibre": {"min": 30}, "sodium": {"max": 100}}`), http.StatusCreated, nil)
+	var summary DailySummary
+	s.decode(s.request("GET", "/api/summary/daily?date=2024-05-01", nil), http.StatusOK, &summary)
+	if summary.Targets == nil || summary.Targets.Fibre == nil || summary.Progress.Fibre.Status != statusBelowMin ||
+		summary.Progress.Sodium.Status != statusAboveMax || summary.Progress.Sugar.Status != statusNoTarget {
+		t.Errorf("unexpected nutrient progress %+v", summary.Progress)
+	}
+
+	// Target profiles carry nutrient targets too
+	var profile TargetProfile
+	s.decode(s.request("POST", "/api/target-profiles", `{"name": "low sugar", "effectiveFrom": "2024-01-01", "sugar": {"max": 5}}`), http.StatusCreated, &profile)
+	s.decode(s.request("PUT", "/api/target-overrides/2024-05-01", fmt.Sprintf(`{"profileId": %d}`, profile.ID)), http.StatusOK, nil)
+	summary = DailySummary{}
+	s.decode(s.request("GET", "/api/summary/daily?date=2024-05-01", nil), http.StatusOK, &summary)
+	if summary.Targets == nil || summary.Targets.ProfileName != "low sugar" || summary.Progress.Sugar.Status != statusAboveMax {
+		t.Errorf("expected the profile's sugar target, got %+v / %+v", summary.Targets, summary.Progress)
+	}
+
+	var template IngredientTemplate
+	s.decode(s.request("POST", "/api/ingredient-templates", `{"name": "Protein bar", "kcal": 200, "saturatedFat": 3, "macroUnit": "per_serving", "servingSize": 60}`), http.StatusCreated, &template)
+	if template.ServingSize == nil || *template.ServingSize != 60 || template.SaturatedFat != 3 {
+		t.Errorf("unexpected template %+v", template)
+	}
+
+	// Volumes and weights do not convert, and servings need a serving size
+	s.expectError(s.request("POST", "/api/ingredients", `{"name": "Rice", "quantity": 1, "quantityUnit": "cup", "kcal": 130, "macroUnit": "per_100g"}`), http.StatusBadRequest)
+	s.expectError(s.request("POST", "/api/ingredients", `{"name": "Bar", "quantity": 30, "quantityUnit": "g", "kcal": 200, "macroUnit": "per_serving"}`), http.StatusBadRequest)
+	s.expectError(s.request("POST", "/api/ingredients", `{"name": "Bar", "quantity": 1, "quantityUnit": "bushel", "macroUnit": "per_unit"}`), http.StatusBadRequest)
+	s.expectError(s.request("POST", "/api/ingredients", `{"name": "Salt", "quantity": 1, "sodium": -1, "macroUnit": "per_unit"}`), http.StatusBadRequest)
+	s.expectError(s.request("POST", "/api/ingredient-templates", `{"name": "Soup", "macroUnit": "per_bowl"}`), http.StatusBadRequest)
+	s.expectError(s.request("POST", "/api/ingredient-templates", `{"name": "Soup", "macroUnit": "per_serving", "servingSize": 0}`), http.StatusBadRequest)
+}
+
+// Users cannot see or modify each other's data
+func TestUserIsolation(t *testing.T) {
+	s := newTestServer(t)
+
+	var meal Meal
+	s.decode(s.request("POST", "/api/meals", lunchJSON), http.StatusCreated, &meal)
+	var targets DailyTargets
+	s.decode(s.request("POST", "/api/daily-targets", `{"kcal": {"max": 2000}}`), http.StatusCreated, &targets)
+
+	other := s.register("other@example.com")
+	mealPath := fmt.Sprintf("/api/meals/%d", meal.ID)
+	s.expectError(s.requestAs(other, "GET", mealPath, nil), http.StatusNotFound)
+	s.expectError(s.requestAs(other, "PUT", mealPath, lunchJSON), http.StatusNotFound)
+	s.expectError(s.requestAs(other, "DELETE", mealPath, nil), http.StatusNotFound)
+	s.expectError(s.requestAs(other, "DELETE", fmt.Sprintf("/api/daily-targets/%d", targets.ID), nil), http.StatusNotFound)
+	s.expectError(s.requestAs(other, "GET", "/api/daily-targets", nil), http.StatusNotFound)
+
+	var meals []Meal
+	s.decode(s.requestAs(other, "GET", "/api/meals", nil), http.StatusOK, &meals)
+	if len(meals) != 0 {
+		t.Errorf("expected no meals for another user, got %d", len(meals))
+	}
+
+	// Meal templates can only use the user's own ingredient templates
+	var templates []IngredientTemplate
+	s.decode(s.request("GET", "/api/ingredient-templates", nil), http.StatusOK, &templates)
+	body := fmt.Sprintf(`{"name": "Stolen", "ingredients": [{"id": %d}]}`, templates[0].ID)
+	s.expectError(s.requestAs(other, "POST", "/api/meal-templates", body), http.StatusBadRequest)
+}
+
+func TestLogMealTemplate(t *testing.T) {
+	s := newTestServer(t)
+
+	var templates []IngredientTemplate
+	s.decode(s.request("GET", "/api/ingredient-templates", nil), http.StatusOK, &templates)
+	ids := make(map[string]int)
+	for _, it := range templates {
+		ids[it.Name] = it.ID
+	}
+	var template MealTemplate
+	body := fmt.Sprintf(`{"name": "Breakfast", "ingredients": [{"id": %d, "quantity": 2}, {"id": %d, "quantity": 200}]}`, ids["Eggs"], ids["Oatmeal"])
+	s.decode(s.request("POST", "/api/meal-templates", body), http.StatusCreated, &template)
+	path := fmt.Sprintf("/api/meal-templates/%d/log", template.ID)
+
+	var meal Meal
+	body = fmt.Sprintf(`{"datetime": "2024-05-01T08:00", "scale": 1.5, "quantities": {"%d": 100}}`, ids["Oatmeal"])
+	s.decode(s.request("POST", path, body), http.StatusCreated, &meal)
+	if meal.ID == 0 || meal.Name != "Breakfast" || len(meal.Ingredients) != 2 {
+		t.Fatalf("unexpected meal %+v", meal)
+	}
+	for _, ingredient := range meal.Ingredients {
+		want := map[string]float64{"Eggs": 3, "Oatmeal": 100}[ingredient.Name]
+		if ingredient.Quantity != want {
+			t.Errorf("%s: expected quantity %v, got %v", ingredient.Name, want, ingredient.Quantity)
+		}
+	}
+	assertFloat(t, "kcal", meal.Totals.Kcal, 74*3+68)
+
+	// The logged meal keeps its macros when the ingredient template changes later
+	s.decode(s.request("PUT", fmt.Sprintf("/api/ingredient-templates/%d", ids["Eggs"]), `{"name": "Eggs", "kcal": 1000, "macroUnit": "per_unit"}`), http.StatusOK, nil)
+	s.decode(s.request("GET", fmt.Sprintf("/api/meals/%d", meal.ID), nil), http.StatusOK, &meal)
+	assertFloat(t, "stored kcal", meal.Totals.Kcal, 74*3+68)
+
+	s.decode(s.request("POST", path, `{"datetime": "2024-05-02 08:00:00", "name": "Second breakfast"}`), http.StatusCreated, &meal)
+	if meal.Name != "Second breakfast" {
+		t.Errorf("expected the name override, got %q", meal.Name)
+	}
+
+	s.expectError(s.request("POST", path, `{}`), http.StatusBadRequest)
+	s.expectError(s.request("POST", path, `{"datetime": "soon"}`), http.StatusBadRequest)
+	s.expectError(s.request("POST", path, `{"datetime": "2024-05-01", "scale": 0}`), http.StatusBadRequest)
+	s.expectError(s.request("POST", path, `{"datetime": "2024-05-01", "quantities": {"999": 10}}`), http.StatusBadRequest)
+	s.expectError(s.request("POST", path, `{"datetime": "2024-05-01", "quantities": {"`+strconv.Itoa(ids["Eggs"])+`": -1}}`), http.StatusBadRequest)
+	s.expectError(s.request("POST", "/api/meal-templates/abc/log", `{"datetime": "2024-05-01"}`), http.StatusBadRequest)
+	s.expectError(s.request("POST", "/api/meal-templates/999/log", `{"datetime": "2024-05-01"}`), http.StatusNotFound)
+
+	var meals []Meal
+	s.decode(s.request("GET", "/api/meals", nil), http.StatusOK, &meals)
+	if len(meals) != 2 {
+		t.Errorf("expected 2 logged meals, got %d", len(meals))
+	}
+}
+
+func TestSaveMealAsTemplate(t *testing.T) {
+	s := newTestServer(t)
+
+	body := `{"name": "Dinner", "datetime": "2024-05-01T19:00", "ingredients": [
+		{"name": "salmon", "quantity": 150, "carbs": 0, "fat": 13, "protein": 20, "kcal": 208, "macroUnit": "per_100g"},
+		{"name": "Couscous", "quantity": 80, "carbs": 72, "fat": 1, "protein": 13, "kcal": 360, "macroUnit": "per_100g"},
+		{"name": "Couscous", "quantity": 20, "carbs": 72, "fat": 1, "protein": 13, "kcal": 360, "macroUnit": "per_100g"}
+	]}`
+	var meal Meal
+	s.decode(s.request("POST", "/api/meals", body), http.StatusCreated, &meal)
+
+	var template MealTemplate
+	s.decode(s.request("POST", fmt.Sprintf("/api/meals/%d/save-as-template", meal.ID), `{"description": "Weeknight"}`), http.StatusCreated, &template)
+	if template.Name != "Dinner" || template.Description != "Weeknight" || len(template.Ingredients) != 2 {
+		t.Fatalf("unexpected template %+v", template)
+	}
+
+	var templates []IngredientTemplate
+	s.decode(s.request("GET", "/api/ingredient-templates", nil), http.StatusOK, &templates)
+	if len(templates) != 11 {
+		t.Errorf("expected the seeded Salmon to be reused and Couscous created, got %d templates", len(templates))
+	}
+	for _, ingredient := range template.Ingredients {
+		want := map[string]float64{"Salmon": 150, "Couscous": 100}[ingredient.Name]
+		if ingredient.Quantity != want {
+			t.Errorf("%s: expected quantity %v, got %v", ingredient.Name, want, ingredient.Quantity)
+		}
+	}
+
+	// Without a body the template takes the meal's name
+	s.decode(s.request("POST", fmt.Sprintf("/api/meals/%d/save-as-template", meal.ID), nil), http.StatusCreated, &template)
+	if template.Name != "Dinner" {
+		t.Errorf("expected the meal's name, got %q", template.Name)
+	}
+
+	// Eggs are seeded per unit, so 100g of egg cannot reuse that template
+	body = `{"name": "Omelette", "datetime": "2024-05-01T08:00", "ingredients": [
+		{"name": "Eggs", "quantity": 100, "carbs": 1, "fat": 10, "protein": 13, "kcal": 143, "macroUnit": "per_100g"}
+	]}`
+	s.decode(s.request("POST", "/api/meals", body), http.StatusCreated, &meal)
+	s.expectError(s.request("POST", fmt.Sprintf("/api/meals/%d/save-as-template", meal.ID), nil), http.StatusBadRequest)
+
+	// Nor can salmon logged with other macros reuse the seeded Salmon
+	body = `{"name": "Poke", "datetime": "2024-05-01T12:00", "ingredients": [
+		{"name": "Salmon", "quantity": 100, "carbs": 0, "fat": 6, "protein": 22, "kcal": 142, "macroUnit": "per_100g"}
+	]}`
+	s.decode(s.request("POST", "/api/meals", body), http.StatusCreated, &meal)
+	s.expectError(s.request("POST", fmt.Sprintf("/api/meals/%d/save-as-template", meal.ID), nil), http.StatusConflict)
+
+	s.expectError(s.request("POST", "/api/meals/abc/save-as-template", nil), http.StatusBadRequest)
+	s.expectError(s.request("POST", "/api/meals/999/save-as-template", nil), http.StatusNotFound)
+	s.expectError(s.request("POST", fmt.Sprintf("/api/meals/%d/save-as-template", meal.ID), `{"name": 1}`), http.StatusBadRequest)
+}
+
+func TestIngredientTemplateUsage(t *testing.T) {
+	s := newTestServer(t)
+
+	var tofu IngredientTemplate
+	s.decode(s.request("POST", "/api/ingredient-templates", `{"name": "Tofu", "carbs": 2, "fat": 5, "protein": 8, "kcal": 76, "macroUnit": "per_100g"}`), http.StatusCreated, &tofu)
+	usagePath := fmt.Sprintf("/api/ingredient-templates/%d/usage", tofu.ID)
+
+	var usage []IngredientTemplateUsage
+	s.decode(s.request("GET", usagePath, nil), http.StatusOK, &usage)
+	if len(usage) != 0 {
+		t.Fatalf("expected no usage yet, got %+v", usage)
+	}
+
+	// Added from the template by the client
+	body := fmt.Sprintf(`{"name": "Stir fry", "datetime": "2024-05-01T19:00", "ingredients": [
+		{"name": "Tofu", "quantity": 200, "carbs": 2, "fat": 5, "protein": 8, "kcal": 76, "macroUnit": "per_100g", "ingredientTemplateId": %d},
+		{"name": "Noodles", "quantity": 100, "carbs": 25, "fat": 1, "protein": 5, "kcal": 130, "macroUnit": "per_100g"}
+	]}`, tofu.ID)
+	var meal Meal
+	s.decode(s.request("POST", "/api/meals", body), http.StatusCreated, &meal)
+
+	// Logged from a meal template
+	var template MealTemplate
+	s.decode(s.request("POST", "/api/meal-templates", fmt.Sprintf(`{"name": "Tofu bowl", "ingredients": [{"id": %d, "quantity": 150}]}`, tofu.ID)), http.StatusCreated, &template)
+	s.decode(s.request("POST", fmt.Sprintf("/api/meal-templates/%d/log", template.ID), `{"datetime": "2024-05-02T12:00"}`), http.StatusCreated, nil)
+
+	s.decode(s.request("GET", usagePath, nil), http.StatusOK, &usage)
+	if len(usage) != 2 || usage[0].MealName != "Tofu bowl" || usage[1].MealID != meal.ID {
+		t.Fatalf("unexpected usage %+v", usage)
+	}
+	assertFloat(t, "kcal", usage[1].Ingredient.Totals.Kcal, 152)
+
+	// The link survives editing the meal
+	var stored Meal
+	s.decode(s.request("GET", fmt.Sprintf("/api/meals/%d", meal.ID), nil), http.StatusOK, &stored)
+	s.decode(s.request("PUT", fmt.Sprintf("/api/meals/%d", meal.ID), stored), http.StatusOK, nil)
+	s.decode(s.request("GET", usagePath, nil), http.StatusOK, &usage)
+	if len(usage) != 2 {
+		t.Errorf("expected 2 usages after editing the meal, got %d", len(usage))
+	}
+
+	// Deleting the template keeps the logged ingredients but clears their link
+	s.decode(s.request("DELETE", fmt.Sprintf("/api/ingredient-templates/%d", tofu.ID), nil), http.StatusOK, nil)
+	stored = Meal{}
+	s.decode(s.request("GET", fmt.Sprintf("/api/meals/%d", meal.ID), nil), http.StatusOK, &stored)
+	if len(stored.Ingredients) != 2 || stored.Ingredients[0].IngredientTemplateID != nil {
+		t.Errorf("expected the ingredient to be kept without a link, got %+v", stored.Ingredients)
+	}
+	s.expectError(s.request("GET", usagePath, nil), http.StatusNotFound)
+
+	s.expectError(s.request("GET", "/api/ingredient-templates/abc/usage", nil), http.StatusBadRequest)
+	body = `{"name": "Bad", "datetime": "2024-05-01T19:00", "ingredients": [{"name": "X", "quantity": 1, "macroUnit": "per_unit", "ingredientTemplateId": 999}]}`
+	s.expectError(s.request("POST", "/api/meals", body), http.StatusBadRequest)
+	s.expectError(s.request("POST", "/api/ingredients", `{"name": "X", "quantity": 1, "macroUnit": "per_unit", "ingredientTemplateId": 999}`), http.StatusBadRequest)
+}
+
+func TestIngredientPortions(t *testing.T) {
+	s := newTestServer(t)
+
+	var bread IngredientTemplate
+	s.decode(s.request("POST", "/api/ingredient-templates", `{"name": "Bread", "carbs": 49, "fat": 3.2, "protein": 9, "kcal": 265, "fibre": 2.7, "macroUnit": "per_100g"}`), http.StatusCreated, &bread)
+	portionsPath := fmt.Sprintf("/api/ingredient-templates/%d/portions", bread.ID)
+
+	var slice, loaf IngredientPortion
+	s.decode(s.request("POST", portionsPath, `{"name": "slice", "grams": 30}`), http.StatusCreated, &slice)
+	s.decode(s.request("POST", portionsPath, `{"name": "loaf", "grams": 400}`), http.StatusCreated, &loaf)
+	s.expectError(s.request("POST", portionsPath, `{"name": "Slice", "grams": 25}`), http.StatusConflict)
+	s.expectError(s.request("POST", portionsPath, `{"name": "crumb", "grams": -1}`), http.StatusBadRequest)
+	s.decode(s.request("PUT", fmt.Sprintf("%s/%d", portionsPath, slice.ID), `{"name": "slice", "grams": 36}`), http.StatusOK, nil)
+
+	var templates []IngredientTemplate
+	s.decode(s.request("GET", "/api/ingredient-templates", nil), http.StatusOK, &templates)
+	for _, template := range templates {
+		if template.ID == bread.ID && (len(template.Portions) != 2 || template.Portions[0].Grams != 36) {
+			t.Errorf("expected the template to list its portions by weight, got %+v", template.Portions)
+		}
+	}
+
+	// 2 × slice is logged as 72g with the template's macros
+	body := fmt.Sprintf(`{"name": "Toast", "datetime": "2024-05-01T08:00", "ingredients": [
+		{"quantity": 2, "portion": "Slice", "ingredientTemplateId": %d}]}`, bread.ID)
+	var meal Meal
+	s.decode(s.request("POST", "/api/meals", body), http.StatusCreated, &meal)
+	ingredient := meal.Ingredients[0]
+	if ingredient.Name != "Bread" || ingredient.Quantity != 72 || ingredient.QuantityUnit != "g" || ingredient.Portion != "" {
+		t.Errorf("unexpected ingredient %+v", ingredient)
+	}
+	assertFloat(t, "kcal", meal.Totals.Kcal, 190.8)
+	assertFloat(t, "fibre", meal.Totals.Fibre, 1.94)
+
+	body = fmt.Sprintf(`{"name": "Toast", "datetime": "2024-05-01T08:00", "ingredients": [
+		{"quantity": 1, "portion": "crust", "ingredientTemplateId": %d}]}`, bread.ID)
+	s.expectError(s.request("PUT", fmt.Sprintf("/api/meals/%d", meal.ID), body), http.StatusBadRequest)
+	s.expectError(s.request("POST", "/api/meals", `{"name": "Toast", "datetime": "2024-05-01T08:00", "ingredients": [{"quantity": 1, "portion": "slice"}]}`), http.StatusBadRequest)
+	for _, quantity := range []string{`"quantity": 0, `, ""} {
+		s.expectError(s.request("POST", "/api/meals", fmt.Sprintf(`{"name": "Toast", "datetime": "2024-05-01T08:00", "ingredients": [
+			{%s"portion": "slice", "ingredientTemplateId": %d}]}`, quantity, bread.ID)), http.StatusBadRequest)
+	}
+
+	// Portions belong to the template's owner
+	other := s.register("other@example.com")
+	s.expectError(s.requestAs(other, "GET", portionsPath, nil), http.StatusNotFound)
+	s.expectError(s.requestAs(other, "DELETE", fmt.Sprintf("%s/%d", portionsPath, loaf.ID), nil), http.StatusNotFound)
+
+	s.decode(s.request("DELETE", fmt.Sprintf("%s/%d", portionsPath, loaf.ID), nil), http.StatusOK, nil)
+	var portions []IngredientPortion
+	s.decode(s.request("GET", portionsPath, nil), http.StatusOK, &portions)
+	if len(portions) != 1 || portions[0].Name != "slice" {
+		t.Errorf("expected only the slice portion, got %+v", portions)
+	}
+	s.expectError(s.request("DELETE", portionsPath+"/abc", nil), http.StatusBadRequest)
+}
+
+func TestIngredientTemplateSearch(t *testing.T) {
+	s := newTestServer(t)
+
+	for _, name := range []string{"Chicken Thigh", "Chickpeas", "Shortbread"} {
+		s.decode(s.request("POST", "/api/ingredient-templates", fmt.Sprintf(`{"name": %q, "kcal": 100, "macroUnit": "per_100g"}`, name)), http.StatusCreated, nil)
+	}
+	search := func(query string) ([]IngredientTemplate, string) {
+		var templates []IngredientTemplate
+		w := s.request("GET", "/api/ingredient-templates/search?"+query, nil)
+		s.decode(w, http.StatusOK, &templates)
+		return templates, w.Header().Get("X-Total-Count")
+	}
+	names := func(templates []IngredientTemplate) []string {
+		var names []string
+		for _, template := range templates {
+			names = append(names, template.Name)
+		}
+		return names
+	}
+
+	// Abbreviations, misspellings and words within words match
+	if templates, total := search("q=chik+brst"); total != "1" || templates[0].Name != "Chicken Breast" {
+		t.Errorf("expected chik brst to find Chicken Breast, got %v (total %s)", names(templates), total)
+	}
+	if templates, _ := search("q=salmn"); len(templates) != 1 || templates[0].Name != "Salmon" {
+		t.Errorf("expected salmn to find Salmon, got %v", names(templates))
+	}
+	if templates, _ := search("q=BREAD"); len(templates) == 0 || templates[0].Name != "Shortbread" {
+		t.Errorf("expected bread to find Shortbread first, got %v", names(templates))
+	}
+	if templates, _ := search("q=xyzzy"); len(templates) != 0 {
+		t.Errorf("expected no matches, got %v", names(templates))
+	}
+
+	// Equally good matches rank by use in meals, then by name, and close misspellings after them
+	templates, total := search("q=chicken")
+	if total != "3" || templates[0].Name != "Chicken Breast" || templates[1].Name != "Chicken Thigh" {
+		t.Fatalf("unexpected results %v (total %s)", names(templates), total)
+	}
+	thigh := templates[1]
+	body := fmt.Sprintf(`{"name": "Dinner", "datetime": %q, "ingredients": [
+		{"name": "Chicken Thigh", "quantity": 150, "kcal": 100, "macroUnit": "per_100g", "ingredientTemplateId": %d}]}`,
+		time.Now().Format("2006-01-02T15:04"), thigh.ID)
+	var meal Meal
+	s.decode(s.request("POST", "/api/meals", body), http.StatusCreated, &meal)
+	if templates, _ := search("q=chicken&pageSize=1"); len(templates) != 1 || templates[0].ID != thigh.ID {
+		t.Errorf("expected the used template first, got %v", names(templates))
+	}
+	if templates, _ := search("q=chicken&page=2&pageSize=1"); len(templates) != 1 || templates[0].Name != "Chicken Breast" {
+		t.Errorf("expected Chicken Breast on page 2, got %v", names(templates))
+	}
+	if templates, total := search(""); total != "13" || templates[0].ID != thigh.ID {
+		t.Errorf("expected every template, most used first, got %v (total %s)", names(templates), total)
+	}
+
+	// Use counts follow meals being deleted
+	s.decode(s.request("DELETE", fmt.Sprintf("/api/meals/%d", meal.ID), nil), http.StatusOK, nil)
+	if templates, _ := search("q=chicken&pageSize=1"); len(templates) != 1 || templates[0].Name != "Chicken Breast" {
+		t.Errorf("expected Chicken Breast first again, got %v", names(templates))
+	}
+	s.expectError(s.request("GET", "/api/ingredient-templates/search?page=0", nil), http.StatusBadRequest)
+}
+
+func TestRecipes(t *testing.T) {
+	s := newTestServer(t)
+
+	// A batch of chili: 1855 kcal of raw ingredients cooks down to 1500g, in 6 servings
+	chiliJSON := `{"name": "Chili", "cookedWeight": 1500, "servings": 6, "ingredients": [
+		{"name": "Beef mince", "quantity": 500, "fat": 20, "protein": 17, "kcal": 250, "macroUnit": "per_100g"},
+		{"name": "Kidney beans", "quantity": 400, "carbs": 16, "fat": 0.5, "protein": 7, "kcal": 100, "fibre": 6, "macroUnit": "per_100g"},
+		{"name": "Chopped tomatoes", "quantity": 800, "carbs": 3, "fat": 0.2, "protein": 1, "kcal": 20, "macroUnit": "per_100g"},
+		{"name": "Onion", "quantity": 1, "carbs": 10, "protein": 1, "kcal": 45, "macroUnit": "per_unit"}]}`
+	var chili Recipe
+	s.decode(s.request("POST", "/api/recipes", chiliJSON), http.StatusCreated, &chili)
+	assertFloat(t, "total kcal", chili.Totals.Kcal, 1855)
+	assertFloat(t, "kcal per 100g", chili.Per100g.Kcal, 123.67)
+	assertFloat(t, "protein per 100g", chili.Per100g.Protein, 8.13)
+	assertFloat(t, "fibre per 100g", chili.Per100g.Fibre, 1.6)
+	assertFloat(t, "kcal per serving", chili.PerServing.Kcal, 309.17)
+	assertFloat(t, "serving weight", chili.ServingWeight, 250)
+	s.expectError(s.request("POST", "/api/recipes", chiliJSON), http.StatusConflict)
+	s.expectError(s.request("POST", "/api/recipes", `{"name": "Soup", "cookedWeight": 0, "ingredients": [{"name": "Water", "quantity": 1, "macroUnit": "per_unit"}]}`), http.StatusBadRequest)
+	s.expectError(s.request("POST", "/api/recipes", `{"name": "Soup", "cookedWeight": 1000, "ingredients": []}`), http.StatusBadRequest)
+
+	// 180g of chili is logged with the cooked dish's per 100g macros
+	path := fmt.Sprintf("/api/recipes/%d/log", chili.ID)
+	var meal Meal
+	s.decode(s.request("POST", path, `{"datetime": "2024-05-01T19:00", "quantity": 180, "quantityUnit": "g"}`), http.StatusCreated, &meal)
+	if meal.Name != "Chili" || len(meal.Ingredients) != 1 || meal.Ingredients[0].MacroUnit != "per_100g" || meal.Ingredients[0].Quantity != 180 {
+		t.Fatalf("unexpected meal %+v", meal)
+	}
+	assertFloat(t, "logged kcal", meal.Totals.Kcal, 222.6)
+	assertFloat(t, "logged protein", meal.Totals.Protein, 14.64)
+	s.decode(s.request("POST", path, `{"datetime": "2024-05-02T19:00"}`), http.StatusCreated, &meal)
+	assertFloat(t, "one serving kcal", meal.Totals.Kcal, chili.PerServing.Kcal)
+	s.expectError(s.request("POST", path, `{"datetime": "2024-05-02T19:00", "quantity": 1, "quantityUnit": "unit"}`), http.StatusBadRequest)
+	s.expectError(s.request("POST", path, `{"datetime": "2024-05-02T19:00", "quantity": 0}`), http.StatusBadRequest)
+
+	// A recipe can also be one of a meal's ingredients, in servings or by weight
+	body := fmt.Sprintf(`{"name": "Dinner", "datetime": "2024-05-03T19:00", "ingredients": [
+		{"recipeId": %d, "quantity": 1.5}, {"name": "Rice", "quantity": 150, "carbs": 28, "fat": 0.3, "protein": 2.7, "kcal": 130, "macroUnit": "per_100g"}]}`, chili.ID)
+	s.decode(s.request("POST", "/api/meals", body), http.StatusCreated, &meal)
+	if ingredient := meal.Ingredients[0]; ingredient.Name != "Chili" || ingredient.QuantityUnit != "serving" || ingredient.RecipeID != nil {
+		t.Errorf("unexpected recipe ingredient %+v", ingredient)
+	}
+	assertFloat(t, "chili and rice kcal", meal.Totals.Kcal, 463.75+195)
+	for _, quantity := range []string{`, "quantity": 0`, ""} {
+		s.expectError(s.request("POST", "/api/meals", fmt.Sprintf(`{"name": "Dinner", "datetime": "2024-05-03T19:00", "ingredients": [{"recipeId": %d%s}]}`, chili.ID, quantity)), http.StatusBadRequest)
+	}
+
+	// Recipe ingredients are not listed as ingredients, but meal ingredients are
+	var ingredients []Ingredient
+	s.decode(s.request("GET", "/api/ingredients", nil), http.StatusOK, &ingredients)
+	if len(ingredients) == 0 {
+		t.Error("expected the logged meal ingredients to be listed")
+	}
+	for _, ingredient := range ingredients {
+		if ingredient.Name == "Beef mince" {
+			t.Errorf("expected recipe ingredients to be left out, got %+v", ingredient)
+		}
+	}
+
+	// Editing the recipe changes its macros but not meals already logged
+	var updated Recipe
+	s.decode(s.request("PUT", fmt.Sprintf("/api/recipes/%d", chili.ID), strings.Replace(chiliJSON, `"cookedWeight": 1500`, `"cookedWeight": 1250`, 1)), http.StatusOK, &updated)
+	assertFloat(t, "kcal per 100g after update", updated.Per100g.Kcal, 148.4)
+	s.decode(s.request("GET", fmt.Sprintf("/api/meals/%d", meal.ID), nil), http.StatusOK, &meal)
+	assertFloat(t, "logged kcal after update", meal.Totals.Kcal, 463.75+195)
+
+	var recipes []Recipe
+	s.decode(s.request("GET", "/api/recipes", nil), http.StatusOK, &recipes)
+	if len(recipes) != 1 || len(recipes[0].Ingredients) != 4 || recipes[0].CookedWeight != 1250 {
+		t.Errorf("unexpected recipes %+v", recipes)
+	}
+
+	// Recipes belong to their owner
+	other := s.register("other@example.com")
+	s.expectError(s.requestAs(other, "GET", fmt.Sprintf("/api/recipes/%d", chili.ID), nil), http.StatusNotFound)
+	s.expectError(s.requestAs(other, "POST", path, `{"datetime": "2024-05-01T19:00"}`), http.StatusNotFound)
+	s.expectError(s.requestAs(other, "POST", "/api/meals", body), http.StatusBadRequest)
+
+	s.decode(s.request("DELETE", fmt.Sprintf("/api/recipes/%d", chili.ID), nil), http.StatusOK, nil)
+	s.expectError(s.request("GET", fmt.Sprintf("/api/recipes/%d", chili.ID), nil), http.StatusNotFound)
+}
+
+func TestDailyTargetsHistory(t *testing.T) {
+	s := newTestServer(t)
+
+	var first, second DailyTargets
+	s.decode(s.request("POST", "/api/daily-targets", `{"effectiveFrom": "2024-01-01", "kcal": {"max": 2500}}`), http.StatusCreated, &first)
+	s.decode(s.request("POST", "/api/daily-targets", `{"effectiveFrom": "2024-03-01", "kcal": {"max": 2000}}`), http.StatusCreated, &second)
+	s.decode(s.request("POST", "/api/meals", lunchJSON), http.StatusCreated, nil)
+	s.decode(s.request("POST", "/api/meals", strings.Replace(lunchJSON, "2024-05-01", "2024-02-01", 1)), http.StatusCreated, nil)
+
+	for date, want := range map[string]float64{"2024-01-01": 2500, "2024-02-29": 2500, "2024-03-01": 2000, "2030-01-01": 2000} {
+		var targets DailyTargets
+		s.decode(s.request("GET", "/api/daily-targets?date="+date, nil), http.StatusOK, &targets)
+		if targets.Kcal == nil || *targets.Kcal.Max != want {
+			t.Errorf("%s: expected kcal max %v, got %+v", date, want, targets.Kcal)
+		}
+	}
+	s.expectError(s.request("GET", "/api/daily-targets?date=2023-12-31", nil), http.StatusNotFound)
+	s.expectError(s.request("GET", "/api/daily-targets?date=yesterday", nil), http.StatusBadRequest)
+
+	// Each day's summary uses the targets in effect that day
+	var summary DailySummary
+	s.decode(s.request("GET", "/api/summary/daily?date=2024-02-01", nil), http.StatusOK, &summary)
+	if summary.Targets == nil || summary.Targets.ID != first.ID {
+		t.Errorf("expected the first targets on 2024-02-01, got %+v", summary.Targets)
+	}
+	s.decode(s.request("GET", "/api/summary/daily?date=2024-05-01", nil), http.StatusOK, &summary)
+	if summary.Targets == nil || summary.Targets.ID != second.ID {
+		t.Errorf("expected the second targets on 2024-05-01, got %+v", summary.Targets)
+	}
+
+	var timeline []TargetPeriod
+	s.decode(s.request("GET", "/api/daily-targets/timeline", nil), http.StatusOK, &timeline)
+	if len(timeline) != 2 || timeline[0].EffectiveFrom != "2024-01-01" || timeline[0].EffectiveUntil != "2024-03-01" || timeline[1].EffectiveUntil != "" {
+		t.Errorf("unexpected timeline %+v", timeline)
+	}
+
+	// Updating without effectiveFrom keeps the date; with it, the period moves
+	var updated DailyTargets
+	s.decode(s.request("PUT", fmt.Sprintf("/api/daily-targets/%d", second.ID), `{"kcal": {"max": 1900}}`), http.StatusOK, &updated)
+	if updated.EffectiveFrom != "2024-03-01" {
+		t.Errorf("expected effectiveFrom to be kept, got %q", updated.EffectiveFrom)
+	}
+	s.decode(s.request("PUT", fmt.Sprintf("/api/daily-targets/%d", second.ID), `{"effectiveFrom": "2024-02-01", "kcal": {"max": 1900}}`), http.StatusOK, nil)
+	s.decode(s.request("GET", "/api/summary/daily?date=2024-02-01", nil), http.StatusOK, &summary)
+	if summary.Targets == nil || summary.Targets.ID != second.ID {
+		t.Errorf("expected the moved targets on 2024-02-01, got %+v", summary.Targets)
+	}
+
+	s.expectError(s.request("POST", "/api/daily-targets", `{"effectiveFrom": "01/02/2024"}`), http.StatusBadRequest)
+	s.expectError(s.request("PUT", fmt.Sprintf("/api/daily-targets/%d", second.ID), `{"effectiveFrom": "tomorrow"}`), http.StatusBadRequest)
+}
+
+func TestTargetProfiles(t *testing.T) {
+	s := newTestServer(t)
+
+	s.decode(s.request("POST", "/api/daily-targets", `{"effectiveFrom": "2024-01-01", "carbs": {"max": 200}}`), http.StatusCreated, nil)
+	var training, rest TargetProfile
+	s.decode(s.request("POST", "/api/target-profiles", `{"name": "training", "effectiveFrom": "2024-01-01", "carbs": {"min": 300}}`), http.StatusCreated, &training)
+	s.decode(s.request("POST", "/api/target-profiles", `{"name": "rest", "effectiveFrom": "2024-01-01", "carbs": {"max": 150}}`), http.StatusCreated, &rest)
+	s.expectError(s.request("POST", "/api/target-profiles", `{"name": "rest"}`), http.StatusConflict)
+	s.expectError(s.request("POST", "/api/target-profiles", `{"carbs": {"max": 150}}`), http.StatusBadRequest)
+
+	var schedule TargetSchedule
+	s.decode(s.request("PUT", "/api/target-schedule", fmt.Sprintf(`{"monday": %d, "Thursday": %d}`, training.ID, training.ID)), http.StatusOK, &schedule)
+	if len(schedule) != 2 || schedule["thursday"] != training.ID {
+		t.Errorf("unexpected schedule %+v", schedule)
+	}
+	s.expectError(s.request("PUT", "/api/target-schedule", fmt.Sprintf(`{"funday": %d}`, training.ID)), http.StatusBadRequest)
+	s.expectError(s.request("PUT", "/api/target-schedule", `{"monday": 999}`), http.StatusBadRequest)
+
+	var override TargetOverride
+	s.decode(s.request("PUT", "/api/target-overrides/2024-05-13", fmt.Sprintf(`{"profileId": %d}`, rest.ID)), http.StatusOK, &override)
+	if override.ProfileName != "rest" {
+		t.Errorf("unexpected override %+v", override)
+	}
+
+	// 2024-05-06 and 2024-05-13 are Mondays, 2024-05-07 a Tuesday
+	expected := map[string]string{"2024-05-06": "training", "2024-05-13": "rest", "2024-05-07": ""}
+	for date, profile := range expected {
+		var targets DailyTargets
+		s.decode(s.request("GET", "/api/daily-targets?date="+date, nil), http.StatusOK, &targets)
+		if targets.ProfileName != profile {
+			t.Errorf("%s: expected profile %q, got %+v", date, profile, targets)
+		}
+		var summary DailySummary
+		s.decode(s.request("GET", "/api/summary/daily?date="+date, nil), http.StatusOK, &summary)
+		if summary.Targets == nil || summary.Targets.ProfileName != profile {
+			t.Errorf("%s: expected summary profile %q, got %+v", date, profile, summary.Targets)
+		}
+	}
+
+	var overrides []TargetOverride
+	s.decode(s.request("GET", "/api/target-overrides?from=2024-05-01&to=2024-05-31", nil), http.StatusOK, &overrides)
+	if len(overrides) != 1 || overrides[0].Date != "2024-05-13" {
+		t.Errorf("unexpected overrides %+v", overrides)
+	}
+	s.decode(s.request("GET", "/api/target-overrides?from=2024-06-01", nil), http.StatusOK, &overrides)
+	if len(overrides) != 0 {
+		t.Errorf("expected no overrides in June, got %+v", overrides)
+	}
+
+	// Edits take effect from their effectiveFrom, earlier days keep the targets they had
+	s.decode(s.request("PUT", fmt.Sprintf("/api/target-profiles/%d", training.ID), `{"name": "training", "effectiveFrom": "2024-05-10", "carbs": {"min": 350}}`), http.StatusOK, nil)
+	for date, carbs := range map[string]float64{"2024-05-06": 300, "2024-05-20": 350} {
+		var targets DailyTargets
+		s.decode(s.request("GET", "/api/daily-targets?date="+date, nil), http.StatusOK, &targets)
+		if targets.ProfileName != "training" || targets.Carbs == nil || *targets.Carbs.Min != carbs {
+			t.Errorf("%s: expected training carbs min %v, got %+v", date, carbs, targets)
+		}
+	}
+	var profiles []TargetProfile
+	s.decode(s.request("GET", "/api/target-profiles", nil), http.StatusOK, &profiles)
+	if len(profiles) != 2 || profiles[1].Name != "training" || len(profiles[1].Versions) != 2 ||
+		profiles[1].Versions[0].EffectiveFrom != "2024-01-01" || *profiles[1].Carbs.Min != 350 {
+		t.Errorf("unexpected profiles %+v", profiles)
+	}
+
+	// Profiles support the relative target modes
+	var percent TargetProfile
+	s.decode(s.request("POST", "/api/target-profiles", `{"name": "percent", "effectiveFrom": "2024-01-01", "mode": "percent_kcal", "kcal": {"max": 2000}, "relative": {"protein": {"min": 30}}}`), http.StatusCreated, &percent)
+	s.decode(s.request("PUT", "/api/target-overrides/2024-05-14", fmt.Sprintf(`{"profileId": %d}`, percent.ID)), http.StatusOK, nil)
+	var targets DailyTargets
+	s.decode(s.request("GET", "/api/daily-targets?date=2024-05-14", nil), http.StatusOK, &targets)
+	if targets.Mode != targetModePercentKcal || targets.Protein == nil || *targets.Protein.Min != 150 || targets.Relative == nil {
+		t.Errorf("expected percent_kcal profile targets, got %+v", targets)
+	}
+	// A profile does not apply before its first version, so past days keep their dated targets
+	var later TargetProfile
+	s.decode(s.request("POST", "/api/target-profiles", `{"name": "later", "effectiveFrom": "2024-06-01", "carbs": {"max": 100}}`), http.StatusCreated, &later)
+	s.decode(s.request("PUT", "/api/target-overrides/2024-05-15", fmt.Sprintf(`{"profileId": %d}`, later.ID)), http.StatusOK, nil)
+	targets = DailyTargets{}
+	s.decode(s.request("GET", "/api/daily-targets?date=2024-05-15", nil), http.StatusOK, &targets)
+	if targets.ProfileID != nil || targets.Carbs == nil || *targets.Carbs.Max != 200 {
+		t.Errorf("expected the dated targets before the profile existed, got %+v", targets)
+	}
+	s.decode(s.request("DELETE", fmt.Sprintf("/api/target-profiles/%d", later.ID), nil), http.StatusOK, nil)
+	s.expectError(s.request("POST", "/api/target-profiles", `{"name": "bad", "mode": "percent_kcal", "relative": {"protein": {"min": 30}}}`), http.StatusBadRequest)
+	s.expectError(s.request("PUT", fmt.Sprintf("/api/target-profiles/%d", training.ID), `{"name": "training", "effectiveFrom": "May"}`), http.StatusBadRequest)
+	s.decode(s.request("DELETE", "/api/target-overrides/2024-05-14", nil), http.StatusOK, nil)
+	s.expectError(s.request("PUT", fmt.Sprintf("/api/target-profiles/%d", training.ID), `{"name": "rest"}`), http.StatusConflict)
+
+	// Deleting a profile removes it from the schedule and overrides
+	s.decode(s.request("DELETE", "/api/target-overrides/2024-05-13", nil), http.StatusOK, nil)
+	s.expectError(s.request("DELETE", "/api/target-overrides/2024-05-13", nil), http.StatusNotFound)
+	s.decode(s.request("DELETE", fmt.Sprintf("/api/target-profiles/%d", training.ID), nil), http.StatusOK, nil)
+	schedule = nil
+	s.decode(s.request("GET", "/api/target-schedule", nil), http.StatusOK, &schedule)
+	if len(schedule) != 0 {
+		t.Errorf("expected an empty schedule, got %+v", schedule)
+	}
+	targets = DailyTargets{}
+	s.decode(s.request("GET", "/api/daily-targets?date=2024-05-06", nil), http.StatusOK, &targets)
+	if targets.ProfileID != nil || targets.Carbs == nil || *targets.Carbs.Max != 200 {
+		t.Errorf("expected the dated targets, got %+v", targets)
+	}
+
+	s.expectError(s.request("PUT", "/api/target-overrides/13-05-2024", fmt.Sprintf(`{"profileId": %d}`, rest.ID)), http.StatusBadRequest)
+	s.expectError(s.request("PUT", "/api/target-overrides/2024-05-13", `{"profileId": 999}`), http.StatusBadRequest)
+	s.expectError(s.request("PUT", "/api/target-overrides/2024-05-13", `{}`), http.StatusBadRequest)
+	s.expectError(s.request("GET", "/api/target-overrides?from=may", nil), http.StatusBadRequest)
+	s.expectError(s.request("DELETE", "/api/target-profiles/abc", nil), http.StatusBadRequest)
+	s.expectError(s.request("DELETE", fmt.Sprintf("/api/target-profiles/%d", training.ID), nil), http.StatusNotFound)
+}
+
+func TestDailyTargetModes(t *testing.T) {
+	s := newTestServer(t)
+
+	// 30% of 2000 kcal from protein is 150g; a missing kcal bound falls back to the other one
+	var created DailyTargets
+	body := `{"mode": "percent_kcal", "kcal": {"max": 2000}, "relative": {"protein": {"min": 30}, "fat": {"min": 20, "max": 36}}, "carbs": {"max": 250}}`
+	s.decode(s.request("POST", "/api/daily-targets", body), http.StatusCreated, &created)
+
+	var targets DailyTargets
+	s.decode(s.request("GET", "/api/daily-targets", nil), http.StatusOK, &targets)
+	if targets.Mode != "percent_kcal" || targets.Relative == nil || targets.Relative.Protein == nil || *targets.Relative.Protein.Min != 30 {
+		t.Fatalf("relative targets not returned: %+v", targets)
+	}
+	assertFloat(t, "protein min", *targets.Protein.Min, 150)
+	assertFloat(t, "fat min", *targets.Fat.Min, 44.44)
+	assertFloat(t, "fat max", *targets.Fat.Max, 80)
+	assertFloat(t, "carbs max", *targets.Carbs.Max, 250)
+
+	// Switching to g/kg recomputes from body weight; switching back to grams drops the relative form
+	body = `{"mode": "g_per_kg", "bodyWeight": 80, "relative": {"protein": {"min": 1.6, "max": 2.2}}}`
+	s.decode(s.request("PUT", fmt.Sprintf("/api/daily-targets/%d", created.ID), body), http.StatusOK, nil)
+	targets = DailyTargets{}
+	s.decode(s.request("GET", "/api/daily-targets", nil), http.StatusOK, &targets)
+	if targets.Mode != "g_per_kg" || targets.BodyWeight == nil || *targets.BodyWeight != 80 || targets.Fat != nil {
+		t.Fatalf("unexpected g_per_kg targets: %+v", targets)
+	}
+	assertFloat(t, "protein min", *targets.Protein.Min, 128)
+	assertFloat(t, "protein max", *targets.Protein.Max, 176)
+
+	s.decode(s.request("PUT", fmt.Sprintf("/api/daily-targets/%d", created.ID), `{"relative": {"protein": {"min": 2}}, "protein": {"min": 100}}`), http.StatusOK, nil)
+	targets = DailyTargets{}
+	s.decode(s.request("GET", "/api/daily-targets", nil), http.StatusOK, &targets)
+	if targets.Mode != "grams" || targets.Relative != nil || targets.BodyWeight != nil || *targets.Protein.Min != 100 {
+		t.Errorf("unexpected grams targets: %+v", targets)
+	}
+
+	for _, body := range []string{
+		`{"mode": "calories"}`,
+		`{"mode": "percent_kcal", "relative": {"protein": {"min": 30}}}`,
+		`{"mode": "percent_kcal", "kcal": {"min": 2000}}`,
+		`{"mode": "percent_kcal", "kcal": {"min": 2000}, "relative": {"protein": {"min": 130}}}`,
+		`{"mode": "g_per_kg", "relative": {"protein": {"min": 2}}}`,
+		`{"mode": "g_per_kg", "bodyWeight": 80, "relative": {"protein": {"min": -1}}}`,
+	} {
+		s.expectError(s.request("POST", "/api/daily-targets", body), http.StatusBadRequest)
+	}
+}
+
+func TestMeasurements(t *testing.T) {
+	s := newTestServer(t)
+
+	var created BodyMeasurement
+	s.decode(s.request("POST", "/api/measurements", `{"date": "2024-05-01", "weight": 80, "bodyFat": 20.5}`), http.StatusCreated, &created)
+	path := fmt.Sprintf("/api/measurements/%d", created.ID)
+
+	var measurement BodyMeasurement
+	s.decode(s.request("GET", path, nil), http.StatusOK, &measurement)
+	if measurement.Date != "2024-05-01" || measurement.Weight == nil || *measurement.Weight != 80 || measurement.Waist != nil {
+		t.Errorf("unexpected measurement %+v", measurement)
+	}
+
+	s.decode(s.request("PUT", path, `{"date": "2024-05-01", "weight": 79.5, "waist": 85}`), http.StatusOK, nil)
+	measurement = BodyMeasurement{}
+	s.decode(s.request("GET", path, nil), http.StatusOK, &measurement)
+	if *measurement.Weight != 79.5 || measurement.BodyFat != nil || measurement.Waist == nil || *measurement.Waist != 85 {
+		t.Errorf("update not applied: %+v", measurement)
+	}
+
+	s.expectError(s.request("POST", "/api/measurements", `{"date": "2024-05-01", "weight": 81}`), http.StatusConflict)
+	s.decode(s.request("POST", "/api/measurements", `{"date": "2024-05-02", "weight": 81}`), http.StatusCreated, nil)
+	s.decode(s.request("POST", "/api/measurements", `{"date": "2024-05-04", "weight": 78}`), http.StatusCreated, nil)
+
+	var list []BodyMeasurement
+	s.decode(s.request("GET", "/api/measurements?from=2024-05-02", nil), http.StatusOK, &list)
+	if len(list) != 2 || list[0].Date != "2024-05-02" || list[1].Date != "2024-05-04" {
+		t.Errorf("unexpected measurements %+v", list)
+	}
+
+	// 79.5, then 79.5 + 0.5 * 1.5 = 80.25, then two days later 80.25 + 0.75 * -2.25 = 78.56
+	var trend WeightTrend
+	s.decode(s.request("GET", "/api/measurements/trend?alpha=0.5", nil), http.StatusOK, &trend)
+	if len(trend.Points) != 3 || trend.Change == nil {
+		t.Fatalf("unexpected trend %+v", trend)
+	}
+	assertFloat(t, "trend 1", trend.Points[0].Trend, 79.5)
+	assertFloat(t, "trend 2", trend.Points[1].Trend, 80.25)
+	assertFloat(t, "trend 3", trend.Points[2].Trend, 78.56)
+	assertFloat(t, "change", *trend.Change, -0.94)
+
+	// Limiting the range keeps the trend computed from earlier weights
+	trend = WeightTrend{}
+	s.decode(s.request("GET", "/api/measurements/trend?alpha=0.5&from=2024-05-04", nil), http.StatusOK, &trend)
+	if len(trend.Points) != 1 || trend.Points[0].Trend != 78.56 {
+		t.Errorf("unexpected trend %+v", trend)
+	}
+
+	s.decode(s.request("DELETE", path, nil), http.StatusOK, nil)
+	s.expectError(s.request("GET", path, nil), http.StatusNotFound)
+	s.expectError(s.request("PUT", path, `{"date": "2024-05-01", "weight": 80}`), http.StatusNotFound)
+
+	for _, body := range []string{`{"weight": 80}`, `{"date": "May 1st", "weight": 80}`, `{"date": "2024-05-01"}`, `{"date": "2024-05-01", "weight": -1}`, `{"date": "2024-05-01", "bodyFat": 120}`} {
+		s.expectError(s.request("POST", "/api/measurements", body), http.StatusBadRequest)
+	}
+	s.expectError(s.request("GET", "/api/measurements/trend?alpha=2", nil), http.StatusBadRequest)
+	s.expectError(s.request("GET", "/api/measurements?to=soon", nil), http.StatusBadRequest)
+	s.expectError(s.request("GET", "/api/measurements/abc", nil), http.StatusBadRequest)
+
+	// g/kg targets without a body weight use the latest logged weight
+	var targets DailyTargets
+	s.decode(s.request("POST", "/api/daily-targets", `{"effectiveFrom": "2024-05-03", "mode": "g_per_kg", "relative": {"protein": {"min": 2}}}`), http.StatusCreated, &targets)
+	if targets.BodyWeight == nil || *targets.BodyWeight != 81 || *targets.Protein.Min != 162 {
+		t.Errorf("expected the 2024-05-02 weight to be used, got %+v", targets)
+	}
+}
+
+func TestTDEEInsights(t *testing.T) {
+	s := newTestServer(t)
+
+	// 2000 kcal every day for two weeks while the trend weight drops
+	for day := 1; day <= 14; day++ {
+		body := fmt.Sprintf(`{"name": "Day", "datetime": "2024-05-%02dT12:00", "ingredients": [{"name": "Food", "quantity": 1, "kcal": 2000, "macroUnit": "per_unit"}]}`, day)
+		s.decode(s.request("POST", "/api/meals", body), http.StatusCreated, nil)
+	}
+	s.decode(s.request("POST", "/api/measurements", `{"date": "2024-05-01", "weight": 80}`), http.StatusCreated, nil)
+
+	var estimate TDEEEstimate
+	s.decode(s.request("GET", "/api/insights/tdee?days=14&to=2024-05-14", nil), http.StatusOK, &estimate)
+	if estimate.From != "2024-05-01" || estimate.LoggedDays != 14 || estimate.AverageIntake != 2000 || estimate.TDEE != nil || estimate.Reason == "" {
+		t.Errorf("expected no estimate with a single weigh-in, got %+v", estimate)
+	}
+
+	// The trend moves 1 - 0.9^13 of the way from 80 to 79 over 13 days (to 79.25)
+	s.decode(s.request("POST", "/api/measurements", `{"date": "2024-05-14", "weight": 79}`), http.StatusCreated, nil)
+	change := round2(80-(1-math.Pow(0.9, 13))) - 80
+	estimate = TDEEEstimate{}
+	s.decode(s.request("GET", "/api/insights/tdee?days=14&to=2024-05-14&weeklyRate=-0.5", nil), http.StatusOK, &estimate)
+	if estimate.TDEE == nil || estimate.SuggestedKcal == nil {
+		t.Fatalf("expected an estimate, got %+v", estimate)
+	}
+	tdee := math.Round(2000 - change*7700/13)
+	assertFloat(t, "tdee", *estimate.TDEE, tdee)
+	assertFloat(t, "suggested kcal", *estimate.SuggestedKcal, math.Round((tdee-550)/10)*10)
+
+	// Applying keeps the other targets and recomputes percentage targets for the new kcal
+	s.decode(s.request("POST", "/api/daily-targets", `{"effectiveFrom": "2024-01-01", "mode": "percent_kcal", "kcal": {"max": 2000}, "relative": {"protein": {"min": 30}}, "fibre": {"min": 30}}`), http.StatusCreated, nil)
+	var targets DailyTargets
+	body := `{"weeklyRate": -0.5, "days": 14, "to": "2024-05-14", "effectiveFrom": "2024-05-15", "kcalMargin": 50}`
+	s.decode(s.request("POST", "/api/insights/tdee/apply", body), http.StatusCreated, &targets)
+	if targets.EffectiveFrom != "2024-05-15" || targets.Kcal == nil || *targets.Kcal.Max != *estimate.SuggestedKcal+50 {
+		t.Fatalf("unexpected applied targets %+v", targets)
+	}
+	assertFloat(t, "protein min", *targets.Protein.Min, round2(0.3*(*estimate.SuggestedKcal-50)/4))
+	if targets.Fibre == nil || *targets.Fibre.Min != 30 {
+		t.Errorf("expected the fibre target to be kept, got %+v", targets.Fibre)
+	}
+
+	// Days a target profile covers are edited through the profile
+	var profile TargetProfile
+	s.decode(s.request("POST", "/api/target-profiles", `{"name": "rest", "effectiveFrom": "2024-01-01", "kcal": {"max": 1800}}`), http.StatusCreated, &profile)
+	s.decode(s.request("PUT", "/api/target-overrides/2024-05-16", fmt.Sprintf(`{"profileId": %d}`, profile.ID)), http.StatusOK, nil)
+	body = `{"weeklyRate": -0.5, "days": 14, "to": "2024-05-14", "effectiveFrom": "2024-05-16"}`
+	s.expectError(s.request("POST", "/api/insights/tdee/apply", body), http.StatusConflict)
+
+	s.expectError(s.request("GET", "/api/insights/tdee?days=3", nil), http.StatusBadRequest)
+	s.expectError(s.request("GET", "/api/insights/tdee?weeklyRate=-5", nil), http.StatusBadRequest)
+	s.expectError(s.request("GET", "/api/insights/tdee?to=today", nil), http.StatusBadRequest)
+	s.expectError(s.request("POST", "/api/insights/tdee/apply", `{}`), http.StatusBadRequest)
+	s.expectError(s.request("POST", "/api/insights/tdee/apply", `{"weeklyRate": -0.5, "to": "2023-01-31"}`), http.StatusBadRequest)
+}
+
+func TestReports(t *testing.T) {
+	s := newTestServer(t)
+
+	// Monday 2024-04-29 to Sunday 2024-05-05, then Monday 2024-05-06
+	meal := func(datetime string, kcal, protein float64) {
+		body := fmt.Sprintf(`{"name": "Meal", "datetime": %q, "ingredients": [{"name": "Food", "quantity": 100, "kcal": %v, "protein": %v, "macroUnit": "per_100g"}]}`, datetime, kcal, protein)
+		s.decode(s.request("POST", "/api/meals", body), http.StatusCreated, nil)
+	}
+	meal("2024-04-29T08:00", 1000, 50)
+	meal("2024-04-29T19:00", 1000, 50)
+	meal("2024-05-01T12:00", 2600, 150)
+	meal("2024-05-05T12:00", 1500, 80)
+	meal("2024-05-06T12:00", 1800, 120)
+	s.decode(s.request("POST", "/api/daily-targets", `{"effectiveFrom": "2024-01-01", "kcal": {"min": 1800, "max": 2200}, "protein": {"min": 100}}`), http.StatusCreated, nil)
+
+	var report Report
+	s.decode(s.request("GET", "/api/reports?period=week&from=2024-04-29&to=2024-05-06", nil), http.StatusOK, &report)
+	if len(report.Periods) != 2 {
+		t.Fatalf("expected 2 weeks, got %+v", report)
+	}
+	week := report.Periods[0]
+	if week.Start != "2024-04-29" || week.End != "2024-05-05" || week.LoggedDays != 3 || week.MealCount != 4 {
+		t.Errorf("unexpected week %+v", week)
+	}
+	assertFloat(t, "total kcal", week.Totals.Kcal, 6100)
+	assertFloat(t, "average kcal", week.Averages.Kcal, 2033.33)
+	assertFloat(t, "average protein", week.Averages.Protein, 110)
+	if week.Adherence.Kcal != (MacroAdherence{BelowMin: 1, WithinRange: 1, AboveMax: 1}) || week.Adherence.Protein != (MacroAdherence{BelowMin: 1, WithinRange: 2}) || week.Adherence.Carbs != (MacroAdherence{}) {
+		t.Errorf("unexpected adherence %+v", week.Adherence)
+	}
+	// 2024-04-29 hits both targets; 2024-05-05 misses both
+	if week.BestDay == nil || week.BestDay.Date != "2024-04-29" || week.BestDay.WithinRange != 2 || week.WorstDay == nil || week.WorstDay.Date != "2024-05-05" {
+		t.Errorf("unexpected best/worst days %+v / %+v", week.BestDay, week.WorstDay)
+	}
+	if report.Periods[1].Start != "2024-05-06" || report.Periods[1].End != "2024-05-06" || report.Periods[1].LoggedDays != 1 {
+		t.Errorf("unexpected second week %+v", report.Periods[1])
+	}
+
+	report = Report{}
+	s.decode(s.request("GET", "/api/reports?period=month&from=2024-04-01&to=2024-05-31", nil), http.StatusOK, &report)
+	if len(report.Periods) != 2 || report.Periods[0].Start != "2024-04-01" || report.Periods[0].End != "2024-04-30" || report.Periods[0].LoggedDays != 1 || report.Periods[1].LoggedDays != 3 {
+		t.Errorf("unexpected monthly report %+v", report)
+	}
+	// Partial first and last periods report the dates they cover
+	report = Report{}
+	s.decode(s.request("GET", "/api/reports?period=month&from=2024-04-15&to=2024-05-10", nil), http.StatusOK, &report)
+	if len(report.Periods) != 2 || report.Periods[0].Start != "2024-04-15" || report.Periods[0].End != "2024-04-30" ||
+		report.Periods[1].Start != "2024-05-01" || report.Periods[1].End != "2024-05-10" {
+		t.Errorf("unexpected partial monthly report %+v", report)
+	}
+
+	s.decode(s.request("GET", "/api/reports", nil), http.StatusOK, nil)
+	s.expectError(s.request("GET", "/api/reports?period=year", nil), http.StatusBadRequest)
+	s.expectError(s.request("GET", "/api/reports?from=2024-05-06&to=2024-05-01", nil), http.StatusBadRequest)
+	s.expectError(s.request("GET", "/api/reports?from=2020-01-01&to=2024-01-01", nil), http.StatusBadRequest)
+}
+
+func TestExportMealsCSV(t *testing.T) {
+	s := newTestServer(t)
+
+	s.decode(s.request("POST", "/api/meals", lunchJSON), http.StatusCreated, nil)
+	s.decode(s.request("POST", "/api/meals", `{"name": "Breakfast, late", "datetime": "2024-04-30T10:00", "ingredients": []}`), http.StatusCreated, nil)
+	s.decode(s.request("POST", "/api/meals", strings.Replace(lunchJSON, "2024-05-01", "2024-06-01", 1)), http.StatusCreated, nil)
+
+	w := s.request("GET", "/api/export/meals.csv?from=2024-04-01&to=2024-05-31", nil)
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv") {
+		t.Fatalf("unexpected response %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 || strings.Join(records[0], ",") != strings.Join(mealsCSVHeader, ",") {
+		t.Fatalf("unexpected records %q", records)
+	}
+	// Oldest first; the meal without ingredients still gets a row
+	if records[1][1] != "Breakfast, late" || records[1][2] != "2024-04-30 10:00:00" || records[1][3] != "" {
+		t.Errorf("unexpected empty meal row %q", records[1])
+	}
+	if records[2][1] != "Lunch" || records[2][2] != "2024-05-01 12:30:00" || records[2][3] != "Rice" || records[2][5] != "g" || records[2][6] != "per_100g" || records[2][20] != "195" {
+		t.Errorf("unexpected ingredient row %q", records[2])
+	}
+
+	s.expectError(s.request("GET", "/api/export/meals.csv?from=someday", nil), http.StatusBadRequest)
+}
+
+func TestImportMeals(t *testing.T) {
+	s := newTestServer(t)
+
+	cronometer := `Day,Time,Group,Food Name,Amount,Energy (kcal),Carbs (g),Fat (g),Protein (g)
+2024-05-01,8:15 AM,Breakfast,Oats,50.00 g,190,33,3.5,6.5
+2024-05-01,,Breakfast,Milk,200.00 ml,100,10,7,7
+2024-05-01,,Dinner,Salmon,150 g,310,0,19,34
+
+2024-05-02,,Breakfast,Oats,50.00 g,190,33,3.5,6.5
+`
+	var result ImportResult
+	s.decode(s.upload("/api/import/meals", cronometer, map[string]string{"preset": "cronometer", "dryRun": "true"}), http.StatusOK, &result)
+	if !result.DryRun || result.Rows != 4 || result.Ingredients != 4 || len(result.Meals) != 3 || len(result.Errors) != 0 {
+		t.Fatalf("unexpected dry run %+v", result)
+	}
+	if result.Meals[0].Name != "Breakfast" || result.Meals[0].DateTime != "2024-05-01 08:15:00" || result.Meals[0].Ingredients != 2 || result.Meals[0].Totals.Kcal != 290 {
+		t.Errorf("unexpected first meal %+v", result.Meals[0])
+	}
+	var meals []Meal
+	s.decode(s.request("GET", "/api/meals", nil), http.StatusOK, &meals)
+	if len(meals) != 0 {
+		t.Fatalf("dry run created %d meals", len(meals))
+	}
+
+	result = ImportResult{}
+	s.decode(s.upload("/api/import/meals", cronometer, map[string]string{"preset": "cronometer"}), http.StatusCreated, &result)
+	if len(result.Meals) != 3 || result.Meals[2].ID == 0 || result.Meals[2].DateTime != "2024-05-02 12:00:00" {
+		t.Fatalf("unexpected import %+v", result)
+	}
+	var dinner Meal
+	s.decode(s.request("GET", fmt.Sprintf("/api/meals/%d", result.Meals[1].ID), nil), http.StatusOK, &dinner)
+	if dinner.Name != "Dinner" || len(dinner.Ingredients) != 1 || dinner.Totals.Protein != 34 {
+		t.Errorf("unexpected imported meal %+v", dinner)
+	}
+
+	// Invalid rows are reported by line and nothing is created
+	invalid := "Date,Meal,Calories,Carbohydrates (g),Fat (g),Protein (g)\n2024-06-01,Lunch,600,50,20,40\n2024-06-01,Dinner,\"1,5\",1,1,1\nJune 2nd,Lunch,1,1,1,1\n"
+	result = ImportResult{}
+	s.decode(s.upload("/api/import/meals", invalid, map[string]string{"preset": "myfitnesspal"}), http.StatusBadRequest, &result)
+	if len(result.Errors) != 2 || result.Errors[0].Row != 3 || result.Errors[1].Row != 4 {
+		t.Errorf("unexpected errors %+v", result.Errors)
+	}
+	s.decode(s.request("GET", "/api/meals?from=2024-06-01", nil), http.StatusOK, &meals)
+	if len(meals) != 0 {
+		t.Errorf("invalid import created %d meals", len(meals))
+	}
+
+	// A custom mapping, and our own export format round-tripping
+	custom := "when,what,food,grams,unit,kcal\n2024-06-03T13:00,Lunch,Rice,150,per_100g,130\n"
+	mapping := `{"datetime": "when", "meal": "what", "ingredient": "food", "quantity": "grams", "macroUnit": "unit", "kcal": "kcal"}`
+	result = ImportResult{}
+	s.decode(s.upload("/api/import/meals", custom, map[string]string{"mapping": mapping}), http.StatusCreated, &result)
+	if len(result.Meals) != 1 || result.Meals[0].Totals.Kcal != 195 {
+		t.Errorf("unexpected custom import %+v", result)
+	}
+	export := s.request("GET", "/api/export/meals.csv?from=2024-05-01&to=2024-05-01", nil).Body.String()
+	result = ImportResult{}
+	s.decode(s.upload("/api/import/meals", export, map[string]string{"preset": "macro-tracker", "dryRun": "true"}), http.StatusOK, &result)
+	if len(result.Meals) != 2 || result.Ingredients != 3 || len(result.Errors) != 0 {
+		t.Errorf("unexpected round trip %+v", result)
+	}
+
+	s.expectError(s.upload("/api/import/meals", cronometer, map[string]string{"preset": "loseit"}), http.StatusBadRequest)
+	s.expectError(s.upload("/api/import/meals", cronometer, map[string]string{"preset": "myfitnesspal"}), http.StatusBadRequest)
+	s.expectError(s.upload("/api/import/meals", "", map[string]string{"preset": "cronometer"}), http.StatusBadRequest)
+	s.expectError(s.upload("/api/import/meals", cronometer, map[string]string{"mapping": "{"}), http.StatusBadRequest)
+	s.expectError(s.request("POST", "/api/import/meals", `{}`), http.StatusBadRequest)
+}
+
+func TestBackupRestore(t *testing.T) {
+	s := newTestServer(t)
+
+	var oats IngredientTemplate
+	s.decode(s.request("POST", "/api/ingredient-templates", `{"name": "Backup oats", "carbs": 60, "fat": 7, "protein": 13, "kcal": 380, "macroUnit": "per_100g"}`), http.StatusCreated, &oats)
+	s.decode(s.request("POST", fmt.Sprintf("/api/ingredient-templates/%d/portions", oats.ID), `{"name": "scoop", "grams": 40}`), http.StatusCreated, nil)
+	s.decode(s.request("POST", "/api/meal-templates", fmt.Sprintf(`{"name": "Porridge", "ingredients": [{"id": %d, "quantity": 80}]}`, oats.ID)), http.StatusCreated, nil)
+	s.decode(s.request("POST", "/api/recipes", fmt.Sprintf(`{"name": "Granola", "cookedWeight": 400, "servings": 8, "ingredients": [
+		{"name": "Backup oats", "quantity": 300, "carbs": 60, "fat": 7, "protein": 13, "kcal": 380, "macroUnit": "per_100g", "ingredientTemplateId": %d}]}`, oats.ID)), http.StatusCreated, nil)
+	s.decode(s.request("POST", "/api/meals", fmt.Sprintf(`{"name": "Breakfast", "datetime": "2024-05-01T08:00", "ingredients": [
+		{"name": "Backup oats", "quantity": 80, "carbs": 60, "fat": 7, "protein": 13, "kcal": 380, "macroUnit": "per_100g", "ingredientTemplateId": %d},
+		{"name": "Coffee", "quantity": 1, "kcal": 5, "macroUnit": "per_unit"}]}`, oats.ID)), http.StatusCreated, nil)
+	s.decode(s.request("POST", "/api/daily-targets", `{"effectiveFrom": "2024-01-01", "kcal": {"min": 2000, "max": 2400}, "mode": "percent_kcal", "relative": {"protein": {"min": 25}}}`), http.StatusCreated, nil)
+	var training TargetProfile
+	s.decode(s.request("POST", "/api/target-profiles", `{"name": "training", "effectiveFrom": "2024-01-01", "carbs": {"min": 300}}`), http.StatusCreated, &training)
+	s.decode(s.request("PUT", "/api/target-schedule", fmt.Sprintf(`{"monday": %d}`, training.ID)), http.StatusOK, nil)
+	s.decode(s.request("PUT", "/api/target-overrides/2024-05-02", fmt.Sprintf(`{"profileId": %d}`, training.ID)), http.StatusOK, nil)
+	s.decode(s.request("POST", "/api/measurements", `{"date": "2024-05-01", "weight": 80}`), http.StatusCreated, nil)
+
+	var backup Backup
+	s.decode(s.request("GET", "/api/backup", nil), http.StatusOK, &backup)
+	if backup.Version != backupVersion || len(backup.MealTemplates) != 1 || len(backup.Recipes) != 1 || len(backup.Meals) != 1 || len(backup.DailyTargets) != 1 ||
+		len(backup.TargetProfiles) != 1 || len(backup.TargetSchedule) != 1 || len(backup.TargetOverrides) != 1 || len(backup.Measurements) != 1 {
+		t.Fatalf("unexpected backup %+v", backup)
+	}
+
+	// Restoring into another account remaps every reference; its seeded templates are matched by name
+	other := s.register("restore@example.com")
+	var result RestoreResult
+	s.decode(s.requestAs(other, "POST", "/api/restore", backup), http.StatusOK, &result)
+	created := RestoreCounts{IngredientTemplates: 1, MealTemplates: 1, Recipes: 1, Meals: 1, DailyTargets: 1, TargetProfiles: 1, ScheduleDays: 1, TargetOverrides: 1, Measurements: 1}
+	if result.Mode != "merge" || result.Created != created || result.Skipped.IngredientTemplates != len(backup.IngredientTemplates)-1 {
+		t.Errorf("unexpected restore result %+v", result)
+	}
+	var restored Backup
+	s.decode(s.requestAs(other, "GET", "/api/backup", nil), http.StatusOK, &restored)
+	var restoredOats IngredientTemplate
+	for _, template := range restored.IngredientTemplates {
+		if template.Name == "Backup oats" {
+			restoredOats = template
+		}
+	}
+	if restoredOats.ID == 0 || restoredOats.ID == oats.ID || len(restoredOats.Portions) != 1 || restoredOats.Portions[0].Grams != 40 {
+		t.Fatalf("unexpected restored templates %+v", restored.IngredientTemplates)
+	}
+	meal := restored.Meals[0]
+	if meal.Ingredients[0].IngredientTemplateID == nil || *meal.Ingredients[0].IngredientTemplateID != restoredOats.ID || meal.Ingredients[1].IngredientTemplateID != nil {
+		t.Errorf("unexpected restored meal %+v", meal)
+	}
+	assertFloat(t, "restored meal kcal", meal.Totals.Kcal, 309)
+	if restored.MealTemplates[0].Ingredients[0].ID != restoredOats.ID || restored.MealTemplates[0].Ingredients[0].Quantity != 80 {
+		t.Errorf("unexpected restored meal template %+v", restored.MealTemplates[0])
+	}
+	if recipe := restored.Recipes[0]; *recipe.Ingredients[0].IngredientTemplateID != restoredOats.ID || recipe.Servings != 8 {
+		t.Errorf("unexpected restored recipe %+v", recipe)
+	}
+	assertFloat(t, "restored recipe kcal per 100g", restored.Recipes[0].Per100g.Kcal, 285)
+	profileID := restored.TargetProfiles[0].ID
+	if restored.TargetSchedule["monday"] != profileID || restored.TargetOverrides[0].ProfileID != profileID || profileID == training.ID {
+		t.Errorf("unexpected restored schedule %+v and overrides %+v", restored.TargetSchedule, restored.TargetOverrides)
+	}
+	if targets := restored.DailyTargets[0]; targets.Mode != "percent_kcal" || *targets.Protein.Min != 125 {
+		t.Errorf("unexpected restored targets %+v", targets)
+	}
+
+	// Merging the same backup again skips everything that already exists
+	result = RestoreResult{}
+	s.decode(s.requestAs(other, "POST", "/api/restore", backup), http.StatusOK, &result)
+	skipped := created
+	skipped.IngredientTemplates = len(backup.IngredientTemplates)
+	if result.Created != (RestoreCounts{}) || result.Skipped != skipped {
+		t.Errorf("unexpected merge result %+v", result)
+	}
+
+	// Replacing also deletes ingredients logged on their own
+	var standalone Ingredient
+	s.decode(s.requestAs(other, "POST", "/api/ingredients", `{"name": "Apple", "quantity": 1, "kcal": 80, "macroUnit": "per_unit"}`), http.StatusCreated, &standalone)
+
+	// Replacing leaves exactly the backup's data
+	result = RestoreResult{}
+	s.decode(s.requestAs(other, "POST", "/api/restore?mode=replace", backup), http.StatusOK, &result)
+	if result.Mode != "replace" || result.Created.IngredientTemplates != len(backup.IngredientTemplates) || result.Skipped != (RestoreCounts{}) {
+		t.Errorf("unexpected replace result %+v", result)
+	}
+	var meals []Meal
+	s.decode(s.requestAs(other, "GET", "/api/meals", nil), http.StatusOK, &meals)
+	if len(meals) != 1 {
+		t.Errorf("expected 1 meal after replace, got %d", len(meals))
+	}
+	var ingredients []Ingredient
+	s.decode(s.requestAs(other, "GET", "/api/ingredients", nil), http.StatusOK, &ingredients)
+	for _, ingredient := range ingredients {
+		if ingredient.ID == standalone.ID {
+			t.Errorf("expected replace to delete the standalone ingredient, got %+v", ingredient)
+		}
+	}
+
+	// Invalid documents are rejected without changing anything
+	s.expectError(s.requestAs(other, "POST", "/api/restore?mode=overwrite", backup), http.StatusBadRequest)
+	s.expectError(s.requestAs(other, "POST", "/api/restore", `{"version": 99}`), http.StatusBadRequest)
+	s.expectError(s.requestAs(other, "POST", "/api/restore", `{"version": 1, "meals": [{"name": "Lunch", "datetime": "noon"}]}`), http.StatusBadRequest)
+	s.expectError(s.requestAs(other, "POST", "/api/restore", `{"version": 1, "mealTemplates": [{"name": "Lunch", "ingredients": [{"id": 12345}]}]}`), http.StatusBadRequest)
+	s.expectError(s.requestAs(other, "POST", "/api/restore", `{"version": 1, "targetSchedule": {"monday": 7}}`), http.StatusBadRequest)
+	meals = nil
+	s.decode(s.requestAs(other, "GET", "/api/meals", nil), http.StatusOK, &meals)
+	if len(meals) != 1 {
+		t.Errorf("expected rejected restores to leave 1 meal, got %d", len(meals))
+	}
+}
+
+func TestProductBarcode(t *testing.T) {
+	s := newTestServer(t)
+	products := store.(*sqlStore)
+
+	csvDump := "code\tproduct_name\tbrands\tserving_quantity\tenergy-kcal_100g\tenergy_100g\tcarbohydrates_100g\tfat_100g\tproteins_100g\tfiber_100g\tsalt_100g\n" +
+		"5000112637922\tCola\tCoca-Cola\t330\t42\t\t10.6\t0\t0\t\t0.01\n" +
+		"012345678905\tPeanut butter\tAcme, Acme Foods\t32\t\t2470\t13\t50\t25\t6\t1\n" +
+		"123\tBad barcode\t\t\t100\t\t\t\t\t\t\n" +
+		"4000000000000\tNo nutrition\t\t\t\t\t\t\t\t\t\n" +
+		"4000000000017\tImplausible\t\t\t50000\t\t\t\t\t\t\n"
+	imported, skipped, err := products.importProducts(strings.NewReader(csvDump), "csv")
+	if err != nil || imported != 2 || skipped != 3 {
+		t.Fatalf("expected 2 products imported and 3 skipped, got %d and %d (%v)", imported, skipped, err)
+	}
+	jsonlDump := `{"code": "5000112637922", "product_name": "Coca-Cola Classic", "brands": "Coca-Cola", "nutriments": {"energy-kcal_100g": 42, "carbohydrates_100g": "10.6", "sugars_100g": 10.6, "sodium_100g": 0.004}}
+{"code": "76222148", "product_name": "Beer", "nutriments": {"energy-kcal_100g": 43, "alcohol_100g": 5}}
+`
+	if imported, _, err = products.importProducts(strings.NewReader(jsonlDump), "jsonl"); err != nil || imported != 2 {
+		t.Fatalf("expected 2 products imported, got %d (%v)", imported, err)
+	}
+
+	// Re-importing updates the product, and UPC-A codes are found by their EAN-13
+	var product Product
+	s.decode(s.request("GET", "/api/products/barcode/5000112637922", nil), http.StatusOK, &product)
+	if product.Name != "Coca-Cola Classic" || product.Sugar != 10.6 || product.Sodium != 4 || product.MacroUnit != "per_100g" || product.ServingSize != nil {
+		t.Errorf("unexpected product %+v", product)
+	}
+	s.decode(s.request("GET", "/api/products/barcode/012345678905", nil), http.StatusOK, &product)
+	if product.Code != "0012345678905" || product.Sodium != 400 || product.ServingSize == nil || *product.ServingSize != 32 {
+		t.Errorf("unexpected product %+v", product)
+	}
+	assertFloat(t, "kcal from kJ", product.Kcal, 590.34)
+	s.decode(s.request("GET", "/api/products/barcode/76222148", nil), http.StatusOK, &product)
+	assertFloat(t, "alcohol", product.Alcohol, 3.95)
+	s.expectError(s.request("GET", "/api/products/barcode/12345678905", nil), http.StatusBadRequest)
+	s.expectError(s.request("GET", "/api/products/barcode/4000000000000", nil), http.StatusNotFound)
+
+	// Converting a product gives a per_100g ingredient template named after it and its brand
+	var template IngredientTemplate
+	s.decode(s.request("POST", "/api/products/barcode/0012345678905/ingredient-template", nil), http.StatusCreated, &template)
+	if template.Name != "Peanut butter (Acme)" || template.MacroUnit != "per_100g" || template.Fat != 50 || template.Fibre != 6 ||
+		template.ServingSize == nil || template.DefaultQuantity != 32 {
+		t.Errorf("unexpected template %+v", template)
+	}
+	s.expectError(s.request("POST", "/api/products/barcode/0012345678905/ingredient-template", nil), http.StatusConflict)
+	s.decode(s.request("POST", "/api/products/barcode/0012345678905/ingredient-template", `{"name": "PB"}`), http.StatusCreated, &template)
+	if template.Name != "PB" {
+		t.Errorf("expected the template to be named PB, got %q", template.Name)
+	}
+	s.expectError(s.request("POST", "/api/products/barcode/4000000000000/ingredient-template", nil), http.StatusNotFound)
+}
+
+func TestReferenceFoods(t *testing.T) {
+	s := newTestServer(t)
+	foods := store.(*sqlStore)
+
+	dir := t.TempDir()
+	writeFile := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	writeFile("food_category.csv", "id,code,description\n5,0500,Poultry Products\n")
+	writeFile("food.csv", "\"fdc_id\",\"data_type\",\"description\",\"food_category_id\"\n"+
+		"\"171077\",\"sr_legacy_food\",\"Chicken, broilers or fryers, breast, meat only, raw\",\"5\"\n"+
+		"\"171078\",\"sr_legacy_food\",\"Chicken, broilers or fryers, breast, meat only, cooked, roasted\",\"5\"\n"+
+		"\"999999\",\"sr_legacy_food\",\"Water, tap\",\"\"\n")
+	writeFile("food_nutrient.csv", "\"id\",\"fdc_id\",\"nutrient_id\",\"amount\"\n"+
+		"1,171077,1003,22.5\n2,171077,1004,2.62\n3,171077,1005,0\n4,171077,2047,114\n5,171077,1008,120\n6,171077,1093,45\n"+
+		"7,171078,1003,31\n8,171078,1004,3.57\n9,171078,1008,165\n10,999999,1093,4\n")
+	usda, skipped, err := readUSDAFoods(dir)
+	if err != nil || len(usda) != 2 || skipped != 1 {
+		t.Fatalf("expected 2 USDA foods and 1 skipped, got %+v, %d (%v)", usda, skipped, err)
+	}
+	cofid, _, err := readCoFIDFoods([]string{
+		writeFile("proximates.csv", "Food Code,Food Name,Group,Protein (g),Fat (g),Carbohydrate (g),Energy (kcal) (kcal),AOAC fibre (g),NSP (g),Total sugars (g)\n"+
+			"11-001,Bran wheat,AA,14.1,5.5,26.8,206,N,36.4,3.8\n"+
+			"13-001,Chicken breast grilled,MA,32,2.2,0,148,N,N,Tr\n"),
+		writeFile("inorganics.csv", "Food Code,Food Name,Sodium (mg)\n11-001,Bran wheat,28\n"),
+	})
+	if err != nil || len(cofid) != 2 {
+		t.Fatalf("expected 2 CoFID foods, got %+v (%v)", cofid, err)
+	}
+	if err = foods.importReferenceFoods(append(usda, cofid...)); err != nil {
+		t.Fatal(err)
+	}
+	// Importing again updates rather than duplicates
+	if err = foods.importReferenceFoods(usda); err != nil {
+		t.Fatal(err)
+	}
+
+	var results []ReferenceFood
+	w := s.request("GET", "/api/reference-foods?q=chicken+breast&pageSize=2", nil)
+	s.decode(w, http.StatusOK, &results)
+	if total := w.Header().Get("X-Total-Count"); total != "3" || len(results) != 2 || results[0].Name != "Chicken breast grilled" {
+		t.Fatalf("unexpected search results %+v (total %s)", results, total)
+	}
+	s.decode(s.request("GET", "/api/reference-foods?q=chicken+raw&source=usda&page=1&pageSize=1", nil), http.StatusOK, &results)
+	raw := results[0]
+	if len(results) != 1 || raw.Source != "usda" || raw.SourceID != "171077" || raw.Category != "Poultry Products" ||
+		raw.Kcal != 120 || raw.Sodium != 45 || raw.Attribution == "" {
+		t.Errorf("unexpected USDA food %+v", results)
+	}
+	s.decode(s.request("GET", "/api/reference-foods?q=bran", nil), http.StatusOK, &results)
+	if len(results) != 1 || results[0].Fibre != 36.4 || results[0].Sodium != 28 || results[0].Source != "cofid" {
+		t.Errorf("unexpected CoFID food %+v", results)
+	}
+	s.expectError(s.request("GET", "/api/reference-foods?source=ciqual", nil), http.StatusBadRequest)
+	s.expectError(s.request("GET", "/api/reference-foods?pageSize=1000", nil), http.StatusBadRequest)
+
+	// Promoting a reference food gives a per_100g ingredient template
+	var template IngredientTemplate
+	s.decode(s.request("POST", fmt.Sprintf("/api/reference-foods/%d/ingredient-template", raw.ID), `{"name": "Chicken breast"}`), http.StatusCreated, &template)
+	if template.Name != "Chicken breast" || template.MacroUnit != "per_100g" || template.Protein != 22.5 || template.Sodium != 45 {
+		t.Errorf("unexpected template %+v", template)
+	}
+	s.expectError(s.request("POST", fmt.Sprintf("/api/reference-foods/%d/ingredient-template", raw.ID), `{"name": "Chicken breast"}`), http.StatusConflict)
+	s.expectError(s.request("GET", "/api/reference-foods/99999", nil), http.StatusNotFound)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LogMealTemplateRequest is the body of POST /api/meal-templates/:id/log
+type LogMealTemplateRequest struct {
+	DateTime string `json:"datetime" binding:"required"`
+	Name     string `json:"name,omitempty"` // Defaults to the template's name
+	// Scale multiplies every template quantity, e.g. 0.5 for half a portion. Defaults to 1.
+	Scale *float64 `json:"scale,omitempty"`
+	// Quantities overrides the quantity of individual ingredients, keyed by ingredient template ID.
+	// Overridden quantities are not scaled.
+	Quantities map[string]float64 `json:"quantities,omitempty"`
+}
+
+// logMealTemplate creates a meal from a meal template, copying each ingredient template's
+// macros into new ingredients so later template edits do not change the logged meal
+func logMealTemplate(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+	var req LogMealTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	t, _, err := parseDateTimeParam(req.DateTime)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid datetime: %v", err)})
+		return
+	}
+	scale := 1.0
+	if req.Scale != nil {
+		if *req.Scale <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "scale must be greater than 0"})
+			return
+		}
+		scale = *req.Scale
+	}
+
+	template, err := store.GetMealTemplate(currentUserID(c), id)
+	if err != nil {
+		respondStoreError(c, err, "Meal template not found")
+		return
+	}
+
+	meal, err := mealFromTemplate(template, req.Quantities, scale)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	meal.DateTime = t.Format(timestampLayout)
+	if req.Name != "" {
+		meal.Name = req.Name
+	}
+
+	if err := store.CreateMeal(currentUserID(c), &meal); err != nil {
+		respondStoreError(c, err, "Meal template not found")
+		return
+	}
+
+	meal.computeTotals()
+	c.JSON(http.StatusCreated, meal)
+}
+
+// Helper function to build the meal logged from a template. Quantities are taken from the
+// overrides (keyed by ingredient template ID) or else the template's quantity times scale.
+func mealFromTemplate(template *MealTemplate, overrides map[string]float64, scale float64) (Meal, error) {
+	inTemplate := make(map[string]bool, len(template.Ingredients))
+	for _, ingredient := range template.Ingredients {
+		inTemplate[strconv.Itoa(ingredient.ID)] = true
+	}
+	for key, quantity := range overrides {
+		if !inTemplate[key] {
+			return Meal{}, fmt.Errorf("ingredient template %s is not part of this meal template", key)
+		}
+		if quantity <= 0 {
+			return Meal{}, fmt.Errorf("quantity for ingredient template %s must be greater than 0", key)
+		}
+	}
+
+	meal := Meal{Name: template.Name, Ingredients: make([]Ingredient, 0, len(template.Ingredients))}
+	for _, ingredient := range template.Ingredients {
+		quantity, overridden := overrides[strconv.Itoa(ingredient.ID)]
+		if !overridden {
+			quantity = ingredient.Quantity * scale
+		}
+		templateID := ingredient.ID
+		meal.Ingredients = append(meal.Ingredients, Ingredient{
+			Name:                 ingredient.Name,
+			Quantity:             round2(quantity),
+			QuantityUnit:         defaultQuantityUnit(ingredient.MacroUnit),
+			Carbs:                ingredient.Carbs,
+			Fat:                  ingredient.Fat,
+			Protein:              ingredient.Protein,
+			Kcal:                 ingredient.Kcal,
+			Nutrients:            ingredient.Nutrients,
+			MacroUnit:            ingredient.MacroUnit,
+			ServingSize:          ingredient.ServingSize,
+			IngredientTemplateID: &templateID,
+		})
+	}
+	return meal, nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SaveAsTemplateRequest is the optional body of POST /api/meals/:id/save-as-template
+type SaveAsTemplateRequest struct {
+	Name        string `json:"name,omitempty"` // Defaults to the meal's name
+	Description string `json:"description,omitempty"`
+}
+
+// saveMealAsTemplate turns a logged meal into a meal template. Each ingredient is matched to
+// the user's ingredient template of the same name, or a new ingredient template is created from it.
+func saveMealAsTemplate(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+	var req SaveAsTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	meal, err := store.GetMeal(currentUserID(c), id)
+	if err != nil {
+		respondStoreError(c, err, "Meal not found")
+		return
+	}
+
+	template := MealTemplate{Name: meal.Name, Description: req.Description}
+	if req.Name != "" {
+		template.Name = req.Name
+	}
+	if err := store.CreateMealTemplateFromMeal(currentUserID(c), meal, &template); err != nil {
+		respondStoreError(c, err, "Meal not found")
+		return
+	}
+
+	created, err := store.GetMealTemplate(currentUserID(c), template.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, created)
+}
+
+// CreateMealTemplateFromMeal creates a meal template holding the meal's ingredients. Ingredients
+// are matched to ingredient templates by name (case-insensitively) and must have the same macro
+// unit and macros; unmatched ingredients become new ingredient templates. Ingredients sharing a
+// name are combined into one entry, and each ingredient is linked to its ingredient template.
+func (s *sqlStore) CreateMealTemplateFromMeal(userID int, meal *Meal, template *MealTemplate) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow("INSERT INTO meal_templates (user_id, name, description) VALUES ($1, $2, $3) RETURNING id",
+		userID, template.Name, template.Description).Scan(&template.ID)
+	if err != nil {
+		return err
+	}
+
+	quantities := make(map[int]float64)
+	var order []int
+	for _, ingredient := range meal.Ingredients {
+		templateID, err := matchIngredientTemplate(tx, userID, ingredient)
+		if err != nil {
+			return err
+		}
+		if _, seen := quantities[templateID]; !seen {
+			order = append(order, templateID)
+		}
+		quantities[templateID] += ingredient.baseQuantity()
+
+		// Link the logged ingredient to its template, as if it had been added from it
+		if _, err = tx.Exec("UPDATE ingredients SET ingredient_template_id = $1 WHERE id = $2", templateID, ingredient.ID); err != nil {
+			return err
+		}
+	}
+
+	// The ingredients may have been added from other templates before
+	if err = refreshTemplateUses(tx, append(order, ingredientTemplateIDs(meal.Ingredients)...)); err != nil {
+		return err
+	}
+
+	for _, templateID := range order {
+		_, err = tx.Exec("INSERT INTO meal_template_ingredients (meal_template_id, ingredient_template_id, quantity) VALUES ($1, $2, $3)",
+			template.ID, templateID, quantities[templateID])
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// matchIngredientTemplate returns the ID of the user's ingredient template named like the
+// ingredient, creating one from the ingredient's macros if there is none. A template of that
+// name with other macros is a conflict.
+func matchIngredientTemplate(tx *sql.Tx, userID int, ingredient Ingredient) (int, error) {
+	var id int
+	var macroUnit string
+	var carbs, fat, protein, kcal float64
+	err := tx.QueryRow(`
+		SELECT id, macro_unit, carbs, fat, protein, kcal FROM ingredient_templates
+		WHERE user_id = $1 AND LOWER(name) = LOWER($2) ORDER BY id LIMIT 1
+	`, userID, strings.TrimSpace(ingredient.Name)).Scan(&id, &macroUnit, &carbs, &fat, &protein, &kcal)
+	if err == nil {
+		// A quantity only means the same thing if both use the same macro unit
+		if macroUnit != ingredient.MacroUnit {
+			return 0, fmt.Errorf("%w: ingredient template %q uses %s macros but the meal's ingredient uses %s",
+				errInvalidReference, ingredient.Name, macroUnit, ingredient.MacroUnit)
+		}
+		// Reusing a template with other macros would change what the meal template adds up to
+		if round2(carbs) != round2(ingredient.Carbs) || round2(fat) != round2(ingredient.Fat) ||
+			round2(protein) != round2(ingredient.Protein) || round2(kcal) != round2(ingredient.Kcal) {
+			return 0, fmt.Errorf("%w: ingredient template %q has different macros from the meal's ingredient",
+				errDuplicate, ingredient.Name)
+		}
+		return id, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO ingredient_templates (user_id, name, carbs, fat, protein, kcal,
+			fibre, sugar, saturated_fat, sodium, alcohol, macro_unit, serving_size, default_quantity)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		RETURNING id
+	`, userID, strings.TrimSpace(ingredient.Name), ingredient.Carbs, ingredient.Fat, ingredient.Protein, ingredient.Kcal,
+		ingredient.Fibre, ingredient.Sugar, ingredient.SaturatedFat, ingredient.Sodium, ingredient.Alcohol,
+		ingredient.MacroUnit, getFloatOrNil(ingredient.ServingSize), round2(ingredient.baseQuantity())).Scan(&id)
+	return id, err
+}
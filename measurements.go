@@ -0,0 +1,380 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BodyMeasurement is one day's body weight, body fat and waist measurements. At most one
+// measurement is kept per user per date.
+type BodyMeasurement struct {
+	ID        int      `json:"id,omitempty"`
+	Date      string   `json:"date" binding:"required"` // YYYY-MM-DD
+	Weight    *float64 `json:"weight,omitempty"`        // kg
+	BodyFat   *float64 `json:"bodyFat,omitempty"`       // percent
+	Waist     *float64 `json:"waist,omitempty"`         // cm
+	CreatedAt string   `json:"createdAt,omitempty"`
+	UpdatedAt string   `json:"updatedAt,omitempty"`
+}
+
+// WeightTrendPoint is a logged weight alongside the smoothed trend weight for that day
+type WeightTrendPoint struct {
+	Date   string  `json:"date"`
+	Weight float64 `json:"weight"`
+	Trend  float64 `json:"trend"`
+}
+
+// WeightTrend is the response of GET /api/measurements/trend
+type WeightTrend struct {
+	Alpha  float64            `json:"alpha"`
+	Points []WeightTrendPoint `json:"points"`
+	// Change is the difference between the last and first trend weight in the range
+	Change *float64 `json:"change,omitempty"`
+}
+
+// defaultTrendAlpha smooths out day-to-day water weight swings while still following real changes
+const defaultTrendAlpha = 0.1
+
+// Helper function to bind a measurement from the request body and validate it
+func bindMeasurement(c *gin.Context) (BodyMeasurement, bool) {
+	var measurement BodyMeasurement
+	if err := c.ShouldBindJSON(&measurement); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return measurement, false
+	}
+	if _, err := time.Parse(dateLayout, measurement.Date); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date, expected YYYY-MM-DD"})
+		return measurement, false
+	}
+	if measurement.Weight == nil && measurement.BodyFat == nil && measurement.Waist == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one of weight, bodyFat or waist is required"})
+		return measurement, false
+	}
+	for name, value := range map[string]*float64{"weight": measurement.Weight, "bodyFat": measurement.BodyFat, "waist": measurement.Waist} {
+		if value != nil && *value <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s must be greater than 0", name)})
+			return measurement, false
+		}
+	}
+	if measurement.BodyFat != nil && *measurement.BodyFat >= 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bodyFat must be a percentage below 100"})
+		return measurement, false
+	}
+	return measurement, true
+}
+
+// Measurement handlers
+
+// getMeasurements lists measurements by date, optionally limited to ?from=YYYY-MM-DD&to=YYYY-MM-DD
+func getMeasurements(c *gin.Context) {
+	from, to, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+
+	measurements, err := store.ListMeasurements(currentUserID(c), from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, measurements)
+}
+
+func getMeasurement(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	measurement, err := store.GetMeasurement(currentUserID(c), id)
+	if err != nil {
+		respondStoreError(c, err, "Measurement not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, measurement)
+}
+
+func createMeasurement(c *gin.Context) {
+	measurement, ok := bindMeasurement(c)
+	if !ok {
+		return
+	}
+
+	if err := store.CreateMeasurement(currentUserID(c), &measurement); err != nil {
+		respondStoreError(c, err, "Measurement not found")
+		return
+	}
+
+	c.JSON(http.StatusCreated, measurement)
+}
+
+func updateMeasurement(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+	measurement, ok := bindMeasurement(c)
+	if !ok {
+		return
+	}
+
+	if err := store.UpdateMeasurement(currentUserID(c), id, &measurement); err != nil {
+		respondStoreError(c, err, "Measurement not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, measurement)
+}
+
+func deleteMeasurement(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := store.DeleteMeasurement(currentUserID(c), id); err != nil {
+		respondStoreError(c, err, "Measurement not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Measurement deleted successfully"})
+}
+
+// getWeightTrend returns the logged weights between ?from and ?to with an exponential moving
+// average trend. ?alpha (0 < alpha <= 1) sets the smoothing factor; smaller is smoother.
+func getWeightTrend(c *gin.Context) {
+	from, to, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+	alpha := defaultTrendAlpha
+	if alphaParam := c.Query("alpha"); alphaParam != "" {
+		value, err := strconv.ParseFloat(alphaParam, 64)
+		if err != nil || value <= 0 || value > 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid alpha, expected a number between 0 and 1"})
+			return
+		}
+		alpha = value
+	}
+
+	// The trend depends on every earlier weight, so start from the first measurement
+	measurements, err := store.ListMeasurements(currentUserID(c), "", to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	trend := WeightTrend{Alpha: alpha, Points: []WeightTrendPoint{}}
+	for _, point := range weightTrend(measurements, alpha) {
+		if from == "" || point.Date >= from {
+			trend.Points = append(trend.Points, point)
+		}
+	}
+	if n := len(trend.Points); n > 0 {
+		change := round2(trend.Points[n-1].Trend - trend.Points[0].Trend)
+		trend.Change = &change
+	}
+
+	c.JSON(http.StatusOK, trend)
+}
+
+// Helper function to compute the exponential moving average of the weights in measurements,
+// which must be ordered by date. Days without a weigh-in still count: after a gap of n days the
+// new weight gets the weight it would have had after n daily updates.
+func weightTrend(measurements []BodyMeasurement, alpha float64) []WeightTrendPoint {
+	var points []WeightTrendPoint
+	var trend float64
+	var last time.Time
+	for _, measurement := range measurements {
+		if measurement.Weight == nil {
+			continue
+		}
+		date, err := time.Parse(dateLayout, measurement.Date)
+		if err != nil {
+			continue
+		}
+		weight := *measurement.Weight
+		if points == nil {
+			trend = weight
+		} else {
+			days := math.Max(1, math.Round(date.Sub(last).Hours()/24))
+			trend += (1 - math.Pow(1-alpha, days)) * (weight - trend)
+		}
+		last = date
+		points = append(points, WeightTrendPoint{Date: measurement.Date, Weight: weight, Trend: round2(trend)})
+	}
+	return points
+}
+
+// Helper function to find the user's most recent logged weight on or before a date (YYYY-MM-DD,
+// or today if empty). Returns nil if no weight has been logged.
+func latestWeight(userID int, date string) (*float64, error) {
+	if date == "" {
+		date = time.Now().Format(dateLayout)
+	}
+	return store.LatestWeight(userID, date)
+}
+
+// Measurement storage
+
+const measurementColumns = "id, date, weight_kg, body_fat_percent, waist_cm, created_at, updated_at"
+
+func scanMeasurement(row rowScanner) (BodyMeasurement, error) {
+	var measurement BodyMeasurement
+	var weight, bodyFat, waist sql.NullFloat64
+	var createdAt, updatedAt sql.NullString
+
+	err := row.Scan(&measurement.ID, &measurement.Date, &weight, &bodyFat, &waist, &createdAt, &updatedAt)
+	if err != nil {
+		return measurement, err
+	}
+
+	measurement.Date = dateString(measurement.Date)
+	if weight.Valid {
+		measurement.Weight = &weight.Float64
+	}
+	if bodyFat.Valid {
+		measurement.BodyFat = &bodyFat.Float64
+	}
+	if waist.Valid {
+		measurement.Waist = &waist.Float64
+	}
+	measurement.CreatedAt = createdAt.String
+	measurement.UpdatedAt = updatedAt.String
+	return measurement, nil
+}
+
+// ListMeasurements returns the user's measurements by date, optionally between from and to (inclusive)
+func (s *sqlStore) ListMeasurements(userID int, from, to string) ([]BodyMeasurement, error) {
+	conditions := []string{"user_id = $1"}
+	args := []interface{}{userID}
+	if from != "" {
+		args = append(args, from)
+		conditions = append(conditions, fmt.Sprintf("date >= $%d", len(args)))
+	}
+	if to != "" {
+		args = append(args, to)
+		conditions = append(conditions, fmt.Sprintf("date <= $%d", len(args)))
+	}
+
+	rows, err := s.db.Query("SELECT "+measurementColumns+" FROM body_measurements WHERE "+strings.Join(conditions, " AND ")+" ORDER BY date", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	measurements := []BodyMeasurement{}
+	for rows.Next() {
+		measurement, err := scanMeasurement(rows)
+		if err != nil {
+			return nil, err
+		}
+		measurements = append(measurements, measurement)
+	}
+	return measurements, rows.Err()
+}
+
+// LatestWeight returns the weight of the user's latest measurement with one on or before a date,
+// or nil if there is none
+func (s *sqlStore) LatestWeight(userID int, date string) (*float64, error) {
+	var weight float64
+	err := s.db.QueryRow(`
+		SELECT weight_kg FROM body_measurements
+		WHERE user_id = $1 AND date <= $2 AND weight_kg IS NOT NULL
+		ORDER BY date DESC LIMIT 1
+	`, userID, date).Scan(&weight)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &weight, nil
+}
+
+func (s *sqlStore) GetMeasurement(userID, id int) (*BodyMeasurement, error) {
+	row := s.db.QueryRow("SELECT "+measurementColumns+" FROM body_measurements WHERE id = $1 AND user_id = $2", id, userID)
+	measurement, err := scanMeasurement(row)
+	if err == sql.ErrNoRows {
+		return nil, errNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &measurement, nil
+}
+
+// checkMeasurementDate returns errDuplicate if another of the user's measurements is on the date
+func checkMeasurementDate(tx *sql.Tx, userID, id int, date string) error {
+	var exists bool
+	err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM body_measurements WHERE user_id = $1 AND date = $2 AND id <> $3)", userID, date, id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("%w: measurement on %s", errDuplicate, date)
+	}
+	return nil
+}
+
+func (s *sqlStore) CreateMeasurement(userID int, measurement *BodyMeasurement) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = checkMeasurementDate(tx, userID, 0, measurement.Date); err != nil {
+		return err
+	}
+	if err = insertMeasurement(tx, userID, measurement); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func insertMeasurement(q queryer, userID int, measurement *BodyMeasurement) error {
+	return q.QueryRow(`
+		INSERT INTO body_measurements (user_id, date, weight_kg, body_fat_percent, waist_cm)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`, userID, measurement.Date, getFloatOrNil(measurement.Weight), getFloatOrNil(measurement.BodyFat), getFloatOrNil(measurement.Waist)).Scan(&measurement.ID)
+}
+
+func (s *sqlStore) UpdateMeasurement(userID, id int, measurement *BodyMeasurement) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = checkMeasurementDate(tx, userID, id, measurement.Date); err != nil {
+		return err
+	}
+	result, err := tx.Exec(`
+		UPDATE body_measurements
+		SET date = $1, weight_kg = $2, body_fat_percent = $3, waist_cm = $4, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $5 AND user_id = $6
+	`, measurement.Date, getFloatOrNil(measurement.Weight), getFloatOrNil(measurement.BodyFat), getFloatOrNil(measurement.Waist), id, userID)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return errNotFound
+	}
+	measurement.ID = id
+	return tx.Commit()
+}
+
+func (s *sqlStore) DeleteMeasurement(userID, id int) error {
+	return s.deleteOwned("body_measurements", userID, id)
+}
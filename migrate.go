@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Each dialect has its own copy of the schema in migrations/<dialect>/
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+// migration is a numbered schema change loaded from migrations/<dialect>/NNNN_name.{up,down}.sql
+type migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// loadMigrations reads the embedded migrations for a dialect, ordered by version
+func loadMigrations(d dialect) ([]migration, error) {
+	entries, err := fs.Glob(migrationFiles, path.Join("migrations", d.name, "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*migration)
+	for _, entry := range entries {
+		file := path.Base(entry)
+		base, direction, ok := strings.Cut(strings.TrimSuffix(file, ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("migration %s: expected NNNN_name.up.sql or NNNN_name.down.sql", file)
+		}
+		versionPart, name, _ := strings.Cut(base, "_")
+		version, err := strconv.Atoi(versionPart)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: invalid version: %w", file, err)
+		}
+
+		contents, err := migrationFiles.ReadFile(entry)
+		if err != nil {
+			return nil, err
+		}
+
+		m, exists := byVersion[version]
+		if !exists {
+			m = &migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("migration %s: version %d is already used by %s", file, version, m.Name)
+		}
+		if direction == "up" {
+			m.Up = string(contents)
+		} else {
+			m.Down = string(contents)
+		}
+	}
+
+	if len(byVersion) == 0 {
+		return nil, fmt.Errorf("no migrations found for %s", d.name)
+	}
+
+	migrations := make([]migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %04d_%s has no up script", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+func (s *sqlStore) ensureMigrationsTable() error {
+	_, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	return err
+}
+
+// appliedMigrations returns the set of migration versions recorded in schema_migrations
+func (s *sqlStore) appliedMigrations() (map[int]bool, error) {
+	if err := s.ensureMigrationsTable(); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query("SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+// migrateUp applies every pending migration in order, each in its own transaction, then checks
+// which optional extensions the schema ended up with
+func (s *sqlStore) migrateUp() error {
+	migrations, err := loadMigrations(s.dialect)
+	if err != nil {
+		return err
+	}
+	applied, err := s.appliedMigrations()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if applied[m.Version] {
+			continue
+		}
+		log.Printf("Applying migration %04d_%s", m.Version, m.Name)
+		err := s.runMigration(m.Up, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", m.Version, m.Name)
+		if err != nil {
+			return fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+	}
+	return s.detectWordSimilarity()
+}
+
+// migrateDown reverts the most recently applied migrations, newest first
+func (s *sqlStore) migrateDown(steps int) error {
+	migrations, err := loadMigrations(s.dialect)
+	if err != nil {
+		return err
+	}
+	applied, err := s.appliedMigrations()
+	if err != nil {
+		return err
+	}
+
+	for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+		m := migrations[i]
+		if !applied[m.Version] {
+			continue
+		}
+		if m.Down == "" {
+			return fmt.Errorf("migration %04d_%s has no down script", m.Version, m.Name)
+		}
+		log.Printf("Reverting migration %04d_%s", m.Version, m.Name)
+		err := s.runMigration(m.Down, "DELETE FROM schema_migrations WHERE version = $1", m.Version)
+		if err != nil {
+			return fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+		steps--
+	}
+	return nil
+}
+
+// runMigration executes a migration script and records it in schema_migrations atomically.
+// Foreign keys are off while it runs (SQLite only allows that outside a transaction, hence the
+// pinned connection) so tables can be rebuilt, and are checked before committing.
+func (s *sqlStore) runMigration(script, record string, args ...interface{}) error {
+	ctx := context.Background()
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if s.dialect.foreignKeysOff != "" {
+		if _, err = conn.ExecContext(ctx, s.dialect.foreignKeysOff); err != nil {
+			return err
+		}
+		defer conn.ExecContext(ctx, s.dialect.foreignKeysOn)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(script); err != nil {
+		return err
+	}
+	if _, err = tx.Exec(record, args...); err != nil {
+		return err
+	}
+	if s.dialect.foreignKeyCheck != "" {
+		rows, err := tx.Query(s.dialect.foreignKeyCheck)
+		if err != nil {
+			return err
+		}
+		broken := rows.Next()
+		rows.Close()
+		if broken {
+			return errors.New("migration leaves rows referencing missing rows")
+		}
+	}
+	return tx.Commit()
+}
+
+// printMigrationStatus lists every known migration and whether it has been applied
+func (s *sqlStore) printMigrationStatus() error {
+	migrations, err := loadMigrations(s.dialect)
+	if err != nil {
+		return err
+	}
+	applied, err := s.appliedMigrations()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		state := "pending"
+		if applied[m.Version] {
+			state = "applied"
+		}
+		fmt.Printf("%04d_%-40s %s\n", m.Version, m.Name, state)
+	}
+	return nil
+}
+
+// runMigrateCommand handles `macro-tracker migrate [up | down [N] | status]`
+func runMigrateCommand(s *sqlStore, args []string) error {
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "up":
+		return s.migrateUp()
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+			steps = n
+		}
+		return s.migrateDown(steps)
+	case "status":
+		return s.printMigrationStatus()
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected up, down [N] or status", command)
+	}
+}
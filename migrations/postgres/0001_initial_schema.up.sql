@@ -0,0 +1,84 @@
+-- Core schema. Written to be idempotent so databases created before versioned
+-- migrations existed (by the old initDB or migration.sql) are adopted as-is.
+
+CREATE TABLE IF NOT EXISTS meals (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    datetime TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_meals_datetime ON meals (datetime);
+
+CREATE TABLE IF NOT EXISTS ingredients (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    quantity DECIMAL(8,2) NOT NULL DEFAULT 1,
+    carbs DECIMAL(8,2) NOT NULL DEFAULT 0,
+    fat DECIMAL(8,2) NOT NULL DEFAULT 0,
+    protein DECIMAL(8,2) NOT NULL DEFAULT 0,
+    kcal DECIMAL(8,2) NOT NULL DEFAULT 0,
+    macro_unit VARCHAR(20) NOT NULL DEFAULT 'per_unit'
+);
+
+ALTER TABLE ingredients ADD COLUMN IF NOT EXISTS macro_unit VARCHAR(20) NOT NULL DEFAULT 'per_unit';
+
+CREATE TABLE IF NOT EXISTS meal_ingredients (
+    meal_id INTEGER REFERENCES meals(id) ON DELETE CASCADE,
+    ingredient_id INTEGER REFERENCES ingredients(id) ON DELETE CASCADE,
+    PRIMARY KEY (meal_id, ingredient_id)
+);
+
+CREATE TABLE IF NOT EXISTS ingredient_templates (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    carbs DECIMAL(8,2) NOT NULL DEFAULT 0,
+    fat DECIMAL(8,2) NOT NULL DEFAULT 0,
+    protein DECIMAL(8,2) NOT NULL DEFAULT 0,
+    kcal DECIMAL(8,2) NOT NULL DEFAULT 0,
+    macro_unit VARCHAR(20) NOT NULL DEFAULT 'per_unit',
+    default_quantity DECIMAL(8,2) DEFAULT 1,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE ingredient_templates ADD COLUMN IF NOT EXISTS default_quantity DECIMAL(8,2) DEFAULT 1;
+
+CREATE TABLE IF NOT EXISTS meal_templates (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS meal_template_ingredients (
+    meal_template_id INTEGER REFERENCES meal_templates(id) ON DELETE CASCADE,
+    ingredient_template_id INTEGER REFERENCES ingredient_templates(id) ON DELETE CASCADE,
+    quantity DECIMAL(8,2) NOT NULL DEFAULT 1,
+    PRIMARY KEY (meal_template_id, ingredient_template_id)
+);
+
+CREATE TABLE IF NOT EXISTS daily_targets (
+    id SERIAL PRIMARY KEY,
+    carbs_min DECIMAL(8,2),
+    carbs_max DECIMAL(8,2),
+    fat_min DECIMAL(8,2),
+    fat_max DECIMAL(8,2),
+    protein_min DECIMAL(8,2),
+    protein_max DECIMAL(8,2),
+    kcal_min DECIMAL(8,2),
+    kcal_max DECIMAL(8,2),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Restrict macro_unit to the supported values
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.table_constraints WHERE constraint_name = 'check_macro_unit') THEN
+        ALTER TABLE ingredients ADD CONSTRAINT check_macro_unit CHECK (macro_unit IN ('per_unit', 'per_100g'));
+    END IF;
+    IF NOT EXISTS (SELECT 1 FROM information_schema.table_constraints WHERE constraint_name = 'check_ingredient_templates_macro_unit') THEN
+        ALTER TABLE ingredient_templates ADD CONSTRAINT check_ingredient_templates_macro_unit CHECK (macro_unit IN ('per_unit', 'per_100g'));
+    END IF;
+END $$;
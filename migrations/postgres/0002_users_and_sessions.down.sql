@@ -0,0 +1,12 @@
+-- The global UNIQUE (name) on ingredient templates is not restored: every user has their own
+-- seeded templates, so names repeat once accounts are merged
+ALTER TABLE ingredient_templates DROP CONSTRAINT IF EXISTS ingredient_templates_user_id_name_key;
+
+ALTER TABLE daily_targets DROP COLUMN IF EXISTS user_id;
+ALTER TABLE meal_templates DROP COLUMN IF EXISTS user_id;
+ALTER TABLE ingredient_templates DROP COLUMN IF EXISTS user_id;
+ALTER TABLE ingredients DROP COLUMN IF EXISTS user_id;
+ALTER TABLE meals DROP COLUMN IF EXISTS user_id;
+
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS users;
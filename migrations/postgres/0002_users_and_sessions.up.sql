@@ -0,0 +1,38 @@
+-- User accounts. Every user-scoped table gets an owner; rows logged before accounts
+-- existed keep a NULL owner until the first user registers and claims them.
+
+CREATE TABLE IF NOT EXISTS users (
+    id SERIAL PRIMARY KEY,
+    email VARCHAR(255) NOT NULL UNIQUE,
+    password_hash VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Session tokens are stored as SHA-256 hashes
+CREATE TABLE IF NOT EXISTS sessions (
+    token_hash CHAR(64) PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL
+);
+
+ALTER TABLE meals ADD COLUMN IF NOT EXISTS user_id INTEGER REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE ingredients ADD COLUMN IF NOT EXISTS user_id INTEGER REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE ingredient_templates ADD COLUMN IF NOT EXISTS user_id INTEGER REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE meal_templates ADD COLUMN IF NOT EXISTS user_id INTEGER REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE daily_targets ADD COLUMN IF NOT EXISTS user_id INTEGER REFERENCES users(id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_meals_user_id ON meals (user_id);
+CREATE INDEX IF NOT EXISTS idx_ingredients_user_id ON ingredients (user_id);
+CREATE INDEX IF NOT EXISTS idx_ingredient_templates_user_id ON ingredient_templates (user_id);
+CREATE INDEX IF NOT EXISTS idx_meal_templates_user_id ON meal_templates (user_id);
+CREATE INDEX IF NOT EXISTS idx_daily_targets_user_id ON daily_targets (user_id);
+
+-- Ingredient template names are unique per user rather than globally
+ALTER TABLE ingredient_templates DROP CONSTRAINT IF EXISTS ingredient_templates_name_key;
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.table_constraints WHERE constraint_name = 'ingredient_templates_user_id_name_key') THEN
+        ALTER TABLE ingredient_templates ADD CONSTRAINT ingredient_templates_user_id_name_key UNIQUE (user_id, name);
+    END IF;
+END $$;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_ingredients_ingredient_template_id;
+
+ALTER TABLE ingredients DROP COLUMN IF EXISTS ingredient_template_id;
@@ -0,0 +1,6 @@
+-- Remember which ingredient template a logged ingredient was copied from. The ingredient keeps
+-- its own copy of the macros; the link is cleared if the template is deleted.
+
+ALTER TABLE ingredients ADD COLUMN IF NOT EXISTS ingredient_template_id INTEGER REFERENCES ingredient_templates(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_ingredients_ingredient_template_id ON ingredients (ingredient_template_id);
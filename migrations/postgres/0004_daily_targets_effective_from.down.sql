@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_daily_targets_user_id_effective_from;
+
+ALTER TABLE daily_targets DROP COLUMN IF EXISTS effective_from;
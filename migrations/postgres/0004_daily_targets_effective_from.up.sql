@@ -0,0 +1,11 @@
+-- Daily targets apply from a date onwards instead of the newest row applying to every day.
+-- Existing targets take effect from the day they were created.
+
+ALTER TABLE daily_targets ADD COLUMN IF NOT EXISTS effective_from DATE;
+
+UPDATE daily_targets SET effective_from = COALESCE(CAST(created_at AS DATE), CURRENT_DATE) WHERE effective_from IS NULL;
+
+ALTER TABLE daily_targets ALTER COLUMN effective_from SET DEFAULT CURRENT_DATE;
+ALTER TABLE daily_targets ALTER COLUMN effective_from SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_daily_targets_user_id_effective_from ON daily_targets (user_id, effective_from);
@@ -0,0 +1,34 @@
+-- Named target profiles (e.g. training / rest days), a weekly schedule of profiles and
+-- per-date overrides. Days with neither fall back to the dated daily_targets.
+
+CREATE TABLE IF NOT EXISTS target_profiles (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    carbs_min DECIMAL(8,2),
+    carbs_max DECIMAL(8,2),
+    fat_min DECIMAL(8,2),
+    fat_max DECIMAL(8,2),
+    protein_min DECIMAL(8,2),
+    protein_max DECIMAL(8,2),
+    kcal_min DECIMAL(8,2),
+    kcal_max DECIMAL(8,2),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT target_profiles_user_id_name_key UNIQUE (user_id, name)
+);
+
+-- weekday follows Go's time.Weekday: 0 = Sunday .. 6 = Saturday
+CREATE TABLE IF NOT EXISTS target_schedule (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    weekday SMALLINT NOT NULL CHECK (weekday BETWEEN 0 AND 6),
+    profile_id INTEGER NOT NULL REFERENCES target_profiles(id) ON DELETE CASCADE,
+    PRIMARY KEY (user_id, weekday)
+);
+
+CREATE TABLE IF NOT EXISTS target_overrides (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    date DATE NOT NULL,
+    profile_id INTEGER NOT NULL REFERENCES target_profiles(id) ON DELETE CASCADE,
+    PRIMARY KEY (user_id, date)
+);
@@ -0,0 +1,10 @@
+ALTER TABLE daily_targets DROP CONSTRAINT IF EXISTS check_daily_targets_target_mode;
+
+ALTER TABLE daily_targets DROP COLUMN IF EXISTS protein_max_relative;
+ALTER TABLE daily_targets DROP COLUMN IF EXISTS protein_min_relative;
+ALTER TABLE daily_targets DROP COLUMN IF EXISTS fat_max_relative;
+ALTER TABLE daily_targets DROP COLUMN IF EXISTS fat_min_relative;
+ALTER TABLE daily_targets DROP COLUMN IF EXISTS carbs_max_relative;
+ALTER TABLE daily_targets DROP COLUMN IF EXISTS carbs_min_relative;
+ALTER TABLE daily_targets DROP COLUMN IF EXISTS body_weight_kg;
+ALTER TABLE daily_targets DROP COLUMN IF EXISTS target_mode;
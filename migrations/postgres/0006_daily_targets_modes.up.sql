@@ -0,0 +1,18 @@
+-- Daily targets can give protein/carbs/fat as a percentage of kcal or in grams per kg of body
+-- weight. The *_relative columns keep the values as entered; carbs_min..fat_max keep them in grams.
+
+ALTER TABLE daily_targets ADD COLUMN IF NOT EXISTS target_mode VARCHAR(20) NOT NULL DEFAULT 'grams';
+ALTER TABLE daily_targets ADD COLUMN IF NOT EXISTS body_weight_kg DECIMAL(6,2);
+ALTER TABLE daily_targets ADD COLUMN IF NOT EXISTS carbs_min_relative DECIMAL(8,2);
+ALTER TABLE daily_targets ADD COLUMN IF NOT EXISTS carbs_max_relative DECIMAL(8,2);
+ALTER TABLE daily_targets ADD COLUMN IF NOT EXISTS fat_min_relative DECIMAL(8,2);
+ALTER TABLE daily_targets ADD COLUMN IF NOT EXISTS fat_max_relative DECIMAL(8,2);
+ALTER TABLE daily_targets ADD COLUMN IF NOT EXISTS protein_min_relative DECIMAL(8,2);
+ALTER TABLE daily_targets ADD COLUMN IF NOT EXISTS protein_max_relative DECIMAL(8,2);
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.table_constraints WHERE constraint_name = 'check_daily_targets_target_mode') THEN
+        ALTER TABLE daily_targets ADD CONSTRAINT check_daily_targets_target_mode CHECK (target_mode IN ('grams', 'percent_kcal', 'g_per_kg'));
+    END IF;
+END $$;
@@ -0,0 +1,22 @@
+ALTER TABLE daily_targets DROP COLUMN IF EXISTS alcohol_max;
+ALTER TABLE daily_targets DROP COLUMN IF EXISTS alcohol_min;
+ALTER TABLE daily_targets DROP COLUMN IF EXISTS sodium_max;
+ALTER TABLE daily_targets DROP COLUMN IF EXISTS sodium_min;
+ALTER TABLE daily_targets DROP COLUMN IF EXISTS saturated_fat_max;
+ALTER TABLE daily_targets DROP COLUMN IF EXISTS saturated_fat_min;
+ALTER TABLE daily_targets DROP COLUMN IF EXISTS sugar_max;
+ALTER TABLE daily_targets DROP COLUMN IF EXISTS sugar_min;
+ALTER TABLE daily_targets DROP COLUMN IF EXISTS fibre_max;
+ALTER TABLE daily_targets DROP COLUMN IF EXISTS fibre_min;
+
+ALTER TABLE ingredient_templates DROP COLUMN IF EXISTS alcohol;
+ALTER TABLE ingredient_templates DROP COLUMN IF EXISTS sodium;
+ALTER TABLE ingredient_templates DROP COLUMN IF EXISTS saturated_fat;
+ALTER TABLE ingredient_templates DROP COLUMN IF EXISTS sugar;
+ALTER TABLE ingredient_templates DROP COLUMN IF EXISTS fibre;
+
+ALTER TABLE ingredients DROP COLUMN IF EXISTS alcohol;
+ALTER TABLE ingredients DROP COLUMN IF EXISTS sodium;
+ALTER TABLE ingredients DROP COLUMN IF EXISTS saturated_fat;
+ALTER TABLE ingredients DROP COLUMN IF EXISTS sugar;
+ALTER TABLE ingredients DROP COLUMN IF EXISTS fibre;
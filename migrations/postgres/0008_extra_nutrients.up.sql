@@ -0,0 +1,25 @@
+-- Fibre, sugar, saturated fat, sodium (mg) and alcohol alongside the four macros, given per the
+-- same macro unit, with optional daily targets
+
+ALTER TABLE ingredients ADD COLUMN IF NOT EXISTS fibre DECIMAL(8,2) NOT NULL DEFAULT 0;
+ALTER TABLE ingredients ADD COLUMN IF NOT EXISTS sugar DECIMAL(8,2) NOT NULL DEFAULT 0;
+ALTER TABLE ingredients ADD COLUMN IF NOT EXISTS saturated_fat DECIMAL(8,2) NOT NULL DEFAULT 0;
+ALTER TABLE ingredients ADD COLUMN IF NOT EXISTS sodium DECIMAL(8,2) NOT NULL DEFAULT 0;
+ALTER TABLE ingredients ADD COLUMN IF NOT EXISTS alcohol DECIMAL(8,2) NOT NULL DEFAULT 0;
+
+ALTER TABLE ingredient_templates ADD COLUMN IF NOT EXISTS fibre DECIMAL(8,2) NOT NULL DEFAULT 0;
+ALTER TABLE ingredient_templates ADD COLUMN IF NOT EXISTS sugar DECIMAL(8,2) NOT NULL DEFAULT 0;
+ALTER TABLE ingredient_templates ADD COLUMN IF NOT EXISTS saturated_fat DECIMAL(8,2) NOT NULL DEFAULT 0;
+ALTER TABLE ingredient_templates ADD COLUMN IF NOT EXISTS sodium DECIMAL(8,2) NOT NULL DEFAULT 0;
+ALTER TABLE ingredient_templates ADD COLUMN IF NOT EXISTS alcohol DECIMAL(8,2) NOT NULL DEFAULT 0;
+
+ALTER TABLE daily_targets ADD COLUMN IF NOT EXISTS fibre_min DECIMAL(8,2);
+ALTER TABLE daily_targets ADD COLUMN IF NOT EXISTS fibre_max DECIMAL(8,2);
+ALTER TABLE daily_targets ADD COLUMN IF NOT EXISTS sugar_min DECIMAL(8,2);
+ALTER TABLE daily_targets ADD COLUMN IF NOT EXISTS sugar_max DECIMAL(8,2);
+ALTER TABLE daily_targets ADD COLUMN IF NOT EXISTS saturated_fat_min DECIMAL(8,2);
+ALTER TABLE daily_targets ADD COLUMN IF NOT EXISTS saturated_fat_max DECIMAL(8,2);
+ALTER TABLE daily_targets ADD COLUMN IF NOT EXISTS sodium_min DECIMAL(8,2);
+ALTER TABLE daily_targets ADD COLUMN IF NOT EXISTS sodium_max DECIMAL(8,2);
+ALTER TABLE daily_targets ADD COLUMN IF NOT EXISTS alcohol_min DECIMAL(8,2);
+ALTER TABLE daily_targets ADD COLUMN IF NOT EXISTS alcohol_max DECIMAL(8,2);
@@ -0,0 +1,18 @@
+-- Quantities go back to grams or units; this fails if any rows use the new macro units
+
+UPDATE ingredients
+SET quantity = quantity * CASE quantity_unit
+        WHEN 'kg' THEN 1000 WHEN 'oz' THEN 28.349523125 WHEN 'lb' THEN 453.59237 WHEN 'serving' THEN serving_size
+        ELSE 1 END,
+    quantity_unit = 'g'
+WHERE macro_unit = 'per_100g' AND quantity_unit <> 'g';
+
+ALTER TABLE ingredient_templates DROP COLUMN IF EXISTS serving_size;
+ALTER TABLE ingredients DROP COLUMN IF EXISTS serving_size;
+ALTER TABLE ingredients DROP CONSTRAINT IF EXISTS check_ingredients_quantity_unit;
+ALTER TABLE ingredients DROP COLUMN IF EXISTS quantity_unit;
+
+ALTER TABLE ingredient_templates DROP CONSTRAINT IF EXISTS check_ingredient_templates_macro_unit;
+ALTER TABLE ingredient_templates ADD CONSTRAINT check_ingredient_templates_macro_unit CHECK (macro_unit IN ('per_unit', 'per_100g'));
+ALTER TABLE ingredients DROP CONSTRAINT IF EXISTS check_macro_unit;
+ALTER TABLE ingredients ADD CONSTRAINT check_macro_unit CHECK (macro_unit IN ('per_unit', 'per_100g'));
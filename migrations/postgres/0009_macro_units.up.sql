@@ -0,0 +1,21 @@
+-- Macros can also be given per 100ml, per ounce or per serving. Ingredients record the unit
+-- their quantity was entered in (see units.go), and a serving size in grams converts between
+-- servings and weights.
+
+ALTER TABLE ingredients DROP CONSTRAINT IF EXISTS check_macro_unit;
+ALTER TABLE ingredients ADD CONSTRAINT check_macro_unit CHECK (macro_unit IN ('per_unit', 'per_100g', 'per_100ml', 'per_oz', 'per_serving'));
+ALTER TABLE ingredient_templates DROP CONSTRAINT IF EXISTS check_ingredient_templates_macro_unit;
+ALTER TABLE ingredient_templates ADD CONSTRAINT check_ingredient_templates_macro_unit CHECK (macro_unit IN ('per_unit', 'per_100g', 'per_100ml', 'per_oz', 'per_serving'));
+
+ALTER TABLE ingredients ADD COLUMN IF NOT EXISTS quantity_unit VARCHAR(20) NOT NULL DEFAULT 'unit';
+UPDATE ingredients SET quantity_unit = 'g' WHERE macro_unit = 'per_100g';
+ALTER TABLE ingredients ADD COLUMN IF NOT EXISTS serving_size DECIMAL(8,2);
+ALTER TABLE ingredient_templates ADD COLUMN IF NOT EXISTS serving_size DECIMAL(8,2);
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.table_constraints WHERE constraint_name = 'check_ingredients_quantity_unit') THEN
+        ALTER TABLE ingredients ADD CONSTRAINT check_ingredients_quantity_unit
+            CHECK (quantity_unit IN ('unit', 'g', 'kg', 'oz', 'lb', 'ml', 'l', 'tsp', 'tbsp', 'fl_oz', 'cup', 'serving'));
+    END IF;
+END $$;
@@ -0,0 +1,19 @@
+-- Packaged products imported from an Open Food Facts dump, looked up by barcode. Shared by all
+-- users; macros and nutrients are per 100g, sodium in mg.
+
+CREATE TABLE IF NOT EXISTS products (
+    code VARCHAR(14) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    brands VARCHAR(255) NOT NULL DEFAULT '',
+    carbs DECIMAL(8,2) NOT NULL DEFAULT 0,
+    fat DECIMAL(8,2) NOT NULL DEFAULT 0,
+    protein DECIMAL(8,2) NOT NULL DEFAULT 0,
+    kcal DECIMAL(8,2) NOT NULL DEFAULT 0,
+    fibre DECIMAL(8,2) NOT NULL DEFAULT 0,
+    sugar DECIMAL(8,2) NOT NULL DEFAULT 0,
+    saturated_fat DECIMAL(8,2) NOT NULL DEFAULT 0,
+    sodium DECIMAL(8,2) NOT NULL DEFAULT 0,
+    alcohol DECIMAL(8,2) NOT NULL DEFAULT 0,
+    serving_size DECIMAL(8,2),
+    imported_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
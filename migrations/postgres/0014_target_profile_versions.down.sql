@@ -0,0 +1,16 @@
+ALTER TABLE target_profiles ADD COLUMN IF NOT EXISTS carbs_min DECIMAL(8,2);
+ALTER TABLE target_profiles ADD COLUMN IF NOT EXISTS carbs_max DECIMAL(8,2);
+ALTER TABLE target_profiles ADD COLUMN IF NOT EXISTS fat_min DECIMAL(8,2);
+ALTER TABLE target_profiles ADD COLUMN IF NOT EXISTS fat_max DECIMAL(8,2);
+ALTER TABLE target_profiles ADD COLUMN IF NOT EXISTS protein_min DECIMAL(8,2);
+ALTER TABLE target_profiles ADD COLUMN IF NOT EXISTS protein_max DECIMAL(8,2);
+ALTER TABLE target_profiles ADD COLUMN IF NOT EXISTS kcal_min DECIMAL(8,2);
+ALTER TABLE target_profiles ADD COLUMN IF NOT EXISTS kcal_max DECIMAL(8,2);
+
+-- Profiles keep the targets of their latest version
+UPDATE target_profiles SET carbs_min = v.carbs_min, carbs_max = v.carbs_max, fat_min = v.fat_min, fat_max = v.fat_max, protein_min = v.protein_min, protein_max = v.protein_max, kcal_min = v.kcal_min, kcal_max = v.kcal_max
+FROM target_profile_versions v
+WHERE v.profile_id = target_profiles.id
+  AND v.effective_from = (SELECT MAX(effective_from) FROM target_profile_versions WHERE profile_id = target_profiles.id);
+
+DROP TABLE IF EXISTS target_profile_versions;
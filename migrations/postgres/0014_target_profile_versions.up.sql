@@ -0,0 +1,53 @@
+-- Target profiles keep dated versions of their targets, as daily_targets does, so editing a
+-- profile does not change the targets of days already past. A version has the same targets as a
+-- daily_targets row, including the target mode and nutrient targets.
+
+CREATE TABLE IF NOT EXISTS target_profile_versions (
+    id SERIAL PRIMARY KEY,
+    profile_id INTEGER NOT NULL REFERENCES target_profiles(id) ON DELETE CASCADE,
+    effective_from DATE NOT NULL DEFAULT CURRENT_DATE,
+    target_mode VARCHAR(20) NOT NULL DEFAULT 'grams'
+        CONSTRAINT check_target_profile_versions_target_mode CHECK (target_mode IN ('grams', 'percent_kcal', 'g_per_kg')),
+    body_weight_kg DECIMAL(6,2),
+    carbs_min DECIMAL(8,2),
+    carbs_max DECIMAL(8,2),
+    fat_min DECIMAL(8,2),
+    fat_max DECIMAL(8,2),
+    protein_min DECIMAL(8,2),
+    protein_max DECIMAL(8,2),
+    kcal_min DECIMAL(8,2),
+    kcal_max DECIMAL(8,2),
+    fibre_min DECIMAL(8,2),
+    fibre_max DECIMAL(8,2),
+    sugar_min DECIMAL(8,2),
+    sugar_max DECIMAL(8,2),
+    saturated_fat_min DECIMAL(8,2),
+    saturated_fat_max DECIMAL(8,2),
+    sodium_min DECIMAL(8,2),
+    sodium_max DECIMAL(8,2),
+    alcohol_min DECIMAL(8,2),
+    alcohol_max DECIMAL(8,2),
+    carbs_min_relative DECIMAL(8,2),
+    carbs_max_relative DECIMAL(8,2),
+    fat_min_relative DECIMAL(8,2),
+    fat_max_relative DECIMAL(8,2),
+    protein_min_relative DECIMAL(8,2),
+    protein_max_relative DECIMAL(8,2),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT target_profile_versions_profile_id_effective_from_key UNIQUE (profile_id, effective_from)
+);
+
+-- Existing profiles become their first version, from the day they were created
+INSERT INTO target_profile_versions (profile_id, effective_from, carbs_min, carbs_max, fat_min, fat_max, protein_min, protein_max, kcal_min, kcal_max, created_at, updated_at)
+SELECT id, COALESCE(CAST(created_at AS DATE), CURRENT_DATE), carbs_min, carbs_max, fat_min, fat_max, protein_min, protein_max, kcal_min, kcal_max, created_at, updated_at
+FROM target_profiles;
+
+ALTER TABLE target_profiles DROP COLUMN IF EXISTS carbs_min;
+ALTER TABLE target_profiles DROP COLUMN IF EXISTS carbs_max;
+ALTER TABLE target_profiles DROP COLUMN IF EXISTS fat_min;
+ALTER TABLE target_profiles DROP COLUMN IF EXISTS fat_max;
+ALTER TABLE target_profiles DROP COLUMN IF EXISTS protein_min;
+ALTER TABLE target_profiles DROP COLUMN IF EXISTS protein_max;
+ALTER TABLE target_profiles DROP COLUMN IF EXISTS kcal_min;
+ALTER TABLE target_profiles DROP COLUMN IF EXISTS kcal_max;
@@ -0,0 +1,4 @@
+-- pg_trgm is left installed, as other database objects may have come to depend on it
+
+ALTER TABLE ingredient_templates DROP COLUMN IF EXISTS last_used_at;
+ALTER TABLE ingredient_templates DROP COLUMN IF EXISTS use_count;
@@ -0,0 +1,29 @@
+-- How often and how recently meals used each ingredient template, kept up to date as meals are
+-- written so that search can rank templates without aggregating every logged ingredient.
+-- pg_trgm lets search shortlist misspelt names in SQL. Creating it needs superuser rights (or,
+-- from PostgreSQL 13, CREATE rights on the database); without them the migration still applies
+-- and search matches by prefix and substring only until pg_trgm is installed.
+
+DO $$
+BEGIN
+    CREATE EXTENSION IF NOT EXISTS pg_trgm;
+EXCEPTION WHEN insufficient_privilege OR undefined_file THEN
+    RAISE NOTICE 'pg_trgm not installed: %', SQLERRM;
+END
+$$;
+
+ALTER TABLE ingredient_templates ADD COLUMN IF NOT EXISTS use_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE ingredient_templates ADD COLUMN IF NOT EXISTS last_used_at TIMESTAMP;
+
+UPDATE ingredient_templates SET
+    use_count = (
+        SELECT COUNT(*) FROM ingredients i
+        JOIN meal_ingredients mi ON mi.ingredient_id = i.id
+        WHERE i.ingredient_template_id = ingredient_templates.id
+    ),
+    last_used_at = (
+        SELECT MAX(m.datetime) FROM ingredients i
+        JOIN meal_ingredients mi ON mi.ingredient_id = i.id
+        JOIN meals m ON m.id = mi.meal_id
+        WHERE i.ingredient_template_id = ingredient_templates.id
+    );
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS daily_targets;
+DROP TABLE IF EXISTS meal_template_ingredients;
+DROP TABLE IF EXISTS meal_templates;
+DROP TABLE IF EXISTS ingredient_templates;
+DROP TABLE IF EXISTS meal_ingredients;
+DROP TABLE IF EXISTS ingredients;
+DROP TABLE IF EXISTS meals;
@@ -0,0 +1,69 @@
+-- Core schema (SQLite)
+
+CREATE TABLE meals (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL,
+    datetime TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_meals_datetime ON meals (datetime);
+
+CREATE TABLE ingredients (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL,
+    quantity DECIMAL(8,2) NOT NULL DEFAULT 1,
+    carbs DECIMAL(8,2) NOT NULL DEFAULT 0,
+    fat DECIMAL(8,2) NOT NULL DEFAULT 0,
+    protein DECIMAL(8,2) NOT NULL DEFAULT 0,
+    kcal DECIMAL(8,2) NOT NULL DEFAULT 0,
+    macro_unit VARCHAR(20) NOT NULL DEFAULT 'per_unit' CONSTRAINT check_macro_unit CHECK (macro_unit IN ('per_unit', 'per_100g'))
+);
+
+CREATE TABLE meal_ingredients (
+    meal_id INTEGER REFERENCES meals(id) ON DELETE CASCADE,
+    ingredient_id INTEGER REFERENCES ingredients(id) ON DELETE CASCADE,
+    PRIMARY KEY (meal_id, ingredient_id)
+);
+
+-- Unlike Postgres there is no global UNIQUE (name) to drop later; names are unique per user from 0002
+CREATE TABLE ingredient_templates (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL,
+    carbs DECIMAL(8,2) NOT NULL DEFAULT 0,
+    fat DECIMAL(8,2) NOT NULL DEFAULT 0,
+    protein DECIMAL(8,2) NOT NULL DEFAULT 0,
+    kcal DECIMAL(8,2) NOT NULL DEFAULT 0,
+    macro_unit VARCHAR(20) NOT NULL DEFAULT 'per_unit' CONSTRAINT check_ingredient_templates_macro_unit CHECK (macro_unit IN ('per_unit', 'per_100g')),
+    default_quantity DECIMAL(8,2) DEFAULT 1,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE meal_templates (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE meal_template_ingredients (
+    meal_template_id INTEGER REFERENCES meal_templates(id) ON DELETE CASCADE,
+    ingredient_template_id INTEGER REFERENCES ingredient_templates(id) ON DELETE CASCADE,
+    quantity DECIMAL(8,2) NOT NULL DEFAULT 1,
+    PRIMARY KEY (meal_template_id, ingredient_template_id)
+);
+
+CREATE TABLE daily_targets (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    carbs_min DECIMAL(8,2),
+    carbs_max DECIMAL(8,2),
+    fat_min DECIMAL(8,2),
+    fat_max DECIMAL(8,2),
+    protein_min DECIMAL(8,2),
+    protein_max DECIMAL(8,2),
+    kcal_min DECIMAL(8,2),
+    kcal_max DECIMAL(8,2),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
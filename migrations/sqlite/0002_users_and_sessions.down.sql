@@ -0,0 +1,94 @@
+-- User accounts (SQLite). The owner columns have foreign keys, which SQLite cannot drop, so
+-- each table is rebuilt without its owner. Every user's rows are kept.
+
+CREATE TABLE meals_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL,
+    datetime TIMESTAMP NOT NULL
+);
+
+INSERT INTO meals_old (id, name, datetime)
+SELECT id, name, datetime
+FROM meals;
+
+DROP TABLE meals;
+ALTER TABLE meals_old RENAME TO meals;
+CREATE INDEX idx_meals_datetime ON meals (datetime);
+
+CREATE TABLE ingredients_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL,
+    quantity DECIMAL(8,2) NOT NULL DEFAULT 1,
+    carbs DECIMAL(8,2) NOT NULL DEFAULT 0,
+    fat DECIMAL(8,2) NOT NULL DEFAULT 0,
+    protein DECIMAL(8,2) NOT NULL DEFAULT 0,
+    kcal DECIMAL(8,2) NOT NULL DEFAULT 0,
+    macro_unit VARCHAR(20) NOT NULL DEFAULT 'per_unit' CONSTRAINT check_macro_unit CHECK (macro_unit IN ('per_unit', 'per_100g'))
+);
+
+INSERT INTO ingredients_old (id, name, quantity, carbs, fat, protein, kcal, macro_unit)
+SELECT id, name, quantity, carbs, fat, protein, kcal, macro_unit
+FROM ingredients;
+
+DROP TABLE ingredients;
+ALTER TABLE ingredients_old RENAME TO ingredients;
+
+-- As in 0001, template names are not unique, since users may have chosen the same ones
+CREATE TABLE ingredient_templates_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL,
+    carbs DECIMAL(8,2) NOT NULL DEFAULT 0,
+    fat DECIMAL(8,2) NOT NULL DEFAULT 0,
+    protein DECIMAL(8,2) NOT NULL DEFAULT 0,
+    kcal DECIMAL(8,2) NOT NULL DEFAULT 0,
+    macro_unit VARCHAR(20) NOT NULL DEFAULT 'per_unit' CONSTRAINT check_ingredient_templates_macro_unit CHECK (macro_unit IN ('per_unit', 'per_100g')),
+    default_quantity DECIMAL(8,2) DEFAULT 1,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO ingredient_templates_old (id, name, carbs, fat, protein, kcal, macro_unit, default_quantity, created_at, updated_at)
+SELECT id, name, carbs, fat, protein, kcal, macro_unit, default_quantity, created_at, updated_at
+FROM ingredient_templates;
+
+DROP TABLE ingredient_templates;
+ALTER TABLE ingredient_templates_old RENAME TO ingredient_templates;
+
+CREATE TABLE meal_templates_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO meal_templates_old (id, name, description, created_at, updated_at)
+SELECT id, name, description, created_at, updated_at
+FROM meal_templates;
+
+DROP TABLE meal_templates;
+ALTER TABLE meal_templates_old RENAME TO meal_templates;
+
+CREATE TABLE daily_targets_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    carbs_min DECIMAL(8,2),
+    carbs_max DECIMAL(8,2),
+    fat_min DECIMAL(8,2),
+    fat_max DECIMAL(8,2),
+    protein_min DECIMAL(8,2),
+    protein_max DECIMAL(8,2),
+    kcal_min DECIMAL(8,2),
+    kcal_max DECIMAL(8,2),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO daily_targets_old (id, carbs_min, carbs_max, fat_min, fat_max, protein_min, protein_max, kcal_min, kcal_max, created_at, updated_at)
+SELECT id, carbs_min, carbs_max, fat_min, fat_max, protein_min, protein_max, kcal_min, kcal_max, created_at, updated_at
+FROM daily_targets;
+
+DROP TABLE daily_targets;
+ALTER TABLE daily_targets_old RENAME TO daily_targets;
+
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,30 @@
+-- User accounts (SQLite)
+
+CREATE TABLE users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    email VARCHAR(255) NOT NULL UNIQUE,
+    password_hash VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Session tokens are stored as SHA-256 hashes
+CREATE TABLE sessions (
+    token_hash CHAR(64) PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL
+);
+
+ALTER TABLE meals ADD COLUMN user_id INTEGER REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE ingredients ADD COLUMN user_id INTEGER REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE ingredient_templates ADD COLUMN user_id INTEGER REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE meal_templates ADD COLUMN user_id INTEGER REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE daily_targets ADD COLUMN user_id INTEGER REFERENCES users(id) ON DELETE CASCADE;
+
+CREATE INDEX idx_meals_user_id ON meals (user_id);
+CREATE INDEX idx_ingredients_user_id ON ingredients (user_id);
+CREATE INDEX idx_meal_templates_user_id ON meal_templates (user_id);
+CREATE INDEX idx_daily_targets_user_id ON daily_targets (user_id);
+
+-- Ingredient template names are unique per user
+CREATE UNIQUE INDEX ingredient_templates_user_id_name_key ON ingredient_templates (user_id, name);
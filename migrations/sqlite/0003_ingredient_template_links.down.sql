@@ -0,0 +1,22 @@
+-- Ingredient template links (SQLite). The column has a foreign key, which SQLite cannot drop,
+-- so the table is rebuilt without it.
+
+CREATE TABLE ingredients_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    quantity DECIMAL(8,2) NOT NULL DEFAULT 1,
+    carbs DECIMAL(8,2) NOT NULL DEFAULT 0,
+    fat DECIMAL(8,2) NOT NULL DEFAULT 0,
+    protein DECIMAL(8,2) NOT NULL DEFAULT 0,
+    kcal DECIMAL(8,2) NOT NULL DEFAULT 0,
+    macro_unit VARCHAR(20) NOT NULL DEFAULT 'per_unit' CONSTRAINT check_macro_unit CHECK (macro_unit IN ('per_unit', 'per_100g'))
+);
+
+INSERT INTO ingredients_old (id, user_id, name, quantity, carbs, fat, protein, kcal, macro_unit)
+SELECT id, user_id, name, quantity, carbs, fat, protein, kcal, macro_unit
+FROM ingredients;
+
+DROP TABLE ingredients;
+ALTER TABLE ingredients_old RENAME TO ingredients;
+CREATE INDEX idx_ingredients_user_id ON ingredients (user_id);
@@ -0,0 +1,5 @@
+-- Ingredient template links (SQLite)
+
+ALTER TABLE ingredients ADD COLUMN ingredient_template_id INTEGER REFERENCES ingredient_templates(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_ingredients_ingredient_template_id ON ingredients (ingredient_template_id);
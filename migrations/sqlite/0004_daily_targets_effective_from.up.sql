@@ -0,0 +1,8 @@
+-- Daily targets effective dates (SQLite). SQLite cannot add a NOT NULL column without a constant
+-- default, so the column stays nullable; the application always sets it.
+
+ALTER TABLE daily_targets ADD COLUMN effective_from DATE;
+
+UPDATE daily_targets SET effective_from = COALESCE(DATE(created_at), DATE('now'));
+
+CREATE INDEX idx_daily_targets_user_id_effective_from ON daily_targets (user_id, effective_from);
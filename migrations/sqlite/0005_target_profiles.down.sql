@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS target_overrides;
+DROP TABLE IF EXISTS target_schedule;
+DROP TABLE IF EXISTS target_profiles;
@@ -0,0 +1,8 @@
+ALTER TABLE daily_targets DROP COLUMN protein_max_relative;
+ALTER TABLE daily_targets DROP COLUMN protein_min_relative;
+ALTER TABLE daily_targets DROP COLUMN fat_max_relative;
+ALTER TABLE daily_targets DROP COLUMN fat_min_relative;
+ALTER TABLE daily_targets DROP COLUMN carbs_max_relative;
+ALTER TABLE daily_targets DROP COLUMN carbs_min_relative;
+ALTER TABLE daily_targets DROP COLUMN body_weight_kg;
+ALTER TABLE daily_targets DROP COLUMN target_mode;
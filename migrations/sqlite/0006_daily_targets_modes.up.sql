@@ -0,0 +1,12 @@
+-- Daily targets can give protein/carbs/fat as a percentage of kcal or in grams per kg of body
+-- weight (SQLite). The *_relative columns keep the values as entered.
+
+ALTER TABLE daily_targets ADD COLUMN target_mode VARCHAR(20) NOT NULL DEFAULT 'grams'
+    CONSTRAINT check_daily_targets_target_mode CHECK (target_mode IN ('grams', 'percent_kcal', 'g_per_kg'));
+ALTER TABLE daily_targets ADD COLUMN body_weight_kg DECIMAL(6,2);
+ALTER TABLE daily_targets ADD COLUMN carbs_min_relative DECIMAL(8,2);
+ALTER TABLE daily_targets ADD COLUMN carbs_max_relative DECIMAL(8,2);
+ALTER TABLE daily_targets ADD COLUMN fat_min_relative DECIMAL(8,2);
+ALTER TABLE daily_targets ADD COLUMN fat_max_relative DECIMAL(8,2);
+ALTER TABLE daily_targets ADD COLUMN protein_min_relative DECIMAL(8,2);
+ALTER TABLE daily_targets ADD COLUMN protein_max_relative DECIMAL(8,2);
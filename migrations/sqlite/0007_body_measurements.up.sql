@@ -0,0 +1,13 @@
+-- Body weight, body fat and waist measurements, at most one per user per day (SQLite)
+
+CREATE TABLE body_measurements (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    date DATE NOT NULL,
+    weight_kg DECIMAL(6,2),
+    body_fat_percent DECIMAL(5,2),
+    waist_cm DECIMAL(6,2),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT body_measurements_user_id_date_key UNIQUE (user_id, date)
+);
@@ -0,0 +1,22 @@
+ALTER TABLE daily_targets DROP COLUMN alcohol_max;
+ALTER TABLE daily_targets DROP COLUMN alcohol_min;
+ALTER TABLE daily_targets DROP COLUMN sodium_max;
+ALTER TABLE daily_targets DROP COLUMN sodium_min;
+ALTER TABLE daily_targets DROP COLUMN saturated_fat_max;
+ALTER TABLE daily_targets DROP COLUMN saturated_fat_min;
+ALTER TABLE daily_targets DROP COLUMN sugar_max;
+ALTER TABLE daily_targets DROP COLUMN sugar_min;
+ALTER TABLE daily_targets DROP COLUMN fibre_max;
+ALTER TABLE daily_targets DROP COLUMN fibre_min;
+
+ALTER TABLE ingredient_templates DROP COLUMN alcohol;
+ALTER TABLE ingredient_templates DROP COLUMN sodium;
+ALTER TABLE ingredient_templates DROP COLUMN saturated_fat;
+ALTER TABLE ingredient_templates DROP COLUMN sugar;
+ALTER TABLE ingredient_templates DROP COLUMN fibre;
+
+ALTER TABLE ingredients DROP COLUMN alcohol;
+ALTER TABLE ingredients DROP COLUMN sodium;
+ALTER TABLE ingredients DROP COLUMN saturated_fat;
+ALTER TABLE ingredients DROP COLUMN sugar;
+ALTER TABLE ingredients DROP COLUMN fibre;
@@ -0,0 +1,25 @@
+-- Fibre, sugar, saturated fat, sodium (mg) and alcohol alongside the four macros, given per the
+-- same macro unit, with optional daily targets (SQLite)
+
+ALTER TABLE ingredients ADD COLUMN fibre DECIMAL(8,2) NOT NULL DEFAULT 0;
+ALTER TABLE ingredients ADD COLUMN sugar DECIMAL(8,2) NOT NULL DEFAULT 0;
+ALTER TABLE ingredients ADD COLUMN saturated_fat DECIMAL(8,2) NOT NULL DEFAULT 0;
+ALTER TABLE ingredients ADD COLUMN sodium DECIMAL(8,2) NOT NULL DEFAULT 0;
+ALTER TABLE ingredients ADD COLUMN alcohol DECIMAL(8,2) NOT NULL DEFAULT 0;
+
+ALTER TABLE ingredient_templates ADD COLUMN fibre DECIMAL(8,2) NOT NULL DEFAULT 0;
+ALTER TABLE ingredient_templates ADD COLUMN sugar DECIMAL(8,2) NOT NULL DEFAULT 0;
+ALTER TABLE ingredient_templates ADD COLUMN saturated_fat DECIMAL(8,2) NOT NULL DEFAULT 0;
+ALTER TABLE ingredient_templates ADD COLUMN sodium DECIMAL(8,2) NOT NULL DEFAULT 0;
+ALTER TABLE ingredient_templates ADD COLUMN alcohol DECIMAL(8,2) NOT NULL DEFAULT 0;
+
+ALTER TABLE daily_targets ADD COLUMN fibre_min DECIMAL(8,2);
+ALTER TABLE daily_targets ADD COLUMN fibre_max DECIMAL(8,2);
+ALTER TABLE daily_targets ADD COLUMN sugar_min DECIMAL(8,2);
+ALTER TABLE daily_targets ADD COLUMN sugar_max DECIMAL(8,2);
+ALTER TABLE daily_targets ADD COLUMN saturated_fat_min DECIMAL(8,2);
+ALTER TABLE daily_targets ADD COLUMN saturated_fat_max DECIMAL(8,2);
+ALTER TABLE daily_targets ADD COLUMN sodium_min DECIMAL(8,2);
+ALTER TABLE daily_targets ADD COLUMN sodium_max DECIMAL(8,2);
+ALTER TABLE daily_targets ADD COLUMN alcohol_min DECIMAL(8,2);
+ALTER TABLE daily_targets ADD COLUMN alcohol_max DECIMAL(8,2);
@@ -0,0 +1,59 @@
+-- Quantities go back to grams or units; this fails if any rows use the new macro units (SQLite)
+
+CREATE TABLE ingredients_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    quantity DECIMAL(8,2) NOT NULL DEFAULT 1,
+    carbs DECIMAL(8,2) NOT NULL DEFAULT 0,
+    fat DECIMAL(8,2) NOT NULL DEFAULT 0,
+    protein DECIMAL(8,2) NOT NULL DEFAULT 0,
+    kcal DECIMAL(8,2) NOT NULL DEFAULT 0,
+    fibre DECIMAL(8,2) NOT NULL DEFAULT 0,
+    sugar DECIMAL(8,2) NOT NULL DEFAULT 0,
+    saturated_fat DECIMAL(8,2) NOT NULL DEFAULT 0,
+    sodium DECIMAL(8,2) NOT NULL DEFAULT 0,
+    alcohol DECIMAL(8,2) NOT NULL DEFAULT 0,
+    macro_unit VARCHAR(20) NOT NULL DEFAULT 'per_unit' CONSTRAINT check_macro_unit CHECK (macro_unit IN ('per_unit', 'per_100g')),
+    ingredient_template_id INTEGER REFERENCES ingredient_templates(id) ON DELETE SET NULL
+);
+
+INSERT INTO ingredients_old (id, user_id, name, quantity, carbs, fat, protein, kcal, fibre, sugar, saturated_fat, sodium, alcohol, macro_unit, ingredient_template_id)
+SELECT id, user_id, name,
+       quantity * CASE quantity_unit
+           WHEN 'kg' THEN 1000 WHEN 'oz' THEN 28.349523125 WHEN 'lb' THEN 453.59237 WHEN 'serving' THEN serving_size
+           ELSE 1 END,
+       carbs, fat, protein, kcal, fibre, sugar, saturated_fat, sodium, alcohol, macro_unit, ingredient_template_id
+FROM ingredients;
+
+DROP TABLE ingredients;
+ALTER TABLE ingredients_old RENAME TO ingredients;
+CREATE INDEX idx_ingredients_user_id ON ingredients (user_id);
+CREATE INDEX idx_ingredients_ingredient_template_id ON ingredients (ingredient_template_id);
+
+CREATE TABLE ingredient_templates_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    carbs DECIMAL(8,2) NOT NULL DEFAULT 0,
+    fat DECIMAL(8,2) NOT NULL DEFAULT 0,
+    protein DECIMAL(8,2) NOT NULL DEFAULT 0,
+    kcal DECIMAL(8,2) NOT NULL DEFAULT 0,
+    fibre DECIMAL(8,2) NOT NULL DEFAULT 0,
+    sugar DECIMAL(8,2) NOT NULL DEFAULT 0,
+    saturated_fat DECIMAL(8,2) NOT NULL DEFAULT 0,
+    sodium DECIMAL(8,2) NOT NULL DEFAULT 0,
+    alcohol DECIMAL(8,2) NOT NULL DEFAULT 0,
+    macro_unit VARCHAR(20) NOT NULL DEFAULT 'per_unit' CONSTRAINT check_ingredient_templates_macro_unit CHECK (macro_unit IN ('per_unit', 'per_100g')),
+    default_quantity DECIMAL(8,2) DEFAULT 1,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO ingredient_templates_old (id, user_id, name, carbs, fat, protein, kcal, fibre, sugar, saturated_fat, sodium, alcohol, macro_unit, default_quantity, created_at, updated_at)
+SELECT id, user_id, name, carbs, fat, protein, kcal, fibre, sugar, saturated_fat, sodium, alcohol, macro_unit, default_quantity, created_at, updated_at
+FROM ingredient_templates;
+
+DROP TABLE ingredient_templates;
+ALTER TABLE ingredient_templates_old RENAME TO ingredient_templates;
+CREATE UNIQUE INDEX ingredient_templates_user_id_name_key ON ingredient_templates (user_id, name);
@@ -0,0 +1,65 @@
+-- Macros can also be given per 100ml, per ounce or per serving. Ingredients record the unit
+-- their quantity was entered in (see units.go), and a serving size in grams converts between
+-- servings and weights (SQLite). SQLite cannot change a CHECK constraint, so both tables are
+-- rebuilt; migrations run with foreign keys off so references to them are kept.
+
+CREATE TABLE ingredient_templates_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    carbs DECIMAL(8,2) NOT NULL DEFAULT 0,
+    fat DECIMAL(8,2) NOT NULL DEFAULT 0,
+    protein DECIMAL(8,2) NOT NULL DEFAULT 0,
+    kcal DECIMAL(8,2) NOT NULL DEFAULT 0,
+    fibre DECIMAL(8,2) NOT NULL DEFAULT 0,
+    sugar DECIMAL(8,2) NOT NULL DEFAULT 0,
+    saturated_fat DECIMAL(8,2) NOT NULL DEFAULT 0,
+    sodium DECIMAL(8,2) NOT NULL DEFAULT 0,
+    alcohol DECIMAL(8,2) NOT NULL DEFAULT 0,
+    macro_unit VARCHAR(20) NOT NULL DEFAULT 'per_unit' CONSTRAINT check_ingredient_templates_macro_unit
+        CHECK (macro_unit IN ('per_unit', 'per_100g', 'per_100ml', 'per_oz', 'per_serving')),
+    serving_size DECIMAL(8,2),
+    default_quantity DECIMAL(8,2) DEFAULT 1,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO ingredient_templates_new (id, user_id, name, carbs, fat, protein, kcal, fibre, sugar, saturated_fat, sodium, alcohol, macro_unit, default_quantity, created_at, updated_at)
+SELECT id, user_id, name, carbs, fat, protein, kcal, fibre, sugar, saturated_fat, sodium, alcohol, macro_unit, default_quantity, created_at, updated_at
+FROM ingredient_templates;
+
+DROP TABLE ingredient_templates;
+ALTER TABLE ingredient_templates_new RENAME TO ingredient_templates;
+CREATE UNIQUE INDEX ingredient_templates_user_id_name_key ON ingredient_templates (user_id, name);
+
+CREATE TABLE ingredients_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    quantity DECIMAL(8,2) NOT NULL DEFAULT 1,
+    quantity_unit VARCHAR(20) NOT NULL DEFAULT 'unit' CONSTRAINT check_ingredients_quantity_unit
+        CHECK (quantity_unit IN ('unit', 'g', 'kg', 'oz', 'lb', 'ml', 'l', 'tsp', 'tbsp', 'fl_oz', 'cup', 'serving')),
+    carbs DECIMAL(8,2) NOT NULL DEFAULT 0,
+    fat DECIMAL(8,2) NOT NULL DEFAULT 0,
+    protein DECIMAL(8,2) NOT NULL DEFAULT 0,
+    kcal DECIMAL(8,2) NOT NULL DEFAULT 0,
+    fibre DECIMAL(8,2) NOT NULL DEFAULT 0,
+    sugar DECIMAL(8,2) NOT NULL DEFAULT 0,
+    saturated_fat DECIMAL(8,2) NOT NULL DEFAULT 0,
+    sodium DECIMAL(8,2) NOT NULL DEFAULT 0,
+    alcohol DECIMAL(8,2) NOT NULL DEFAULT 0,
+    macro_unit VARCHAR(20) NOT NULL DEFAULT 'per_unit' CONSTRAINT check_macro_unit
+        CHECK (macro_unit IN ('per_unit', 'per_100g', 'per_100ml', 'per_oz', 'per_serving')),
+    serving_size DECIMAL(8,2),
+    ingredient_template_id INTEGER REFERENCES ingredient_templates(id) ON DELETE SET NULL
+);
+
+INSERT INTO ingredients_new (id, user_id, name, quantity, quantity_unit, carbs, fat, protein, kcal, fibre, sugar, saturated_fat, sodium, alcohol, macro_unit, ingredient_template_id)
+SELECT id, user_id, name, quantity, CASE WHEN macro_unit = 'per_100g' THEN 'g' ELSE 'unit' END,
+       carbs, fat, protein, kcal, fibre, sugar, saturated_fat, sodium, alcohol, macro_unit, ingredient_template_id
+FROM ingredients;
+
+DROP TABLE ingredients;
+ALTER TABLE ingredients_new RENAME TO ingredients;
+CREATE INDEX idx_ingredients_user_id ON ingredients (user_id);
+CREATE INDEX idx_ingredients_ingredient_template_id ON ingredients (ingredient_template_id);
@@ -0,0 +1,11 @@
+-- Named household measures of an ingredient template in grams, e.g. 1 slice = 30g (SQLite)
+
+CREATE TABLE ingredient_portions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    ingredient_template_id INTEGER NOT NULL REFERENCES ingredient_templates(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    grams DECIMAL(8,2) NOT NULL CONSTRAINT check_ingredient_portions_grams CHECK (grams > 0),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT ingredient_portions_template_id_name_key UNIQUE (ingredient_template_id, name)
+);
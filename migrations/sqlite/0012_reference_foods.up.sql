@@ -0,0 +1,21 @@
+-- Reference foods imported from a food composition database (USDA FoodData Central or UK CoFID),
+-- shared by all users. Macros and nutrients are per 100g, sodium in mg (SQLite)
+
+CREATE TABLE reference_foods (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    source VARCHAR(20) NOT NULL,
+    source_id VARCHAR(50) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    category VARCHAR(255) NOT NULL DEFAULT '',
+    carbs DECIMAL(8,2) NOT NULL DEFAULT 0,
+    fat DECIMAL(8,2) NOT NULL DEFAULT 0,
+    protein DECIMAL(8,2) NOT NULL DEFAULT 0,
+    kcal DECIMAL(8,2) NOT NULL DEFAULT 0,
+    fibre DECIMAL(8,2) NOT NULL DEFAULT 0,
+    sugar DECIMAL(8,2) NOT NULL DEFAULT 0,
+    saturated_fat DECIMAL(8,2) NOT NULL DEFAULT 0,
+    sodium DECIMAL(8,2) NOT NULL DEFAULT 0,
+    alcohol DECIMAL(8,2) NOT NULL DEFAULT 0,
+    imported_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT reference_foods_source_source_id_key UNIQUE (source, source_id)
+);
@@ -0,0 +1,5 @@
+-- Recipe ingredients are not linked to any meal, so they go with their recipes (SQLite)
+
+DELETE FROM ingredients WHERE id IN (SELECT ingredient_id FROM recipe_ingredients);
+DROP TABLE IF EXISTS recipe_ingredients;
+DROP TABLE IF EXISTS recipes;
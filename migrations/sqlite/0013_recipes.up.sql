@@ -0,0 +1,20 @@
+-- Recipes: raw ingredients (rows of ingredients, linked like meal_ingredients) with the weight of
+-- the cooked dish and the servings it makes, from which per 100g and per serving macros follow (SQLite)
+
+CREATE TABLE recipes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    cooked_weight DECIMAL(8,2) NOT NULL CONSTRAINT check_recipes_cooked_weight CHECK (cooked_weight > 0),
+    servings DECIMAL(8,2) NOT NULL DEFAULT 1 CONSTRAINT check_recipes_servings CHECK (servings > 0),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT recipes_user_id_name_key UNIQUE (user_id, name)
+);
+
+CREATE TABLE recipe_ingredients (
+    recipe_id INTEGER NOT NULL REFERENCES recipes(id) ON DELETE CASCADE,
+    ingredient_id INTEGER NOT NULL REFERENCES ingredients(id) ON DELETE CASCADE,
+    PRIMARY KEY (recipe_id, ingredient_id)
+);
@@ -0,0 +1,2 @@
+ALTER TABLE ingredient_templates DROP COLUMN last_used_at;
+ALTER TABLE ingredient_templates DROP COLUMN use_count;
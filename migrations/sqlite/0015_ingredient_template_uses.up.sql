@@ -0,0 +1,18 @@
+-- How often and how recently meals used each ingredient template, kept up to date as meals are
+-- written so that search can rank templates without aggregating every logged ingredient (SQLite)
+
+ALTER TABLE ingredient_templates ADD COLUMN use_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE ingredient_templates ADD COLUMN last_used_at TIMESTAMP;
+
+UPDATE ingredient_templates SET
+    use_count = (
+        SELECT COUNT(*) FROM ingredients i
+        JOIN meal_ingredients mi ON mi.ingredient_id = i.id
+        WHERE i.ingredient_template_id = ingredient_templates.id
+    ),
+    last_used_at = (
+        SELECT MAX(m.datetime) FROM ingredients i
+        JOIN meal_ingredients mi ON mi.ingredient_id = i.id
+        JOIN meals m ON m.id = mi.meal_id
+        WHERE i.ingredient_template_id = ingredient_templates.id
+    );
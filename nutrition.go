@@ -0,0 +1,177 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Nutrients are the label values tracked besides the four macros, given per the same macro unit
+// (or, in totals, eaten). Sodium is in mg, the rest in g.
+type Nutrients struct {
+	Fibre        float64 `json:"fibre"`
+	Sugar        float64 `json:"sugar"`
+	SaturatedFat float64 `json:"saturatedFat"`
+	Sodium       float64 `json:"sodium"`
+	Alcohol      float64 `json:"alcohol"`
+}
+
+// NutrientTargets are the optional daily targets for Nutrients
+type NutrientTargets struct {
+	Fibre        *MacroTarget `json:"fibre,omitempty"`
+	Sugar        *MacroTarget `json:"sugar,omitempty"`
+	SaturatedFat *MacroTarget `json:"saturatedFat,omitempty"`
+	Sodium       *MacroTarget `json:"sodium,omitempty"`
+	Alcohol      *MacroTarget `json:"alcohol,omitempty"`
+}
+
+// Helper function to build Nutrients from columns that may be NULL in an outer join
+func nullNutrients(fibre, sugar, saturatedFat, sodium, alcohol sql.NullFloat64) Nutrients {
+	return Nutrients{
+		Fibre:        fibre.Float64,
+		Sugar:        sugar.Float64,
+		SaturatedFat: saturatedFat.Float64,
+		Sodium:       sodium.Float64,
+		Alcohol:      alcohol.Float64,
+	}
+}
+
+// validate rejects negative nutrient values
+func (n Nutrients) validate() error {
+	if n.Fibre < 0 || n.Sugar < 0 || n.SaturatedFat < 0 || n.Sodium < 0 || n.Alcohol < 0 {
+		return errors.New("fibre, sugar, saturatedFat, sodium and alcohol cannot be negative")
+	}
+	return nil
+}
+
+// validate checks an ingredient's nutrients and units before it is stored
+func (i *Ingredient) validate() error {
+	if err := i.Nutrients.validate(); err != nil {
+		return err
+	}
+	return i.validateUnits()
+}
+
+// validate checks an ingredient template's nutrients and units before it is stored
+func (t *IngredientTemplate) validate() error {
+	if err := t.Nutrients.validate(); err != nil {
+		return err
+	}
+	return t.validateUnits()
+}
+
+// MacroTotals holds the macros actually eaten, after scaling by quantity
+type MacroTotals struct {
+	Carbs   float64 `json:"carbs"`
+	Fat     float64 `json:"fat"`
+	Protein float64 `json:"protein"`
+	Kcal    float64 `json:"kcal"`
+	Nutrients
+}
+
+func (t *MacroTotals) add(o MacroTotals) {
+	t.Carbs += o.Carbs
+	t.Fat += o.Fat
+	t.Protein += o.Protein
+	t.Kcal += o.Kcal
+	t.Fibre += o.Fibre
+	t.Sugar += o.Sugar
+	t.SaturatedFat += o.SaturatedFat
+	t.Sodium += o.Sodium
+	t.Alcohol += o.Alcohol
+}
+
+// rounded returns the totals rounded to two decimal places for API responses
+func (t MacroTotals) rounded() MacroTotals {
+	return MacroTotals{
+		Carbs:   round2(t.Carbs),
+		Fat:     round2(t.Fat),
+		Protein: round2(t.Protein),
+		Kcal:    round2(t.Kcal),
+		Nutrients: Nutrients{
+			Fibre:        round2(t.Fibre),
+			Sugar:        round2(t.Sugar),
+			SaturatedFat: round2(t.SaturatedFat),
+			Sodium:       round2(t.Sodium),
+			Alcohol:      round2(t.Alcohol),
+		},
+	}
+}
+
+// scaled returns the totals multiplied by multiplier
+func (t MacroTotals) scaled(multiplier float64) MacroTotals {
+	return MacroTotals{
+		Carbs:   t.Carbs * multiplier,
+		Fat:     t.Fat * multiplier,
+		Protein: t.Protein * multiplier,
+		Kcal:    t.Kcal * multiplier,
+		Nutrients: Nutrients{
+			Fibre:        t.Fibre * multiplier,
+			Sugar:        t.Sugar * multiplier,
+			SaturatedFat: t.SaturatedFat * multiplier,
+			Sodium:       t.Sodium * multiplier,
+			Alcohol:      t.Alcohol * multiplier,
+		},
+	}
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// scaleMacros is the canonical scaling rule for stored macros: they are multiplied by the
+// quantity eaten, converted to the macro unit's amount (see quantityMultiplier). For example
+// per_100g macros are scaled by grams/100, per_unit macros are multiplied by the quantity.
+func scaleMacros(macros MacroTotals, quantity float64, quantityUnit, macroUnit string, servingSize *float64) MacroTotals {
+	// Stored quantities are validated, so the conversion cannot fail
+	multiplier, _ := quantityMultiplier(quantity, quantityUnit, macroUnit, servingSizeOrZero(servingSize))
+	return macros.scaled(multiplier)
+}
+
+// scaledMacroSQL is scaleMacros as an SQL expression, for aggregating a macro or nutrient column
+// of the ingredients table (aliased i) in queries
+func scaledMacroSQL(column string) string {
+	return fmt.Sprintf("i.%s * %s", column, quantityMultiplierSQL)
+}
+
+func (i Ingredient) macros() MacroTotals {
+	macros := MacroTotals{Carbs: i.Carbs, Fat: i.Fat, Protein: i.Protein, Kcal: i.Kcal, Nutrients: i.Nutrients}
+	return scaleMacros(macros, i.Quantity, i.QuantityUnit, i.MacroUnit, i.ServingSize)
+}
+
+func (t IngredientTemplate) macros() MacroTotals {
+	macros := MacroTotals{Carbs: t.Carbs, Fat: t.Fat, Protein: t.Protein, Kcal: t.Kcal, Nutrients: t.Nutrients}
+	return scaleMacros(macros, t.Quantity, "", t.MacroUnit, t.ServingSize)
+}
+
+// computeTotals fills in Totals for the ingredient
+func (i *Ingredient) computeTotals() {
+	totals := i.macros().rounded()
+	i.Totals = &totals
+}
+
+// computeTotals fills in Totals for each ingredient and for the meal as a whole
+func (m *Meal) computeTotals() {
+	var totals MacroTotals
+	for idx := range m.Ingredients {
+		m.Ingredients[idx].computeTotals()
+		totals.add(m.Ingredients[idx].macros())
+	}
+	totals = totals.rounded()
+	m.Totals = &totals
+}
+
+// computeTotals fills in Totals for each ingredient (at its template quantity) and for the template as a whole
+func (t *MealTemplate) computeTotals() {
+	var totals MacroTotals
+	for idx := range t.Ingredients {
+		ingredient := &t.Ingredients[idx]
+		ingredientTotals := ingredient.macros()
+		totals.add(ingredientTotals)
+		ingredientTotals = ingredientTotals.rounded()
+		ingredient.Totals = &ingredientTotals
+	}
+	totals = totals.rounded()
+	t.Totals = &totals
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IngredientPortion is a named household measure of an ingredient template, e.g. 1 slice = 30g.
+// Names are unique per template.
+type IngredientPortion struct {
+	ID    int     `json:"id,omitempty"`
+	Name  string  `json:"name" binding:"required"`
+	Grams float64 `json:"grams" binding:"required"` // Weight of one portion
+}
+
+// validate checks a portion's name and weight
+func (p *IngredientPortion) validate() error {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		return errors.New("portion name is required")
+	}
+	if p.Grams <= 0 {
+		return errors.New("portion grams must be greater than 0")
+	}
+	return nil
+}
+
+// findPortion returns the template's portion with the name (case-insensitively), or nil
+func (t *IngredientTemplate) findPortion(name string) *IngredientPortion {
+	for i := range t.Portions {
+		if strings.EqualFold(t.Portions[i].Name, strings.TrimSpace(name)) {
+			return &t.Portions[i]
+		}
+	}
+	return nil
+}
+
+// resolvePortion turns an ingredient given as a number of portions of an ingredient template
+// ("2 × slice") into grams, taking its macros from the template
+func resolvePortion(userID int, ingredient *Ingredient) error {
+	if ingredient.IngredientTemplateID == nil {
+		return fmt.Errorf("%w: portion %q needs an ingredientTemplateId", errInvalidReference, ingredient.Portion)
+	}
+	templateID := *ingredient.IngredientTemplateID
+	template, err := store.GetIngredientTemplate(userID, templateID)
+	if errors.Is(err, errNotFound) {
+		return fmt.Errorf("%w: ingredient template %d not found", errInvalidReference, templateID)
+	}
+	if err != nil {
+		return err
+	}
+	portion := template.findPortion(ingredient.Portion)
+	if portion == nil {
+		return fmt.Errorf("%w: ingredient template %q has no portion %q", errInvalidReference, template.Name, ingredient.Portion)
+	}
+
+	count := ingredient.Quantity
+	if count <= 0 {
+		return fmt.Errorf("%w: quantity must be a number of %q portions greater than 0", errInvalidReference, portion.Name)
+	}
+	name := ingredient.Name
+	if name == "" {
+		name = template.Name
+	}
+	*ingredient = Ingredient{
+		Name:                 name,
+		Quantity:             round2(count * portion.Grams),
+		QuantityUnit:         "g",
+		Carbs:                template.Carbs,
+		Fat:                  template.Fat,
+		Protein:              template.Protein,
+		Kcal:                 template.Kcal,
+		Nutrients:            template.Nutrients,
+		MacroUnit:            template.MacroUnit,
+		ServingSize:          template.ServingSize,
+		IngredientTemplateID: &templateID,
+	}
+	return nil
+}
+
+// Helper function to parse the ingredient template and portion IDs from the URL
+func parsePortionParams(c *gin.Context) (int, int, bool) {
+	templateID, ok := parseIDParam(c)
+	if !ok {
+		return 0, 0, false
+	}
+	id, err := strconv.Atoi(c.Param("portionId"))
+	if err != nil || id < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid portion ID %q", c.Param("portionId"))})
+		return 0, 0, false
+	}
+	return templateID, id, true
+}
+
+// Helper function to bind a portion from the request body and validate it
+func bindPortion(c *gin.Context) (IngredientPortion, bool) {
+	var portion IngredientPortion
+	if err := c.ShouldBindJSON(&portion); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return portion, false
+	}
+	if err := portion.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return portion, false
+	}
+	return portion, true
+}
+
+// Portion handlers
+
+func getIngredientPortions(c *gin.Context) {
+	templateID, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	portions, err := store.ListIngredientPortions(currentUserID(c), templateID)
+	if err != nil {
+		respondStoreError(c, err, "Ingredient template not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, portions)
+}
+
+func createIngredientPortion(c *gin.Context) {
+	templateID, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+	portion, ok := bindPortion(c)
+	if !ok {
+		return
+	}
+
+	if err := store.CreateIngredientPortion(currentUserID(c), templateID, &portion); err != nil {
+		respondStoreError(c, err, "Ingredient template not found")
+		return
+	}
+
+	c.JSON(http.StatusCreated, portion)
+}
+
+func updateIngredientPortion(c *gin.Context) {
+	templateID, id, ok := parsePortionParams(c)
+	if !ok {
+		return
+	}
+	portion, ok := bindPortion(c)
+	if !ok {
+		return
+	}
+
+	if err := store.UpdateIngredientPortion(currentUserID(c), templateID, id, &portion); err != nil {
+		respondStoreError(c, err, "Portion not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, portion)
+}
+
+func deleteIngredientPortion(c *gin.Context) {
+	templateID, id, ok := parsePortionParams(c)
+	if !ok {
+		return
+	}
+
+	if err := store.DeleteIngredientPortion(currentUserID(c), templateID, id); err != nil {
+		respondStoreError(c, err, "Portion not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Portion deleted successfully"})
+}
+
+// ListIngredientPortions returns an ingredient template's portions by weight, or errNotFound
+// if the user has no such template
+func (s *sqlStore) ListIngredientPortions(userID, templateID int) ([]IngredientPortion, error) {
+	if err := checkIngredientTemplateExists(s.db, userID, templateID); err != nil {
+		return nil, err
+	}
+
+	portions, err := s.listPortions("p.ingredient_template_id = $1", templateID)
+	if err != nil {
+		return nil, err
+	}
+	if portions[templateID] == nil {
+		return []IngredientPortion{}, nil
+	}
+	return portions[templateID], nil
+}
+
+// listPortions returns the portions matching the condition (on ingredient_portions aliased p)
+// grouped by ingredient template ID, each group ordered by weight
+func (s *sqlStore) listPortions(condition string, args ...interface{}) (map[int][]IngredientPortion, error) {
+	rows, err := s.db.Query(`
+		SELECT p.ingredient_template_id, p.id, p.name, p.grams
+		FROM ingredient_portions p
+		JOIN ingredient_templates t ON t.id = p.ingredient_template_id
+		WHERE `+condition+`
+		ORDER BY p.ingredient_template_id, p.grams, p.name
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	portions := make(map[int][]IngredientPortion)
+	for rows.Next() {
+		var templateID int
+		var portion IngredientPortion
+		if err := rows.Scan(&templateID, &portion.ID, &portion.Name, &portion.Grams); err != nil {
+			return nil, err
+		}
+		if portions[templateID] == nil {
+			portions[templateID] = []IngredientPortion{}
+		}
+		portions[templateID] = append(portions[templateID], portion)
+	}
+	return portions, rows.Err()
+}
+
+func (s *sqlStore) CreateIngredientPortion(userID, templateID int, portion *IngredientPortion) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = checkIngredientTemplateExists(tx, userID, templateID); err != nil {
+		return err
+	}
+	if err = checkPortionName(tx, templateID, 0, portion.Name); err != nil {
+		return err
+	}
+	err = tx.QueryRow("INSERT INTO ingredient_portions (ingredient_template_id, name, grams) VALUES ($1, $2, $3) RETURNING id",
+		templateID, portion.Name, portion.Grams).Scan(&portion.ID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) UpdateIngredientPortion(userID, templateID, id int, portion *IngredientPortion) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = checkIngredientTemplateExists(tx, userID, templateID); err != nil {
+		return err
+	}
+	if err = checkPortionName(tx, templateID, id, portion.Name); err != nil {
+		return err
+	}
+	result, err := tx.Exec(`
+		UPDATE ingredient_portions SET name = $1, grams = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $3 AND ingredient_template_id = $4
+	`, portion.Name, portion.Grams, id, templateID)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return errNotFound
+	}
+	portion.ID = id
+	return tx.Commit()
+}
+
+func (s *sqlStore) DeleteIngredientPortion(userID, templateID, id int) error {
+	result, err := s.db.Exec(`
+		DELETE FROM ingredient_portions
+		WHERE id = $1 AND ingredient_template_id IN (SELECT id FROM ingredient_templates WHERE id = $2 AND user_id = $3)
+	`, id, templateID, userID)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return errNotFound
+	}
+	return nil
+}
+
+// insertIngredientPortions adds portions to an ingredient template, keeping any it already has
+// with the same name
+func insertIngredientPortions(q queryer, templateID int, portions []IngredientPortion) error {
+	for _, portion := range portions {
+		_, err := q.Exec(`
+			INSERT INTO ingredient_portions (ingredient_template_id, name, grams) VALUES ($1, $2, $3)
+			ON CONFLICT (ingredient_template_id, name) DO NOTHING
+		`, templateID, portion.Name, portion.Grams)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkIngredientTemplateExists returns errNotFound unless the user has the ingredient template
+func checkIngredientTemplateExists(q queryer, userID, templateID int) error {
+	var exists bool
+	err := q.QueryRow("SELECT EXISTS (SELECT 1 FROM ingredient_templates WHERE id = $1 AND user_id = $2)", templateID, userID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errNotFound
+	}
+	return nil
+}
+
+// checkPortionName returns errDuplicate if another of the template's portions has the name
+func checkPortionName(tx *sql.Tx, templateID, id int, name string) error {
+	var exists bool
+	err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM ingredient_portions WHERE ingredient_template_id = $1 AND LOWER(name) = LOWER($2) AND id <> $3)",
+		templateID, name, id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("%w: portion %q", errDuplicate, name)
+	}
+	return nil
+}
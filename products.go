@@ -0,0 +1,426 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Product is a packaged food from the local Open Food Facts import, with macros per 100g
+type Product struct {
+	Code    string  `json:"code"` // EAN-13, or EAN-8
+	Name    string  `json:"name"`
+	Brands  string  `json:"brands,omitempty"`
+	Carbs   float64 `json:"carbs"`
+	Fat     float64 `json:"fat"`
+	Protein float64 `json:"protein"`
+	Kcal    float64 `json:"kcal"`
+	Nutrients
+	MacroUnit   string   `json:"macroUnit"`             // Always per_100g
+	ServingSize *float64 `json:"servingSize,omitempty"` // Grams per serving, if the label gives one
+}
+
+// productImportBatch is how many products the import writes per transaction
+const productImportBatch = 1000
+
+// normalizeBarcode returns a scanned EAN/UPC as products are stored: digits only, with UPC-A
+// codes (12 digits) and GTIN-14 codes with a leading zero as their EAN-13 equivalents
+func normalizeBarcode(code string) (string, error) {
+	digits := strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(code))
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return "", fmt.Errorf("invalid barcode %q, expected digits", code)
+		}
+	}
+	switch {
+	case len(digits) == 8 || len(digits) == 13:
+		return digits, nil
+	case len(digits) == 12:
+		return "0" + digits, nil
+	case len(digits) == 14 && digits[0] == '0':
+		return digits[1:], nil
+	}
+	return "", fmt.Errorf("invalid barcode %q, expected an EAN-8, UPC-A or EAN-13", code)
+}
+
+// ingredientTemplate converts the product into an ingredient template with per_100g macros,
+// named after the product and its first brand unless a name is given
+func (p Product) ingredientTemplate(name string) IngredientTemplate {
+	if name == "" {
+		name = p.Name
+		brand, _, _ := strings.Cut(p.Brands, ",")
+		if brand = strings.TrimSpace(brand); brand != "" && !strings.Contains(strings.ToLower(name), strings.ToLower(brand)) {
+			name = fmt.Sprintf("%s (%s)", name, brand)
+		}
+	}
+	return IngredientTemplate{
+		Name:            truncate(name, 255),
+		Carbs:           p.Carbs,
+		Fat:             p.Fat,
+		Protein:         p.Protein,
+		Kcal:            p.Kcal,
+		Nutrients:       p.Nutrients,
+		MacroUnit:       "per_100g",
+		ServingSize:     p.ServingSize,
+		DefaultQuantity: servingSizeOrZero(p.ServingSize),
+	}
+}
+
+// Helper function to bind the optional {"name": ...} body of requests that create an ingredient
+// template from a food, which overrides the template name
+func bindTemplateName(c *gin.Context) (string, bool) {
+	var options struct {
+		Name string `json:"name"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&options); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return "", false
+		}
+	}
+	return strings.TrimSpace(options.Name), true
+}
+
+// Product handlers
+
+func getProductByBarcode(c *gin.Context) {
+	code, err := normalizeBarcode(c.Param("ean"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	product, err := store.GetProduct(code)
+	if err != nil {
+		respondStoreError(c, err, "Product not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, product)
+}
+
+func createIngredientTemplateFromProduct(c *gin.Context) {
+	code, err := normalizeBarcode(c.Param("ean"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	name, ok := bindTemplateName(c)
+	if !ok {
+		return
+	}
+
+	product, err := store.GetProduct(code)
+	if err != nil {
+		respondStoreError(c, err, "Product not found")
+		return
+	}
+
+	template := product.ingredientTemplate(name)
+	if err := template.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := store.CreateIngredientTemplate(currentUserID(c), &template); err != nil {
+		respondStoreError(c, err, "Product not found")
+		return
+	}
+
+	c.JSON(http.StatusCreated, template)
+}
+
+func (s *sqlStore) GetProduct(code string) (*Product, error) {
+	product := Product{MacroUnit: "per_100g"}
+	var servingSize sql.NullFloat64
+	err := s.db.QueryRow(`
+		SELECT code, name, brands, carbs, fat, protein, kcal, fibre, sugar, saturated_fat, sodium, alcohol, serving_size
+		FROM products WHERE code = $1
+	`, code).Scan(&product.Code, &product.Name, &product.Brands, &product.Carbs, &product.Fat, &product.Protein, &product.Kcal,
+		&product.Fibre, &product.Sugar, &product.SaturatedFat, &product.Sodium, &product.Alcohol, &servingSize)
+	if err == sql.ErrNoRows {
+		return nil, errNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if servingSize.Valid {
+		product.ServingSize = &servingSize.Float64
+	}
+	return &product, nil
+}
+
+// Open Food Facts import
+
+// `macro-tracker import-products FILE` loads an Open Food Facts dump into the products table.
+// FILE is the CSV export (tab or comma separated) or the JSONL export (.jsonl), optionally
+// gzipped (.gz). Products already imported are updated.
+func runImportProductsCommand(s *sqlStore, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: import-products FILE.csv|FILE.jsonl[.gz]")
+	}
+	name := args[0]
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(name, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+		name = strings.TrimSuffix(name, ".gz")
+	}
+	format := "csv"
+	if strings.HasSuffix(name, ".jsonl") || strings.HasSuffix(name, ".ndjson") || strings.HasSuffix(name, ".json") {
+		format = "jsonl"
+	}
+
+	imported, skipped, err := s.importProducts(r, format)
+	if err != nil {
+		return err
+	}
+	log.Printf("Imported %d products, skipped %d without a valid barcode, name or nutrition facts", imported, skipped)
+	return nil
+}
+
+// offFields are the Open Food Facts fields the import reads: CSV columns, or in the JSONL export
+// top-level fields and (for the _100g ones) keys of "nutriments"
+var offFields = []string{
+	"code", "product_name", "generic_name", "brands", "serving_quantity", "serving_quantity_unit",
+	"energy-kcal_100g", "energy_100g", "carbohydrates_100g", "fat_100g", "proteins_100g",
+	"fiber_100g", "sugars_100g", "saturated-fat_100g", "sodium_100g", "salt_100g", "alcohol_100g",
+}
+
+// importProducts upserts the products read from an Open Food Facts dump in "csv" or "jsonl"
+// format, returning how many were imported and how many skipped
+func (s *sqlStore) importProducts(r io.Reader, format string) (imported, skipped int, err error) {
+	next, err := offRecordReader(r, format)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var tx *sql.Tx
+	defer func() {
+		if tx != nil {
+			tx.Rollback()
+		}
+	}()
+	for {
+		fields, err := next()
+		if err == io.EOF {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			skipped++
+			continue
+		}
+		if err != nil {
+			return imported, skipped, err
+		}
+		product, ok := parseOFFProduct(fields)
+		if !ok {
+			skipped++
+			continue
+		}
+
+		if tx == nil {
+			if tx, err = s.db.Begin(); err != nil {
+				return imported, skipped, err
+			}
+		}
+		if err = upsertProduct(tx, product); err != nil {
+			return imported, skipped, fmt.Errorf("product %s: %w", product.Code, err)
+		}
+		imported++
+		if imported%productImportBatch == 0 {
+			err = tx.Commit()
+			tx = nil
+			if err != nil {
+				return imported, skipped, err
+			}
+		}
+	}
+	if tx != nil {
+		err = tx.Commit()
+		tx = nil
+	}
+	return imported, skipped, err
+}
+
+func upsertProduct(tx *sql.Tx, p Product) error {
+	_, err := tx.Exec(`
+		INSERT INTO products (code, name, brands, carbs, fat, protein, kcal, fibre, sugar, saturated_fat, sodium, alcohol, serving_size)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		ON CONFLICT (code) DO UPDATE SET
+			name = excluded.name, brands = excluded.brands, carbs = excluded.carbs, fat = excluded.fat,
+			protein = excluded.protein, kcal = excluded.kcal, fibre = excluded.fibre, sugar = excluded.sugar,
+			saturated_fat = excluded.saturated_fat, sodium = excluded.sodium, alcohol = excluded.alcohol,
+			serving_size = excluded.serving_size, imported_at = CURRENT_TIMESTAMP
+	`, p.Code, p.Name, p.Brands, p.Carbs, p.Fat, p.Protein, p.Kcal,
+		p.Fibre, p.Sugar, p.SaturatedFat, p.Sodium, p.Alcohol, getFloatOrNil(p.ServingSize))
+	return err
+}
+
+// offRecordReader returns a function that reads the next product of a dump as its offFields
+func offRecordReader(r io.Reader, format string) (func() (map[string]string, error), error) {
+	switch format {
+	case "jsonl":
+		decoder := json.NewDecoder(r)
+		decoder.UseNumber()
+		return func() (map[string]string, error) {
+			var record map[string]interface{}
+			if err := decoder.Decode(&record); err != nil {
+				return nil, err
+			}
+			nutriments, _ := record["nutriments"].(map[string]interface{})
+			fields := make(map[string]string, len(offFields))
+			for _, name := range offFields {
+				value, ok := record[name]
+				if !ok {
+					value = nutriments[name]
+				}
+				if value != nil {
+					fields[name] = fmt.Sprint(value)
+				}
+			}
+			return fields, nil
+		}, nil
+
+	case "csv":
+		// The Open Food Facts "CSV" export is tab separated; plain CSV works too
+		br := bufio.NewReaderSize(r, 1<<16)
+		header, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		reader := csv.NewReader(io.MultiReader(strings.NewReader(header), br))
+		if strings.Contains(header, "\t") {
+			reader.Comma = '\t'
+		}
+		reader.FieldsPerRecord = -1
+		reader.LazyQuotes = true
+		reader.ReuseRecord = true
+
+		columns, err := reader.Read()
+		if err != nil {
+			return nil, fmt.Errorf("reading the header: %w", err)
+		}
+		index := make(map[string]int, len(offFields))
+		for i, column := range columns {
+			index[strings.TrimSpace(strings.TrimPrefix(column, "\ufeff"))] = i
+		}
+		if _, ok := index["code"]; !ok {
+			return nil, errors.New("the CSV file has no code column")
+		}
+		return func() (map[string]string, error) {
+			record, err := reader.Read()
+			if err != nil {
+				return nil, err
+			}
+			fields := make(map[string]string, len(offFields))
+			for _, name := range offFields {
+				if i, ok := index[name]; ok && i < len(record) {
+					fields[name] = record[i]
+				}
+			}
+			return fields, nil
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported format %q, expected csv or jsonl", format)
+}
+
+// parseOFFProduct builds a product from its Open Food Facts fields. Products without a valid
+// barcode, a name or any of kcal, carbs, fat and protein are skipped, as are implausible values.
+func parseOFFProduct(fields map[string]string) (Product, bool) {
+	var p Product
+	var err error
+	if p.Code, err = normalizeBarcode(fields["code"]); err != nil {
+		return p, false
+	}
+	p.Name = strings.TrimSpace(fields["product_name"])
+	if p.Name == "" {
+		p.Name = strings.TrimSpace(fields["generic_name"])
+	}
+	if p.Name == "" {
+		return p, false
+	}
+	p.Name = truncate(p.Name, 255)
+	p.Brands = truncate(strings.TrimSpace(fields["brands"]), 255)
+
+	valid := true
+	// value returns a field in the unit the products table uses, noting implausible ones
+	value := func(name string, scale, max float64) (float64, bool) {
+		raw := strings.TrimSpace(fields[name])
+		if raw == "" {
+			return 0, false
+		}
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return 0, false
+		}
+		v = round2(v * scale)
+		if v < 0 || v > max {
+			valid = false
+		}
+		return v, true
+	}
+
+	var hasKcal, hasCarbs, hasFat, hasProtein bool
+	if p.Kcal, hasKcal = value("energy-kcal_100g", 1, 900); !hasKcal {
+		p.Kcal, hasKcal = value("energy_100g", 1/4.184, 900) // kJ
+	}
+	p.Carbs, hasCarbs = value("carbohydrates_100g", 1, 100)
+	p.Fat, hasFat = value("fat_100g", 1, 100)
+	p.Protein, hasProtein = value("proteins_100g", 1, 100)
+	p.Fibre, _ = value("fiber_100g", 1, 100)
+	p.Sugar, _ = value("sugars_100g", 1, 100)
+	p.SaturatedFat, _ = value("saturated-fat_100g", 1, 100)
+	var hasSodium bool
+	if p.Sodium, hasSodium = value("sodium_100g", 1000, 100000); !hasSodium {
+		p.Sodium, _ = value("salt_100g", 1000/2.5, 100000)
+	}
+	// Open Food Facts gives alcohol in % vol; ethanol weighs 0.789 g/ml
+	p.Alcohol, _ = value("alcohol_100g", 0.789, 100)
+	if !valid || !(hasKcal || hasCarbs || hasFat || hasProtein) {
+		return p, false
+	}
+
+	if unit := strings.TrimSpace(fields["serving_quantity_unit"]); unit == "" || unit == "g" {
+		if serving, ok := value("serving_quantity", 1, 100000); ok && serving > 0 && serving < 100000 {
+			p.ServingSize = &serving
+		}
+	}
+	return p, true
+}
+
+// Helper function to truncate a string to at most n characters
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
@@ -0,0 +1,487 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Recipe is a batch-cooked dish: its raw ingredients, the weight of the cooked dish and the
+// servings it makes. Macros per 100g of the cooked dish and per serving follow from these, so a
+// weighed helping of the dish can be logged with the right numbers.
+type Recipe struct {
+	ID            int          `json:"id,omitempty"`
+	Name          string       `json:"name" binding:"required"`
+	Description   string       `json:"description,omitempty"`
+	Ingredients   []Ingredient `json:"ingredients"`                     // Raw quantities, as weighed before cooking
+	CookedWeight  float64      `json:"cookedWeight" binding:"required"` // Grams of the whole dish once cooked
+	Servings      float64      `json:"servings,omitempty"`              // Defaults to 1
+	ServingWeight float64      `json:"servingWeight,omitempty"`         // Computed grams of cooked dish per serving
+	Totals        *MacroTotals `json:"totals,omitempty"`                // Computed macros of the whole dish
+	Per100g       *MacroTotals `json:"per100g,omitempty"`               // Computed macros per 100g cooked
+	PerServing    *MacroTotals `json:"perServing,omitempty"`            // Computed macros per serving
+	CreatedAt     string       `json:"createdAt,omitempty"`
+	UpdatedAt     string       `json:"updatedAt,omitempty"`
+	// exactPer100g is Per100g before rounding for display. Logged helpings start from it, though
+	// Postgres stores ingredient macros to two decimal places.
+	exactPer100g MacroTotals
+}
+
+// LogRecipeRequest is the body of POST /api/recipes/:id/log
+type LogRecipeRequest struct {
+	DateTime string `json:"datetime" binding:"required"`
+	Name     string `json:"name,omitempty"` // Defaults to the recipe's name
+	// Quantity of the cooked dish eaten, in QuantityUnit (serving unless given, e.g. 180 g).
+	// Defaults to 1 if left out.
+	Quantity     *float64 `json:"quantity,omitempty"`
+	QuantityUnit string   `json:"quantityUnit,omitempty"`
+}
+
+// validate checks a recipe's name, weight and servings before it is stored, defaulting servings to 1
+func (r *Recipe) validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	switch {
+	case r.Name == "":
+		return errors.New("recipe name is required")
+	case r.CookedWeight <= 0:
+		return errors.New("cookedWeight must be greater than 0")
+	case r.Servings < 0:
+		return errors.New("servings must be greater than 0")
+	case len(r.Ingredients) == 0:
+		return errors.New("a recipe needs at least one ingredient")
+	}
+	if r.Servings == 0 {
+		r.Servings = 1
+	}
+	return nil
+}
+
+// computeTotals fills in Totals for each ingredient, and the recipe's totals, per 100g cooked
+// and per serving
+func (r *Recipe) computeTotals() {
+	var totals MacroTotals
+	for idx := range r.Ingredients {
+		r.Ingredients[idx].computeTotals()
+		totals.add(r.Ingredients[idx].macros())
+	}
+	r.exactPer100g = totals.scaled(100 / r.CookedWeight)
+	per100g := r.exactPer100g.rounded()
+	perServing := totals.scaled(1 / r.Servings).rounded()
+	totals = totals.rounded()
+	r.Totals, r.Per100g, r.PerServing = &totals, &per100g, &perServing
+	r.ServingWeight = round2(r.CookedWeight / r.Servings)
+}
+
+// ingredient returns a quantity of the cooked dish as an ingredient with its per_100g macros
+// and the serving weight as its serving size, so it can be logged by weight or in servings.
+// The quantity unit defaults to servings.
+func (r *Recipe) ingredient(name string, quantity float64, quantityUnit string) (Ingredient, error) {
+	if quantity <= 0 {
+		return Ingredient{}, fmt.Errorf("the quantity of recipe %q must be greater than 0", r.Name)
+	}
+	if quantityUnit == "" {
+		quantityUnit = "serving"
+	}
+	if name == "" {
+		name = r.Name
+	}
+	servingWeight := r.ServingWeight
+	return Ingredient{
+		Name:         name,
+		Quantity:     quantity,
+		QuantityUnit: quantityUnit,
+		Carbs:        r.exactPer100g.Carbs,
+		Fat:          r.exactPer100g.Fat,
+		Protein:      r.exactPer100g.Protein,
+		Kcal:         r.exactPer100g.Kcal,
+		Nutrients:    r.exactPer100g.Nutrients,
+		MacroUnit:    "per_100g",
+		ServingSize:  &servingWeight,
+	}, nil
+}
+
+// resolveRecipe turns an ingredient given as a quantity of a recipe into one with the recipe's
+// per 100g macros
+func resolveRecipe(userID int, ingredient *Ingredient) error {
+	recipe, err := store.GetRecipe(userID, *ingredient.RecipeID)
+	if errors.Is(err, errNotFound) {
+		return fmt.Errorf("%w: recipe %d not found", errInvalidReference, *ingredient.RecipeID)
+	}
+	if err != nil {
+		return err
+	}
+	resolved, err := recipe.ingredient(ingredient.Name, ingredient.Quantity, ingredient.QuantityUnit)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errInvalidReference, err)
+	}
+	*ingredient = resolved
+	return nil
+}
+
+// Helper function to bind a recipe from the request body and validate it and its ingredients
+func bindRecipe(c *gin.Context) (Recipe, bool) {
+	var recipe Recipe
+	if err := c.ShouldBindJSON(&recipe); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return recipe, false
+	}
+	if err := recipe.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return recipe, false
+	}
+	return recipe, prepareIngredients(c, recipe.Ingredients)
+}
+
+// Recipe handlers
+
+func getRecipes(c *gin.Context) {
+	recipes, err := store.ListRecipes(currentUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, recipes)
+}
+
+func getRecipe(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	recipe, err := store.GetRecipe(currentUserID(c), id)
+	if err != nil {
+		respondStoreError(c, err, "Recipe not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, recipe)
+}
+
+func createRecipe(c *gin.Context) {
+	recipe, ok := bindRecipe(c)
+	if !ok {
+		return
+	}
+
+	if err := store.CreateRecipe(currentUserID(c), &recipe); err != nil {
+		respondStoreError(c, err, "Recipe not found")
+		return
+	}
+
+	recipe.computeTotals()
+	c.JSON(http.StatusCreated, recipe)
+}
+
+func updateRecipe(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+	recipe, ok := bindRecipe(c)
+	if !ok {
+		return
+	}
+
+	if err := store.UpdateRecipe(currentUserID(c), id, &recipe); err != nil {
+		respondStoreError(c, err, "Recipe not found")
+		return
+	}
+
+	recipe.computeTotals()
+	c.JSON(http.StatusOK, recipe)
+}
+
+func deleteRecipe(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := store.DeleteRecipe(currentUserID(c), id); err != nil {
+		respondStoreError(c, err, "Recipe not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Recipe deleted successfully"})
+}
+
+// logRecipe creates a meal of a helping of a recipe, copying its per 100g macros so later
+// recipe edits do not change the logged meal
+func logRecipe(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+	var req LogRecipeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	t, _, err := parseDateTimeParam(req.DateTime)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid datetime: %v", err)})
+		return
+	}
+
+	recipe, err := store.GetRecipe(currentUserID(c), id)
+	if err != nil {
+		respondStoreError(c, err, "Recipe not found")
+		return
+	}
+
+	quantity := 1.0
+	if req.Quantity != nil {
+		quantity = *req.Quantity
+	}
+	ingredient, err := recipe.ingredient("", quantity, req.QuantityUnit)
+	if err == nil {
+		err = ingredient.validate()
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	meal := Meal{Name: recipe.Name, DateTime: t.Format(timestampLayout), Ingredients: []Ingredient{ingredient}}
+	if req.Name != "" {
+		meal.Name = req.Name
+	}
+
+	if err := store.CreateMeal(currentUserID(c), &meal); err != nil {
+		respondStoreError(c, err, "Recipe not found")
+		return
+	}
+
+	meal.computeTotals()
+	c.JSON(http.StatusCreated, meal)
+}
+
+// Recipes
+
+func (s *sqlStore) ListRecipes(userID int) ([]Recipe, error) {
+	return s.queryRecipes("r.user_id = $1", userID)
+}
+
+func (s *sqlStore) GetRecipe(userID, id int) (*Recipe, error) {
+	recipes, err := s.queryRecipes("r.id = $1 AND r.user_id = $2", id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(recipes) == 0 {
+		return nil, errNotFound
+	}
+	return &recipes[0], nil
+}
+
+// queryRecipes returns the recipes matching the condition (on recipes aliased r) with their
+// ingredients and totals, ordered by name
+func (s *sqlStore) queryRecipes(condition string, args ...interface{}) ([]Recipe, error) {
+	rows, err := s.db.Query(`
+		SELECT r.id, r.name, r.description, r.cooked_weight, r.servings, r.created_at, r.updated_at,
+		       i.id, i.name, i.quantity, i.quantity_unit, i.carbs, i.fat, i.protein, i.kcal,
+		       i.fibre, i.sugar, i.saturated_fat, i.sodium, i.alcohol, i.macro_unit, i.serving_size, i.ingredient_template_id
+		FROM recipes r
+		LEFT JOIN recipe_ingredients ri ON r.id = ri.recipe_id
+		LEFT JOIN ingredients i ON ri.ingredient_id = i.id
+		WHERE `+condition+`
+		ORDER BY r.name, r.id, i.id
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanRecipes(rows)
+}
+
+func (s *sqlStore) CreateRecipe(userID int, recipe *Recipe) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = insertRecipe(tx, userID, recipe); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateRecipe replaces the recipe's details and ingredients
+func (s *sqlStore) UpdateRecipe(userID, id int, recipe *Recipe) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = checkRecipeName(tx, userID, id, recipe.Name); err != nil {
+		return err
+	}
+	result, err := tx.Exec(`
+		UPDATE recipes SET name = $1, description = $2, cooked_weight = $3, servings = $4, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $5 AND user_id = $6
+	`, recipe.Name, recipe.Description, recipe.CookedWeight, recipe.Servings, id, userID)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return errNotFound
+	}
+
+	// As with meals, the ingredients belong to the recipe alone
+	if err = deleteRecipeIngredients(tx, id); err != nil {
+		return err
+	}
+	if err = insertRecipeIngredients(tx, userID, id, recipe.Ingredients); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	recipe.ID = id
+	return nil
+}
+
+func (s *sqlStore) DeleteRecipe(userID, id int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = deleteRecipeIngredients(tx, id); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec("DELETE FROM recipes WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return errNotFound
+	}
+
+	return tx.Commit()
+}
+
+// insertRecipe inserts a recipe with its ingredients, returning errDuplicate if the user already
+// has a recipe with the name
+func insertRecipe(tx *sql.Tx, userID int, recipe *Recipe) error {
+	if err := checkRecipeName(tx, userID, 0, recipe.Name); err != nil {
+		return err
+	}
+	err := tx.QueryRow("INSERT INTO recipes (user_id, name, description, cooked_weight, servings) VALUES ($1, $2, $3, $4, $5) RETURNING id",
+		userID, recipe.Name, recipe.Description, recipe.CookedWeight, recipe.Servings).Scan(&recipe.ID)
+	if err != nil {
+		return err
+	}
+	return insertRecipeIngredients(tx, userID, recipe.ID, recipe.Ingredients)
+}
+
+// insertRecipeIngredients inserts ingredients and links them to a recipe
+func insertRecipeIngredients(tx *sql.Tx, userID, recipeID int, ingredients []Ingredient) error {
+	for i := range ingredients {
+		ingredient := &ingredients[i]
+		if err := insertIngredient(tx, userID, ingredient); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec("INSERT INTO recipe_ingredients (recipe_id, ingredient_id) VALUES ($1, $2)", recipeID, ingredient.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteRecipeIngredients deletes a recipe's ingredients (and so their recipe_ingredients links)
+func deleteRecipeIngredients(tx *sql.Tx, recipeID int) error {
+	_, err := tx.Exec("DELETE FROM ingredients WHERE id IN (SELECT ingredient_id FROM recipe_ingredients WHERE recipe_id = $1)", recipeID)
+	return err
+}
+
+// checkRecipeName returns errDuplicate if another of the user's recipes has the name
+func checkRecipeName(tx *sql.Tx, userID, id int, name string) error {
+	var exists bool
+	err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM recipes WHERE user_id = $1 AND name = $2 AND id <> $3)", userID, name, id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("%w: recipe %q", errDuplicate, name)
+	}
+	return nil
+}
+
+// scanRecipes groups recipe/ingredient join rows into recipes, preserving the row order
+func scanRecipes(rows *sql.Rows) ([]Recipe, error) {
+	recipes := []Recipe{}
+	index := make(map[int]int)
+	for rows.Next() {
+		var recipe Recipe
+		var description, createdAt, updatedAt sql.NullString
+		var ingredientID sql.NullInt64
+		var ingredientName sql.NullString
+		var quantity, carbs, fat, protein, kcal sql.NullFloat64
+		var fibre, sugar, saturatedFat, sodium, alcohol sql.NullFloat64
+		var quantityUnit, macroUnit sql.NullString
+		var servingSize sql.NullFloat64
+		var templateID sql.NullInt64
+
+		err := rows.Scan(&recipe.ID, &recipe.Name, &description, &recipe.CookedWeight, &recipe.Servings, &createdAt, &updatedAt,
+			&ingredientID, &ingredientName, &quantity, &quantityUnit, &carbs, &fat, &protein, &kcal,
+			&fibre, &sugar, &saturatedFat, &sodium, &alcohol, &macroUnit, &servingSize, &templateID)
+		if err != nil {
+			return nil, err
+		}
+
+		i, exists := index[recipe.ID]
+		if !exists {
+			recipe.Description = description.String
+			recipe.CreatedAt = createdAt.String
+			recipe.UpdatedAt = updatedAt.String
+			recipe.Ingredients = []Ingredient{}
+			recipes = append(recipes, recipe)
+			i = len(recipes) - 1
+			index[recipe.ID] = i
+		}
+
+		if ingredientID.Valid {
+			ingredient := Ingredient{
+				ID:           int(ingredientID.Int64),
+				Name:         ingredientName.String,
+				Quantity:     quantity.Float64,
+				QuantityUnit: quantityUnit.String,
+				Carbs:        carbs.Float64,
+				Fat:          fat.Float64,
+				Protein:      protein.Float64,
+				Kcal:         kcal.Float64,
+				Nutrients:    nullNutrients(fibre, sugar, saturatedFat, sodium, alcohol),
+				MacroUnit:    macroUnit.String,
+			}
+			if servingSize.Valid {
+				ingredient.ServingSize = &servingSize.Float64
+			}
+			if templateID.Valid {
+				id := int(templateID.Int64)
+				ingredient.IngredientTemplateID = &id
+			}
+			recipes[i].Ingredients = append(recipes[i].Ingredients, ingredient)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range recipes {
+		recipes[i].computeTotals()
+	}
+	return recipes, nil
+}
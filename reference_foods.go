@@ -0,0 +1,564 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReferenceFood is a food from an imported food composition database, with macros per 100g
+type ReferenceFood struct {
+	ID       int     `json:"id"`
+	Source   string  `json:"source"`   // usda or cofid
+	SourceID string  `json:"sourceId"` // FoodData Central ID or CoFID food code
+	Name     string  `json:"name"`
+	Category string  `json:"category,omitempty"`
+	Carbs    float64 `json:"carbs"`
+	Fat      float64 `json:"fat"`
+	Protein  float64 `json:"protein"`
+	Kcal     float64 `json:"kcal"`
+	Nutrients
+	MacroUnit   string `json:"macroUnit"`   // Always per_100g
+	Attribution string `json:"attribution"` // Citation for the source database
+}
+
+// referenceFoodSources are the food composition databases import-foods reads, with the
+// attribution their licences ask for
+var referenceFoodSources = map[string]string{
+	"usda":  "U.S. Department of Agriculture, Agricultural Research Service. FoodData Central. fdc.nal.usda.gov",
+	"cofid": "Public Health England (2021). McCance and Widdowson's The Composition of Foods Integrated Dataset. Contains public sector information licensed under the Open Government Licence v3.0",
+}
+
+const (
+	defaultReferenceFoodPageSize = 25
+	maxReferenceFoodPageSize     = 100
+)
+
+// referenceFoodQuery filters and pages the reference foods catalogue
+type referenceFoodQuery struct {
+	Terms    []string // Words that must all appear in the name
+	Source   string
+	Page     int
+	PageSize int
+}
+
+// ingredientTemplate converts the reference food into an ingredient template with per_100g
+// macros, named after the food unless a name is given
+func (f ReferenceFood) ingredientTemplate(name string) IngredientTemplate {
+	if name == "" {
+		name = f.Name
+	}
+	return IngredientTemplate{
+		Name:            truncate(name, 255),
+		Carbs:           f.Carbs,
+		Fat:             f.Fat,
+		Protein:         f.Protein,
+		Kcal:            f.Kcal,
+		Nutrients:       f.Nutrients,
+		MacroUnit:       "per_100g",
+		DefaultQuantity: 100,
+	}
+}
+
+// Helper function to parse page and pageSize from the query string, for listings that are always paged
+func parsePagination(c *gin.Context, defaultPageSize, maxPageSize int) (int, int, error) {
+	page, pageSize := 1, defaultPageSize
+	if pageParam := c.Query("page"); pageParam != "" {
+		var err error
+		if page, err = strconv.Atoi(pageParam); err != nil || page < 1 {
+			return 0, 0, fmt.Errorf("invalid page %q", pageParam)
+		}
+	}
+	if pageSizeParam := c.Query("pageSize"); pageSizeParam != "" {
+		var err error
+		if pageSize, err = strconv.Atoi(pageSizeParam); err != nil || pageSize < 1 || pageSize > maxPageSize {
+			return 0, 0, fmt.Errorf("invalid pageSize %q, expected 1-%d", pageSizeParam, maxPageSize)
+		}
+	}
+	return page, pageSize, nil
+}
+
+// Reference food handlers
+
+func getReferenceFoods(c *gin.Context) {
+	q := referenceFoodQuery{Terms: strings.Fields(c.Query("q")), Source: c.Query("source")}
+	if _, ok := referenceFoodSources[q.Source]; q.Source != "" && !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid source %q, expected usda or cofid", q.Source)})
+		return
+	}
+	var err error
+	if q.Page, q.PageSize, err = parsePagination(c, defaultReferenceFoodPageSize, maxReferenceFoodPageSize); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	foods, total, err := store.ListReferenceFoods(q)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("X-Total-Count", strconv.Itoa(total))
+	c.JSON(http.StatusOK, foods)
+}
+
+func getReferenceFood(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	food, err := store.GetReferenceFood(id)
+	if err != nil {
+		respondStoreError(c, err, "Reference food not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, food)
+}
+
+func createIngredientTemplateFromReferenceFood(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+	name, ok := bindTemplateName(c)
+	if !ok {
+		return
+	}
+
+	food, err := store.GetReferenceFood(id)
+	if err != nil {
+		respondStoreError(c, err, "Reference food not found")
+		return
+	}
+
+	template := food.ingredientTemplate(name)
+	if err := template.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := store.CreateIngredientTemplate(currentUserID(c), &template); err != nil {
+		respondStoreError(c, err, "Reference food not found")
+		return
+	}
+
+	c.JSON(http.StatusCreated, template)
+}
+
+const referenceFoodColumns = `id, source, source_id, name, category, carbs, fat, protein, kcal,
+	fibre, sugar, saturated_fat, sodium, alcohol`
+
+func scanReferenceFood(row rowScanner) (ReferenceFood, error) {
+	food := ReferenceFood{MacroUnit: "per_100g"}
+	err := row.Scan(&food.ID, &food.Source, &food.SourceID, &food.Name, &food.Category,
+		&food.Carbs, &food.Fat, &food.Protein, &food.Kcal,
+		&food.Fibre, &food.Sugar, &food.SaturatedFat, &food.Sodium, &food.Alcohol)
+	food.Attribution = referenceFoodSources[food.Source]
+	return food, err
+}
+
+// ListReferenceFoods returns a page of the reference foods matching the query, names starting
+// with the search first and then shorter (more generic) names first, and the number matching
+func (s *sqlStore) ListReferenceFoods(q referenceFoodQuery) ([]ReferenceFood, int, error) {
+	var conditions []string
+	var args []interface{}
+	for _, term := range q.Terms {
+		args = append(args, "%"+escapeLike(strings.ToLower(term))+"%")
+		conditions = append(conditions, fmt.Sprintf(`LOWER(name) LIKE $%d ESCAPE '\'`, len(args)))
+	}
+	if q.Source != "" {
+		args = append(args, q.Source)
+		conditions = append(conditions, fmt.Sprintf("source = $%d", len(args)))
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM reference_foods "+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	order := "name, id"
+	if len(q.Terms) > 0 {
+		args = append(args, escapeLike(strings.ToLower(strings.Join(q.Terms, " ")))+"%")
+		order = fmt.Sprintf(`CASE WHEN LOWER(name) LIKE $%d ESCAPE '\' THEN 0 ELSE 1 END, LENGTH(name), name, id`, len(args))
+	}
+	args = append(args, q.PageSize, (q.Page-1)*q.PageSize)
+	rows, err := s.db.Query(fmt.Sprintf("SELECT %s FROM reference_foods %s ORDER BY %s LIMIT $%d OFFSET $%d",
+		referenceFoodColumns, where, order, len(args)-1, len(args)), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	foods := []ReferenceFood{}
+	for rows.Next() {
+		food, err := scanReferenceFood(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		foods = append(foods, food)
+	}
+	return foods, total, rows.Err()
+}
+
+func (s *sqlStore) GetReferenceFood(id int) (*ReferenceFood, error) {
+	food, err := scanReferenceFood(s.db.QueryRow("SELECT "+referenceFoodColumns+" FROM reference_foods WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, errNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &food, nil
+}
+
+// Helper function to escape the LIKE wildcards in a search term, for LIKE ... ESCAPE '\'
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// Food composition database import
+
+// `macro-tracker import-foods usda DIR` loads a FoodData Central CSV download (the directory
+// holding food.csv, food_nutrient.csv and optionally food_category.csv), and
+// `macro-tracker import-foods cofid FILE.csv...` loads CoFID sheets saved as CSV (e.g.
+// "1.3 Proximates" and "1.4 Inorganics"), merged by food code. Foods already imported are updated.
+func runImportFoodsCommand(s *sqlStore, args []string) error {
+	const usage = "usage: import-foods usda DIR | import-foods cofid FILE.csv..."
+	if len(args) < 2 {
+		return errors.New(usage)
+	}
+
+	var foods []ReferenceFood
+	var skipped int
+	var err error
+	switch args[0] {
+	case "usda":
+		if len(args) != 2 {
+			return errors.New(usage)
+		}
+		foods, skipped, err = readUSDAFoods(args[1])
+	case "cofid":
+		foods, skipped, err = readCoFIDFoods(args[1:])
+	default:
+		return fmt.Errorf("unknown source %q, %s", args[0], usage)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = s.importReferenceFoods(foods); err != nil {
+		return err
+	}
+	log.Printf("Imported %d %s foods, skipped %d without a name or nutrition facts", len(foods), args[0], skipped)
+	return nil
+}
+
+// importReferenceFoods upserts the foods by source and source ID
+func (s *sqlStore) importReferenceFoods(foods []ReferenceFood) error {
+	for start := 0; start < len(foods); start += productImportBatch {
+		end := min(start+productImportBatch, len(foods))
+		if err := s.upsertReferenceFoods(foods[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqlStore) upsertReferenceFoods(foods []ReferenceFood) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, f := range foods {
+		_, err = tx.Exec(`
+			INSERT INTO reference_foods (source, source_id, name, category, carbs, fat, protein, kcal,
+				fibre, sugar, saturated_fat, sodium, alcohol)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+			ON CONFLICT (source, source_id) DO UPDATE SET
+				name = excluded.name, category = excluded.category, carbs = excluded.carbs, fat = excluded.fat,
+				protein = excluded.protein, kcal = excluded.kcal, fibre = excluded.fibre, sugar = excluded.sugar,
+				saturated_fat = excluded.saturated_fat, sodium = excluded.sodium, alcohol = excluded.alcohol,
+				imported_at = CURRENT_TIMESTAMP
+		`, f.Source, f.SourceID, f.Name, f.Category, f.Carbs, f.Fat, f.Protein, f.Kcal,
+			f.Fibre, f.Sugar, f.SaturatedFat, f.Sodium, f.Alcohol)
+		if err != nil {
+			return fmt.Errorf("%s food %s: %w", f.Source, f.SourceID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// foodComponents are the values of a food being imported, by reference_foods column, and
+// the most each can plausibly be per 100g
+var foodComponents = map[string]float64{
+	"carbs": 100, "fat": 100, "protein": 100, "kcal": 900,
+	"fibre": 100, "sugar": 100, "saturated_fat": 100, "sodium": 100000, "alcohol": 100,
+}
+
+// importedFood is a food being read from a composition database, with the components found so far
+type importedFood struct {
+	id, name, category string
+	values             map[string]float64
+}
+
+// referenceFood returns the food to store, or false if it has no name, none of kcal, carbs, fat
+// and protein, or an implausible value
+func (f *importedFood) referenceFood(source string) (ReferenceFood, bool) {
+	food := ReferenceFood{Source: source, SourceID: f.id, Name: truncate(f.name, 255), Category: truncate(f.category, 255)}
+	if food.Name == "" {
+		return food, false
+	}
+	for component, value := range f.values {
+		if value < 0 || value > foodComponents[component] {
+			return food, false
+		}
+	}
+	hasMacros := false
+	for _, component := range []string{"kcal", "carbs", "fat", "protein"} {
+		_, ok := f.values[component]
+		hasMacros = hasMacros || ok
+	}
+	v := func(component string) float64 { return round2(f.values[component]) }
+	food.Carbs, food.Fat, food.Protein, food.Kcal = v("carbs"), v("fat"), v("protein"), v("kcal")
+	food.Nutrients = Nutrients{Fibre: v("fibre"), Sugar: v("sugar"), SaturatedFat: v("saturated_fat"), Sodium: v("sodium"), Alcohol: v("alcohol")}
+	return food, hasMacros
+}
+
+// usdaNutrient maps a FoodData Central nutrient ID to a component; where several IDs give the
+// same component the lowest rank wins
+type usdaNutrient struct {
+	component string
+	rank      int
+}
+
+var usdaNutrients = map[string]usdaNutrient{
+	"1008": {"kcal", 0},  // Energy (kcal)
+	"2048": {"kcal", 1},  // Energy, Atwater specific factors
+	"2047": {"kcal", 2},  // Energy, Atwater general factors
+	"1005": {"carbs", 0}, // Carbohydrate, by difference
+	"1050": {"carbs", 1}, // Carbohydrate, by summation
+	"1004": {"fat", 0},   // Total lipid (fat)
+	"1085": {"fat", 1},   // Total fat (NLEA)
+	"1003": {"protein", 0},
+	"1079": {"fibre", 0}, // Fiber, total dietary
+	"2000": {"sugar", 0}, // Sugars, total including NLEA
+	"1063": {"sugar", 1}, // Sugars, Total
+	"1258": {"saturated_fat", 0},
+	"1093": {"sodium", 0}, // mg
+	"1018": {"alcohol", 0},
+}
+
+// readUSDAFoods reads a FoodData Central CSV download, whose nutrient amounts are per 100g
+func readUSDAFoods(dir string) ([]ReferenceFood, int, error) {
+	categories := make(map[string]string)
+	err := readCSVFile(filepath.Join(dir, "food_category.csv"), []string{"id", "description"}, func(row csvRow) error {
+		categories[row.text("id")] = row.text("description")
+		return nil
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, 0, err
+	}
+
+	var order []string
+	foods := make(map[string]*importedFood)
+	err = readCSVFile(filepath.Join(dir, "food.csv"), []string{"fdc_id", "description"}, func(row csvRow) error {
+		id := row.text("fdc_id")
+		if _, seen := foods[id]; id == "" || seen {
+			return nil
+		}
+		order = append(order, id)
+		foods[id] = &importedFood{id: id, name: row.text("description"), category: categories[row.text("food_category_id")], values: map[string]float64{}}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ranks := make(map[string]int)
+	err = readCSVFile(filepath.Join(dir, "food_nutrient.csv"), []string{"fdc_id", "nutrient_id", "amount"}, func(row csvRow) error {
+		nutrient, ok := usdaNutrients[row.text("nutrient_id")]
+		food := foods[row.text("fdc_id")]
+		if !ok || food == nil {
+			return nil
+		}
+		amount, ok := row.value("amount")
+		if !ok {
+			return nil
+		}
+		key := food.id + "/" + nutrient.component
+		if rank, seen := ranks[key]; seen && rank <= nutrient.rank {
+			return nil
+		}
+		ranks[key] = nutrient.rank
+		food.values[nutrient.component] = amount
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	result, skipped := collectReferenceFoods("usda", order, foods)
+	return result, skipped, nil
+}
+
+// cofidColumns are the CoFID column headers of each component, in order of preference
+var cofidColumns = map[string][]string{
+	"kcal":          {"energy (kcal) (kcal)", "energy (kcal)"},
+	"carbs":         {"carbohydrate (g)"},
+	"fat":           {"fat (g)"},
+	"protein":       {"protein (g)"},
+	"fibre":         {"aoac fibre (g)", "nsp (g)"},
+	"sugar":         {"total sugars (g)"},
+	"saturated_fat": {"satd fa /100g fd (g)", "saturated fatty acids (g)"},
+	"sodium":        {"sodium (mg)"},
+	"alcohol":       {"alcohol (g)"},
+}
+
+// readCoFIDFoods reads CoFID sheets saved as CSV, whose values are per 100g of edible portion.
+// "N" (not determined) values are missing and "Tr" (trace) ones are 0.
+func readCoFIDFoods(files []string) ([]ReferenceFood, int, error) {
+	var order []string
+	foods := make(map[string]*importedFood)
+	for _, file := range files {
+		err := readCSVFile(file, []string{"food code", "food name"}, func(row csvRow) error {
+			code := row.text("food code")
+			if code == "" {
+				return nil
+			}
+			food := foods[code]
+			if food == nil {
+				food = &importedFood{id: code, values: map[string]float64{}}
+				foods[code] = food
+				order = append(order, code)
+			}
+			if food.name == "" {
+				food.name = row.text("food name")
+			}
+			if food.category == "" {
+				food.category = row.text("group")
+			}
+			for component, columns := range cofidColumns {
+				if _, ok := food.values[component]; ok {
+					continue
+				}
+				if value, ok := row.value(columns...); ok {
+					food.values[component] = value
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	result, skipped := collectReferenceFoods("cofid", order, foods)
+	return result, skipped, nil
+}
+
+// Helper function to turn the foods read from a source into reference foods, counting the skipped ones
+func collectReferenceFoods(source string, order []string, foods map[string]*importedFood) ([]ReferenceFood, int) {
+	result := make([]ReferenceFood, 0, len(order))
+	skipped := 0
+	for _, id := range order {
+		food, ok := foods[id].referenceFood(source)
+		if !ok {
+			skipped++
+			continue
+		}
+		result = append(result, food)
+	}
+	return result, skipped
+}
+
+// csvRow is a CSV record with its columns looked up by lower-case header
+type csvRow struct {
+	index  map[string]int
+	record []string
+}
+
+// text returns the trimmed value of the first of the columns that is present and not empty
+func (r csvRow) text(columns ...string) string {
+	for _, column := range columns {
+		if i, ok := r.index[column]; ok && i < len(r.record) {
+			if value := strings.TrimSpace(r.record[i]); value != "" {
+				return value
+			}
+		}
+	}
+	return ""
+}
+
+// value returns the first of the columns that holds a number, reading trace amounts as 0
+func (r csvRow) value(columns ...string) (float64, bool) {
+	for _, column := range columns {
+		raw := strings.Trim(r.text(column), `"`)
+		if strings.EqualFold(raw, "tr") || strings.EqualFold(raw, "trace") {
+			return 0, true
+		}
+		if v, err := strconv.ParseFloat(raw, 64); err == nil {
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+// readCSVFile calls fn with each record of a CSV file, after checking its header has the
+// required columns
+func readCSVFile(path string, required []string, fn func(row csvRow) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("%s: reading the header: %w", path, err)
+	}
+	row := csvRow{index: make(map[string]int, len(header))}
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))
+		if _, ok := row.index[column]; !ok {
+			row.index[column] = i
+		}
+	}
+	for _, column := range required {
+		if _, ok := row.index[column]; !ok {
+			return fmt.Errorf("%s: missing the %q column", path, column)
+		}
+	}
+
+	for {
+		row.record, err = reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if err = fn(row); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxReportDays limits the range of a report
+const maxReportDays = 366
+
+// Report is the response of GET /api/reports
+type Report struct {
+	Period  string         `json:"period"`
+	From    string         `json:"from"`
+	To      string         `json:"to"`
+	Periods []ReportPeriod `json:"periods"`
+}
+
+// ReportPeriod aggregates the days with logged meals in one week (starting on Monday) or month.
+// Start and End are clamped to the report's range. Averages are per logged day.
+type ReportPeriod struct {
+	Start      string          `json:"start"`
+	End        string          `json:"end"`
+	LoggedDays int             `json:"loggedDays"`
+	MealCount  int             `json:"mealCount"`
+	Totals     MacroTotals     `json:"totals"`
+	Averages   MacroTotals     `json:"averages"`
+	Adherence  ReportAdherence `json:"adherence"`
+	BestDay    *ReportDay      `json:"bestDay,omitempty"`
+	WorstDay   *ReportDay      `json:"worstDay,omitempty"`
+}
+
+// ReportAdherence counts, per macro, the logged days below, within and above that day's targets.
+// Days without a target for the macro are not counted.
+type ReportAdherence struct {
+	Carbs   MacroAdherence `json:"carbs"`
+	Fat     MacroAdherence `json:"fat"`
+	Protein MacroAdherence `json:"protein"`
+	Kcal    MacroAdherence `json:"kcal"`
+}
+
+type MacroAdherence struct {
+	BelowMin    int `json:"belowMin"`
+	WithinRange int `json:"withinRange"`
+	AboveMax    int `json:"aboveMax"`
+}
+
+// ReportDay is a day's totals with the number of its targeted macros that were within range
+type ReportDay struct {
+	Date           string      `json:"date"`
+	Totals         MacroTotals `json:"totals"`
+	WithinRange    int         `json:"withinRange"`
+	TargetedMacros int         `json:"targetedMacros"`
+	// deviation is how far the day's macros were outside their ranges, relative to the bounds
+	deviation float64
+}
+
+// PeriodIntake is the macro totals and daily averages of the days logged in one period
+type PeriodIntake struct {
+	Start      string
+	LoggedDays int
+	MealCount  int
+	Totals     MacroTotals
+	Averages   MacroTotals
+}
+
+// getReport aggregates logged meals per ?period=week|month (default week) between ?from and ?to.
+// to defaults to today and from to the start of the fourth period before to's.
+func getReport(c *gin.Context) {
+	period := c.DefaultQuery("period", "week")
+	if period != "week" && period != "month" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid period, expected week or month"})
+		return
+	}
+	from, to, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+	if to == "" {
+		to = time.Now().Format(dateLayout)
+	}
+	end, _ := time.Parse(dateLayout, to)
+	if from == "" {
+		first := periodStart(period, end).AddDate(0, 0, -21)
+		if period == "month" {
+			first = periodStart(period, end).AddDate(0, -3, 0)
+		}
+		from = first.Format(dateLayout)
+	}
+	start, _ := time.Parse(dateLayout, from)
+	if start.After(end) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must not be after to"})
+		return
+	}
+	if end.Sub(start).Hours()/24 >= maxReportDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Reports cover at most %d days", maxReportDays)})
+		return
+	}
+
+	report, err := buildReport(currentUserID(c), period, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// Helper function to find the first day of the week (Monday) or month containing t
+func periodStart(period string, t time.Time) time.Time {
+	if period == "month" {
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	offset := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, time.UTC)
+}
+
+// buildReport combines the per-period totals with adherence to each logged day's targets
+func buildReport(userID int, period, from, to string) (Report, error) {
+	report := Report{Period: period, From: from, To: to, Periods: []ReportPeriod{}}
+
+	periods, err := store.ListPeriodIntake(userID, period, from, to)
+	if err != nil {
+		return report, err
+	}
+	days, err := store.ListDailyIntake(userID, from, to)
+	if err != nil {
+		return report, err
+	}
+	timeline, err := store.LoadTargetTimeline(userID, from, to)
+	if err != nil {
+		return report, err
+	}
+
+	index := make(map[string]int, len(periods))
+	for _, p := range periods {
+		start, err := time.Parse(dateLayout, p.Start)
+		if err != nil {
+			return report, err
+		}
+		end := start.AddDate(0, 0, 6)
+		if period == "month" {
+			end = start.AddDate(0, 1, -1)
+		}
+		index[p.Start] = len(report.Periods)
+		// Dates are YYYY-MM-DD, so they compare as strings
+		report.Periods = append(report.Periods, ReportPeriod{
+			Start:      max(p.Start, from),
+			End:        min(end.Format(dateLayout), to),
+			LoggedDays: p.LoggedDays,
+			MealCount:  p.MealCount,
+			Totals:     p.Totals,
+			Averages:   p.Averages,
+		})
+	}
+
+	for _, day := range days {
+		date, err := time.Parse(dateLayout, day.Date)
+		if err != nil {
+			return report, err
+		}
+		i, ok := index[periodStart(period, date).Format(dateLayout)]
+		if !ok {
+			continue
+		}
+		report.Periods[i].addDay(day, timeline.On(day.Date))
+	}
+	return report, nil
+}
+
+// addDay counts a logged day towards the period's adherence and best and worst days
+func (p *ReportPeriod) addDay(day DailyIntake, targets *DailyTargets) {
+	if targets == nil {
+		return
+	}
+	reportDay := &ReportDay{Date: day.Date, Totals: day.Totals}
+	reportDay.count(&p.Adherence.Carbs, day.Totals.Carbs, targets.Carbs)
+	reportDay.count(&p.Adherence.Fat, day.Totals.Fat, targets.Fat)
+	reportDay.count(&p.Adherence.Protein, day.Totals.Protein, targets.Protein)
+	reportDay.count(&p.Adherence.Kcal, day.Totals.Kcal, targets.Kcal)
+	if reportDay.TargetedMacros == 0 {
+		return
+	}
+
+	if p.BestDay == nil || reportDay.betterThan(p.BestDay) {
+		p.BestDay = reportDay
+	}
+	if p.WorstDay == nil || p.WorstDay.betterThan(reportDay) {
+		p.WorstDay = reportDay
+	}
+}
+
+// count evaluates one macro of the day against its target
+func (d *ReportDay) count(adherence *MacroAdherence, current float64, target *MacroTarget) {
+	progress := calculateMacroProgress(current, target)
+	switch progress.Status {
+	case statusBelowMin:
+		adherence.BelowMin++
+		d.deviation += (*target.Min - current) / math.Max(*target.Min, 1)
+	case statusWithinRange:
+		adherence.WithinRange++
+		d.WithinRange++
+	case statusAboveMax:
+		adherence.AboveMax++
+		d.deviation += (current - *target.Max) / math.Max(*target.Max, 1)
+	default:
+		return
+	}
+	d.TargetedMacros++
+}
+
+// betterThan ranks days by the share of targeted macros within range, then by how close the rest came
+func (d *ReportDay) betterThan(other *ReportDay) bool {
+	share, otherShare := float64(d.WithinRange)/float64(d.TargetedMacros), float64(other.WithinRange)/float64(other.TargetedMacros)
+	if share != otherShare {
+		return share > otherShare
+	}
+	return d.deviation < other.deviation
+}
+
+// ListPeriodIntake returns the macro totals and per-day averages of the meals logged between from
+// and to (YYYY-MM-DD, inclusive) per "week" or "month". Periods without meals are left out.
+func (s *sqlStore) ListPeriodIntake(userID int, period, from, to string) ([]PeriodIntake, error) {
+	periodExpr, ok := s.dialect.periodExpr[period]
+	if !ok {
+		return nil, fmt.Errorf("unsupported period %q", period)
+	}
+	args, err := dailyIntakeArgs(userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	start := fmt.Sprintf(periodExpr, "d.day")
+
+	rows, err := s.db.Query(fmt.Sprintf(`
+		SELECT %[1]s, COUNT(*), SUM(d.meal_count),
+		       SUM(d.carbs), SUM(d.fat), SUM(d.protein), SUM(d.kcal),
+		       SUM(d.fibre), SUM(d.sugar), SUM(d.saturated_fat), SUM(d.sodium), SUM(d.alcohol),
+		       AVG(d.carbs), AVG(d.fat), AVG(d.protein), AVG(d.kcal),
+		       AVG(d.fibre), AVG(d.sugar), AVG(d.saturated_fat), AVG(d.sodium), AVG(d.alcohol)
+		FROM (%[2]s) d
+		GROUP BY %[1]s
+		ORDER BY %[1]s
+	`, start, s.dailyIntakeSQL()), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	periods := []PeriodIntake{}
+	for rows.Next() {
+		var p PeriodIntake
+		t, a := &p.Totals, &p.Averages
+		err := rows.Scan(&p.Start, &p.LoggedDays, &p.MealCount,
+			&t.Carbs, &t.Fat, &t.Protein, &t.Kcal, &t.Fibre, &t.Sugar, &t.SaturatedFat, &t.Sodium, &t.Alcohol,
+			&a.Carbs, &a.Fat, &a.Protein, &a.Kcal, &a.Fibre, &a.Sugar, &a.SaturatedFat, &a.Sodium, &a.Alcohol)
+		if err != nil {
+			return nil, err
+		}
+		p.Start = dateString(p.Start)
+		p.Totals = p.Totals.rounded()
+		p.Averages = p.Averages.rounded()
+		periods = append(periods, p)
+	}
+	return periods, rows.Err()
+}
@@ -245,6 +245,14 @@ BEGIN
     END LOOP;
 END $$;
 
+-- Assign the sample data to the first registered account (unowned rows are otherwise
+-- claimed by whoever registers first)
+UPDATE ingredient_templates SET user_id = (SELECT MIN(id) FROM users) WHERE user_id IS NULL;
+UPDATE meal_templates SET user_id = (SELECT MIN(id) FROM users) WHERE user_id IS NULL;
+UPDATE meals SET user_id = (SELECT MIN(id) FROM users) WHERE user_id IS NULL;
+UPDATE ingredients SET user_id = (SELECT MIN(id) FROM users) WHERE user_id IS NULL;
+UPDATE daily_targets SET user_id = (SELECT MIN(id) FROM users) WHERE user_id IS NULL;
+
 -- Display summary of created data
 SELECT 'Sample Data Created Successfully!' as status;
 
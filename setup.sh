@@ -42,7 +42,7 @@ echo ""
 echo "🎉 Setup complete!"
 echo ""
 echo "Next steps:"
-echo "1. Start the backend: go run main.go"
+echo "1. Start the backend: go run ."
 echo "2. Start the frontend: npm run dev"
 echo "3. Open http://localhost:5173 in your browser"
 echo ""
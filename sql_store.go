@@ -0,0 +1,1052 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Users and sessions
+
+// CreateUser inserts a user, claims data logged before accounts existed if this is the
+// first user, and seeds a starter set of ingredient templates.
+func (s *sqlStore) CreateUser(email, passwordHash string) (User, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return User{}, err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err = tx.QueryRow("SELECT EXISTS (SELECT 1 FROM users WHERE email = $1)", email).Scan(&exists); err != nil {
+		return User{}, err
+	}
+	if exists {
+		return User{}, errEmailTaken
+	}
+
+	var userCount int
+	if err = tx.QueryRow("SELECT COUNT(*) FROM users").Scan(&userCount); err != nil {
+		return User{}, err
+	}
+
+	user := User{Email: email}
+	err = tx.QueryRow("INSERT INTO users (email, password_hash) VALUES ($1, $2) RETURNING id", email, passwordHash).Scan(&user.ID)
+	if err != nil {
+		return User{}, err
+	}
+
+	// The first account takes ownership of any data logged before accounts existed
+	if userCount == 0 {
+		for _, table := range ownedTables {
+			if _, err = tx.Exec("UPDATE "+table+" SET user_id = $1 WHERE user_id IS NULL", user.ID); err != nil {
+				return User{}, err
+			}
+		}
+	}
+
+	if err = seedIngredientTemplates(tx, user.ID); err != nil {
+		return User{}, err
+	}
+
+	return user, tx.Commit()
+}
+
+func (s *sqlStore) GetUser(id int) (User, error) {
+	var user User
+	var createdAt sql.NullString
+	err := s.db.QueryRow("SELECT id, email, created_at FROM users WHERE id = $1", id).Scan(&user.ID, &user.Email, &createdAt)
+	if err == sql.ErrNoRows {
+		return User{}, errNotFound
+	}
+	user.CreatedAt = createdAt.String
+	return user, err
+}
+
+// GetUserByEmail returns the user and their password hash
+func (s *sqlStore) GetUserByEmail(email string) (User, string, error) {
+	var user User
+	var passwordHash string
+	err := s.db.QueryRow("SELECT id, email, password_hash FROM users WHERE email = $1", email).Scan(&user.ID, &user.Email, &passwordHash)
+	if err == sql.ErrNoRows {
+		return User{}, "", errNotFound
+	}
+	return user, passwordHash, err
+}
+
+func (s *sqlStore) CreateSession(userID int, tokenHash string, expiresAt time.Time) error {
+	_, err := s.db.Exec("INSERT INTO sessions (token_hash, user_id, expires_at) VALUES ($1, $2, $3)",
+		tokenHash, userID, expiresAt.UTC().Format(timestampLayout))
+	return err
+}
+
+// GetSessionUserID returns the owner of an unexpired session
+func (s *sqlStore) GetSessionUserID(tokenHash string, now time.Time) (int, error) {
+	var userID int
+	err := s.db.QueryRow("SELECT user_id FROM sessions WHERE token_hash = $1 AND expires_at > $2",
+		tokenHash, now.UTC().Format(timestampLayout)).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, errNotFound
+	}
+	return userID, err
+}
+
+func (s *sqlStore) DeleteSession(tokenHash string) error {
+	_, err := s.db.Exec("DELETE FROM sessions WHERE token_hash = $1", tokenHash)
+	return err
+}
+
+// seedIngredientTemplates gives a user without ingredient templates a starter set
+func seedIngredientTemplates(tx *sql.Tx, userID int) error {
+	var count int
+	err := tx.QueryRow("SELECT COUNT(*) FROM ingredient_templates WHERE user_id = $1", userID).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO ingredient_templates (user_id, name, carbs, fat, protein, kcal, macro_unit, default_quantity) VALUES
+			($1, 'Chicken Breast', 0, 3.6, 31, 165, 'per_100g', 150),
+			($1, 'Brown Rice', 23, 0.9, 2.7, 111, 'per_100g', 100),
+			($1, 'Broccoli', 7, 0.4, 2.8, 34, 'per_100g', 100),
+			($1, 'Salmon', 0, 13, 20, 208, 'per_100g', 150),
+			($1, 'Sweet Potato', 20, 0.1, 1.6, 86, 'per_100g', 150),
+			($1, 'Eggs', 1.1, 5.3, 6.3, 74, 'per_unit', 1),
+			($1, 'Greek Yogurt', 3.6, 0.4, 10, 59, 'per_100g', 100),
+			($1, 'Oatmeal', 12, 1.8, 2.4, 68, 'per_100g', 200),
+			($1, 'Banana', 23, 0.3, 1.1, 89, 'per_unit', 1),
+			($1, 'Almonds', 6, 49, 21, 579, 'per_100g', 30)
+	`, userID)
+	return err
+}
+
+// Meals
+
+// whereClause returns the SQL filter for the query along with its arguments
+func (q mealQuery) whereClause(userID int) (string, []interface{}) {
+	conditions := []string{"m.user_id = $1"}
+	args := []interface{}{userID}
+	if q.From != "" {
+		args = append(args, q.From)
+		conditions = append(conditions, fmt.Sprintf("m.datetime >= $%d", len(args)))
+	}
+	if q.To != "" {
+		args = append(args, q.To)
+		conditions = append(conditions, fmt.Sprintf("m.datetime < $%d", len(args)))
+	}
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+func (q mealQuery) orderClause() string {
+	direction := "ASC"
+	if q.Desc {
+		direction = "DESC"
+	}
+	return fmt.Sprintf("%s %s, m.id %s", q.SortBy, direction, direction)
+}
+
+// ListMeals returns one page of the user's meals matching the query, plus the total number of matches
+func (s *sqlStore) ListMeals(userID int, q mealQuery) ([]Meal, int, error) {
+	where, args := q.whereClause(userID)
+
+	var total int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM meals m "+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	// Page over meals first so the ingredient join only touches the meals being returned
+	limit := ""
+	if q.PageSize > 0 {
+		args = append(args, q.PageSize, (q.Page-1)*q.PageSize)
+		limit = fmt.Sprintf("LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+	}
+
+	rows, err := s.db.Query(fmt.Sprintf(`
+		SELECT m.id, m.name, m.datetime,
+		       i.id, i.name, i.quantity, i.quantity_unit, i.carbs, i.fat, i.protein, i.kcal,
+		       i.fibre, i.sugar, i.saturated_fat, i.sodium, i.alcohol, i.macro_unit, i.serving_size, i.ingredient_template_id
+		FROM (SELECT m.id, m.name, m.datetime FROM meals m %s ORDER BY %s %s) m
+		LEFT JOIN meal_ingredients mi ON m.id = mi.meal_id
+		LEFT JOIN ingredients i ON mi.ingredient_id = i.id
+		ORDER BY %s, i.id
+	`, where, q.orderClause(), limit, q.orderClause()), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	meals, err := scanMeals(rows)
+	return meals, total, err
+}
+
+func (s *sqlStore) GetMeal(userID, id int) (*Meal, error) {
+	rows, err := s.db.Query(`
+		SELECT m.id, m.name, m.datetime,
+		       i.id, i.name, i.quantity, i.quantity_unit, i.carbs, i.fat, i.protein, i.kcal,
+		       i.fibre, i.sugar, i.saturated_fat, i.sodium, i.alcohol, i.macro_unit, i.serving_size, i.ingredient_template_id
+		FROM meals m
+		LEFT JOIN meal_ingredients mi ON m.id = mi.meal_id
+		LEFT JOIN ingredients i ON mi.ingredient_id = i.id
+		WHERE m.id = $1 AND m.user_id = $2
+		ORDER BY i.id
+	`, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	meals, err := scanMeals(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(meals) == 0 {
+		return nil, errNotFound
+	}
+	return &meals[0], nil
+}
+
+func (s *sqlStore) CreateMeal(userID int, meal *Meal) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	mealID, err := insertMeal(tx, userID, meal)
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	meal.ID = mealID
+	return nil
+}
+
+// UpdateMeal replaces the meal's name, datetime and ingredients
+func (s *sqlStore) UpdateMeal(userID, id int, meal *Meal) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("UPDATE meals SET name = $1, datetime = $2 WHERE id = $3 AND user_id = $4", meal.Name, meal.DateTime, id, userID)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return errNotFound
+	}
+
+	// Ingredients belong to a single meal, so the old ones are deleted rather than just unlinked
+	if err = deleteMealIngredients(tx, id); err != nil {
+		return err
+	}
+
+	if err = insertMealIngredients(tx, userID, id, meal.Ingredients); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	meal.ID = id
+	return nil
+}
+
+func (s *sqlStore) DeleteMeal(userID, id int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = deleteMealIngredients(tx, id); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec("DELETE FROM meals WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return errNotFound
+	}
+
+	return tx.Commit()
+}
+
+// insertMeal inserts a meal with its ingredients and returns the meal's ID
+func insertMeal(tx *sql.Tx, userID int, meal *Meal) (int, error) {
+	var mealID int
+	err := tx.QueryRow("INSERT INTO meals (user_id, name, datetime) VALUES ($1, $2, $3) RETURNING id", userID, meal.Name, meal.DateTime).Scan(&mealID)
+	if err != nil {
+		return 0, err
+	}
+	return mealID, insertMealIngredients(tx, userID, mealID, meal.Ingredients)
+}
+
+// insertMealIngredients inserts ingredients and links them to a meal
+func insertMealIngredients(tx *sql.Tx, userID, mealID int, ingredients []Ingredient) error {
+	for i := range ingredients {
+		ingredient := &ingredients[i]
+		if err := insertIngredient(tx, userID, ingredient); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec("INSERT INTO meal_ingredients (meal_id, ingredient_id) VALUES ($1, $2)", mealID, ingredient.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return refreshTemplateUses(tx, ingredientTemplateIDs(ingredients))
+}
+
+// checkIngredientTemplateOwner returns errInvalidReference unless the ingredient template
+// link is empty or names one of the user's ingredient templates
+func checkIngredientTemplateOwner(tx *sql.Tx, userID int, templateID *int) error {
+	if templateID == nil {
+		return nil
+	}
+	var exists bool
+	err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM ingredient_templates WHERE id = $1 AND user_id = $2)", *templateID, userID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("%w: ingredient template %d not found", errInvalidReference, *templateID)
+	}
+	return nil
+}
+
+// deleteMealIngredients deletes a meal's ingredients (and so their meal_ingredients links)
+func deleteMealIngredients(tx *sql.Tx, mealID int) error {
+	rows, err := tx.Query(`
+		SELECT DISTINCT i.ingredient_template_id FROM ingredients i
+		JOIN meal_ingredients mi ON mi.ingredient_id = i.id
+		WHERE mi.meal_id = $1 AND i.ingredient_template_id IS NOT NULL
+	`, mealID)
+	if err != nil {
+		return err
+	}
+	var templateIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		templateIDs = append(templateIDs, id)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec("DELETE FROM ingredients WHERE id IN (SELECT ingredient_id FROM meal_ingredients WHERE meal_id = $1)", mealID); err != nil {
+		return err
+	}
+	return refreshTemplateUses(tx, templateIDs)
+}
+
+// scanMeals groups meal/ingredient join rows into meals, preserving the row order
+func scanMeals(rows *sql.Rows) ([]Meal, error) {
+	meals := []Meal{}
+	index := make(map[int]int)
+	for rows.Next() {
+		var mealID int
+		var mealName, mealDateTime string
+		var ingredientID sql.NullInt64
+		var ingredientName sql.NullString
+		var quantity, carbs, fat, protein, kcal sql.NullFloat64
+		var fibre, sugar, saturatedFat, sodium, alcohol sql.NullFloat64
+		var quantityUnit, macroUnit sql.NullString
+		var servingSize sql.NullFloat64
+		var templateID sql.NullInt64
+
+		err := rows.Scan(&mealID, &mealName, &mealDateTime, &ingredientID, &ingredientName, &quantity, &quantityUnit, &carbs, &fat, &protein, &kcal,
+			&fibre, &sugar, &saturatedFat, &sodium, &alcohol, &macroUnit, &servingSize, &templateID)
+		if err != nil {
+			return nil, err
+		}
+
+		i, exists := index[mealID]
+		if !exists {
+			meals = append(meals, Meal{
+				ID:          mealID,
+				Name:        mealName,
+				DateTime:    mealDateTime,
+				Ingredients: []Ingredient{},
+			})
+			i = len(meals) - 1
+			index[mealID] = i
+		}
+
+		if ingredientID.Valid {
+			ingredient := Ingredient{
+				ID:           int(ingredientID.Int64),
+				Name:         ingredientName.String,
+				Quantity:     quantity.Float64,
+				QuantityUnit: quantityUnit.String,
+				Carbs:        carbs.Float64,
+				Fat:          fat.Float64,
+				Protein:      protein.Float64,
+				Kcal:         kcal.Float64,
+				Nutrients:    nullNutrients(fibre, sugar, saturatedFat, sodium, alcohol),
+				MacroUnit:    macroUnit.String,
+			}
+			if servingSize.Valid {
+				ingredient.ServingSize = &servingSize.Float64
+			}
+			if templateID.Valid {
+				id := int(templateID.Int64)
+				ingredient.IngredientTemplateID = &id
+			}
+			meals[i].Ingredients = append(meals[i].Ingredients, ingredient)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range meals {
+		meals[i].computeTotals()
+	}
+	return meals, nil
+}
+
+// Ingredients
+
+// ListIngredients returns the user's ingredients. Recipe ingredients are rows of the same
+// table but belong to their recipe, so they are left out.
+func (s *sqlStore) ListIngredients(userID int) ([]Ingredient, error) {
+	rows, err := s.db.Query(`
+		SELECT id, name, quantity, quantity_unit, carbs, fat, protein, kcal,
+		       fibre, sugar, saturated_fat, sodium, alcohol, macro_unit, serving_size, ingredient_template_id
+		FROM ingredients i
+		WHERE user_id = $1
+		  AND NOT EXISTS (SELECT 1 FROM recipe_ingredients ri WHERE ri.ingredient_id = i.id)
+		ORDER BY name
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ingredients := []Ingredient{}
+	for rows.Next() {
+		var ingredient Ingredient
+		var servingSize sql.NullFloat64
+		var templateID sql.NullInt64
+		err := rows.Scan(&ingredient.ID, &ingredient.Name, &ingredient.Quantity, &ingredient.QuantityUnit, &ingredient.Carbs, &ingredient.Fat, &ingredient.Protein, &ingredient.Kcal,
+			&ingredient.Fibre, &ingredient.Sugar, &ingredient.SaturatedFat, &ingredient.Sodium, &ingredient.Alcohol, &ingredient.MacroUnit, &servingSize, &templateID)
+		if err != nil {
+			return nil, err
+		}
+		if servingSize.Valid {
+			ingredient.ServingSize = &servingSize.Float64
+		}
+		if templateID.Valid {
+			id := int(templateID.Int64)
+			ingredient.IngredientTemplateID = &id
+		}
+		ingredients = append(ingredients, ingredient)
+	}
+	return ingredients, rows.Err()
+}
+
+func (s *sqlStore) CreateIngredient(userID int, ingredient *Ingredient) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = insertIngredient(tx, userID, ingredient); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// insertIngredient inserts an ingredient after checking its ingredient template link
+func insertIngredient(tx *sql.Tx, userID int, ingredient *Ingredient) error {
+	if err := checkIngredientTemplateOwner(tx, userID, ingredient.IngredientTemplateID); err != nil {
+		return err
+	}
+	return tx.QueryRow(`
+		INSERT INTO ingredients (user_id, name, quantity, quantity_unit, carbs, fat, protein, kcal,
+			fibre, sugar, saturated_fat, sodium, alcohol, macro_unit, serving_size, ingredient_template_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+		RETURNING id
+	`, userID, ingredient.Name, ingredient.Quantity, ingredient.QuantityUnit, ingredient.Carbs, ingredient.Fat, ingredient.Protein, ingredient.Kcal,
+		ingredient.Fibre, ingredient.Sugar, ingredient.SaturatedFat, ingredient.Sodium, ingredient.Alcohol,
+		ingredient.MacroUnit, getFloatOrNil(ingredient.ServingSize), ingredient.IngredientTemplateID).Scan(&ingredient.ID)
+}
+
+// Ingredient templates
+
+func (s *sqlStore) ListIngredientTemplates(userID int) ([]IngredientTemplate, error) {
+	templates, err := s.queryIngredientTemplates("t.user_id = $1", userID)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.attachPortions(templates, "t.user_id = $1", userID); err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+// GetIngredientTemplate returns one of the user's ingredient templates with its portions
+func (s *sqlStore) GetIngredientTemplate(userID, id int) (*IngredientTemplate, error) {
+	templates, err := s.queryIngredientTemplates("t.id = $1 AND t.user_id = $2", id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(templates) == 0 {
+		return nil, errNotFound
+	}
+	if err = s.attachPortions(templates, "t.id = $1", id); err != nil {
+		return nil, err
+	}
+	return &templates[0], nil
+}
+
+// attachPortions fills in Portions for the templates from the portions matching the condition
+func (s *sqlStore) attachPortions(templates []IngredientTemplate, condition string, args ...interface{}) error {
+	portions, err := s.listPortions(condition, args...)
+	if err != nil {
+		return err
+	}
+	for i := range templates {
+		templates[i].Portions = portions[templates[i].ID]
+	}
+	return nil
+}
+
+// queryIngredientTemplates returns the ingredient templates (aliased t) matching the condition by name
+func (s *sqlStore) queryIngredientTemplates(condition string, args ...interface{}) ([]IngredientTemplate, error) {
+	rows, err := s.db.Query(`
+		SELECT t.id, t.name, t.carbs, t.fat, t.protein, t.kcal, t.fibre, t.sugar, t.saturated_fat, t.sodium, t.alcohol,
+		       t.macro_unit, t.serving_size, t.default_quantity, t.created_at, t.updated_at
+		FROM ingredient_templates t WHERE `+condition+` ORDER BY t.name
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := []IngredientTemplate{}
+	for rows.Next() {
+		var template IngredientTemplate
+		var servingSize, defaultQuantity sql.NullFloat64
+		var createdAt, updatedAt sql.NullString
+		err := rows.Scan(&template.ID, &template.Name, &template.Carbs, &template.Fat, &template.Protein, &template.Kcal,
+			&template.Fibre, &template.Sugar, &template.SaturatedFat, &template.Sodium, &template.Alcohol,
+			&template.MacroUnit, &servingSize, &defaultQuantity, &createdAt, &updatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if servingSize.Valid {
+			template.ServingSize = &servingSize.Float64
+		}
+		template.DefaultQuantity = defaultQuantity.Float64
+		template.CreatedAt = createdAt.String
+		template.UpdatedAt = updatedAt.String
+		templates = append(templates, template)
+	}
+	return templates, rows.Err()
+}
+
+func (s *sqlStore) CreateIngredientTemplate(userID int, template *IngredientTemplate) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = checkIngredientTemplateName(tx, userID, 0, template.Name); err != nil {
+		return err
+	}
+	if err = insertIngredientTemplate(tx, userID, template); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// checkIngredientTemplateName returns errDuplicate if another of the user's ingredient templates has the name
+func checkIngredientTemplateName(tx *sql.Tx, userID, id int, name string) error {
+	var exists bool
+	err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM ingredient_templates WHERE user_id = $1 AND name = $2 AND id <> $3)", userID, name, id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("%w: ingredient template %q", errDuplicate, name)
+	}
+	return nil
+}
+
+func insertIngredientTemplate(q queryer, userID int, template *IngredientTemplate) error {
+	return q.QueryRow(`
+		INSERT INTO ingredient_templates (user_id, name, carbs, fat, protein, kcal,
+			fibre, sugar, saturated_fat, sodium, alcohol, macro_unit, serving_size, default_quantity)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		RETURNING id
+	`, userID, template.Name, template.Carbs, template.Fat, template.Protein, template.Kcal,
+		template.Fibre, template.Sugar, template.SaturatedFat, template.Sodium, template.Alcohol,
+		template.MacroUnit, getFloatOrNil(template.ServingSize), template.DefaultQuantity).Scan(&template.ID)
+}
+
+func (s *sqlStore) UpdateIngredientTemplate(userID, id int, template *IngredientTemplate) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = checkIngredientTemplateName(tx, userID, id, template.Name); err != nil {
+		return err
+	}
+	result, err := tx.Exec(`
+		UPDATE ingredient_templates
+		SET name = $1, carbs = $2, fat = $3, protein = $4, kcal = $5,
+		    fibre = $6, sugar = $7, saturated_fat = $8, sodium = $9, alcohol = $10,
+		    macro_unit = $11, serving_size = $12, default_quantity = $13, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $14 AND user_id = $15
+	`, template.Name, template.Carbs, template.Fat, template.Protein, template.Kcal,
+		template.Fibre, template.Sugar, template.SaturatedFat, template.Sodium, template.Alcohol,
+		template.MacroUnit, getFloatOrNil(template.ServingSize), template.DefaultQuantity, id, userID)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return errNotFound
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	template.ID = id
+	return nil
+}
+
+func (s *sqlStore) DeleteIngredientTemplate(userID, id int) error {
+	return s.deleteOwned("ingredient_templates", userID, id)
+}
+
+// Meal templates
+
+func (s *sqlStore) ListMealTemplates(userID int) ([]MealTemplate, error) {
+	rows, err := s.db.Query(`
+		SELECT mt.id, mt.name, mt.description, mt.created_at, mt.updated_at,
+		       it.id, it.name, it.carbs, it.fat, it.protein, it.kcal,
+		       it.fibre, it.sugar, it.saturated_fat, it.sodium, it.alcohol, it.macro_unit, it.serving_size,
+		       mti.quantity
+		FROM meal_templates mt
+		LEFT JOIN meal_template_ingredients mti ON mt.id = mti.meal_template_id
+		LEFT JOIN ingredient_templates it ON mti.ingredient_template_id = it.id
+		WHERE mt.user_id = $1
+		ORDER BY mt.name, mt.id, it.name
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanMealTemplates(rows)
+}
+
+func (s *sqlStore) GetMealTemplate(userID, id int) (*MealTemplate, error) {
+	rows, err := s.db.Query(`
+		SELECT mt.id, mt.name, mt.description, mt.created_at, mt.updated_at,
+		       it.id, it.name, it.carbs, it.fat, it.protein, it.kcal,
+		       it.fibre, it.sugar, it.saturated_fat, it.sodium, it.alcohol, it.macro_unit, it.serving_size,
+		       mti.quantity
+		FROM meal_templates mt
+		LEFT JOIN meal_template_ingredients mti ON mt.id = mti.meal_template_id
+		LEFT JOIN ingredient_templates it ON mti.ingredient_template_id = it.id
+		WHERE mt.id = $1 AND mt.user_id = $2
+		ORDER BY it.name
+	`, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates, err := scanMealTemplates(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(templates) == 0 {
+		return nil, errNotFound
+	}
+	return &templates[0], nil
+}
+
+func (s *sqlStore) CreateMealTemplate(userID int, template *MealTemplate) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	templateID, err := insertMealTemplate(tx, userID, template)
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	template.ID = templateID
+	return nil
+}
+
+func (s *sqlStore) UpdateMealTemplate(userID, id int, template *MealTemplate) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("UPDATE meal_templates SET name = $1, description = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $3 AND user_id = $4",
+		template.Name, template.Description, id, userID)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return errNotFound
+	}
+
+	if _, err = tx.Exec("DELETE FROM meal_template_ingredients WHERE meal_template_id = $1", id); err != nil {
+		return err
+	}
+
+	if err = insertMealTemplateIngredients(tx, userID, id, template.Ingredients); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	template.ID = id
+	return nil
+}
+
+func (s *sqlStore) DeleteMealTemplate(userID, id int) error {
+	return s.deleteOwned("meal_templates", userID, id)
+}
+
+// insertMealTemplate inserts a meal template with its ingredients and returns the template's ID
+func insertMealTemplate(tx *sql.Tx, userID int, template *MealTemplate) (int, error) {
+	var templateID int
+	err := tx.QueryRow("INSERT INTO meal_templates (user_id, name, description) VALUES ($1, $2, $3) RETURNING id",
+		userID, template.Name, template.Description).Scan(&templateID)
+	if err != nil {
+		return 0, err
+	}
+	return templateID, insertMealTemplateIngredients(tx, userID, templateID, template.Ingredients)
+}
+
+// insertMealTemplateIngredients links ingredient templates owned by the user to a meal template
+func insertMealTemplateIngredients(tx *sql.Tx, userID, templateID int, ingredients []IngredientTemplate) error {
+	for _, ingredient := range ingredients {
+		quantity := ingredient.Quantity
+		if quantity == 0 {
+			quantity = 1.0 // Default to 1 if not specified
+		}
+		result, err := tx.Exec(`
+			INSERT INTO meal_template_ingredients (meal_template_id, ingredient_template_id, quantity)
+			SELECT $1, id, $3 FROM ingredient_templates WHERE id = $2 AND user_id = $4
+		`, templateID, ingredient.ID, quantity, userID)
+		if err != nil {
+			return err
+		}
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			return fmt.Errorf("%w: ingredient template %d not found", errInvalidReference, ingredient.ID)
+		}
+	}
+	return nil
+}
+
+// scanMealTemplates groups meal template/ingredient join rows into meal templates, preserving the row order
+func scanMealTemplates(rows *sql.Rows) ([]MealTemplate, error) {
+	templates := []MealTemplate{}
+	index := make(map[int]int)
+	for rows.Next() {
+		var templateID int
+		var templateName, templateDescription, createdAt, updatedAt sql.NullString
+		var ingredientID sql.NullInt64
+		var ingredientName sql.NullString
+		var carbs, fat, protein, kcal sql.NullFloat64
+		var fibre, sugar, saturatedFat, sodium, alcohol sql.NullFloat64
+		var macroUnit sql.NullString
+		var servingSize, quantity sql.NullFloat64
+
+		err := rows.Scan(&templateID, &templateName, &templateDescription, &createdAt, &updatedAt,
+			&ingredientID, &ingredientName, &carbs, &fat, &protein, &kcal,
+			&fibre, &sugar, &saturatedFat, &sodium, &alcohol, &macroUnit, &servingSize, &quantity)
+		if err != nil {
+			return nil, err
+		}
+
+		i, exists := index[templateID]
+		if !exists {
+			templates = append(templates, MealTemplate{
+				ID:          templateID,
+				Name:        templateName.String,
+				Description: templateDescription.String,
+				Ingredients: []IngredientTemplate{},
+				CreatedAt:   createdAt.String,
+				UpdatedAt:   updatedAt.String,
+			})
+			i = len(templates) - 1
+			index[templateID] = i
+		}
+
+		if ingredientID.Valid {
+			ingredient := IngredientTemplate{
+				ID:        int(ingredientID.Int64),
+				Name:      ingredientName.String,
+				Carbs:     carbs.Float64,
+				Fat:       fat.Float64,
+				Protein:   protein.Float64,
+				Kcal:      kcal.Float64,
+				Nutrients: nullNutrients(fibre, sugar, saturatedFat, sodium, alcohol),
+				MacroUnit: macroUnit.String,
+				Quantity:  quantity.Float64,
+			}
+			if servingSize.Valid {
+				ingredient.ServingSize = &servingSize.Float64
+			}
+			templates[i].Ingredients = append(templates[i].Ingredients, ingredient)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range templates {
+		templates[i].computeTotals()
+	}
+	return templates, nil
+}
+
+// Daily targets
+
+const dailyTargetsColumns = "id, effective_from, target_mode, body_weight_kg, carbs_min, carbs_max, fat_min, fat_max, protein_min, protein_max, kcal_min, kcal_max, " +
+	"fibre_min, fibre_max, sugar_min, sugar_max, saturated_fat_min, saturated_fat_max, sodium_min, sodium_max, alcohol_min, alcohol_max, " +
+	"carbs_min_relative, carbs_max_relative, fat_min_relative, fat_max_relative, protein_min_relative, protein_max_relative, created_at, updated_at"
+
+// GetDailyTargets returns the daily targets in effect on a date (YYYY-MM-DD), or nil if none
+// apply; see TargetTimeline.On
+func (s *sqlStore) GetDailyTargets(userID int, date string) (*DailyTargets, error) {
+	timeline, err := s.LoadTargetTimeline(userID, date, date)
+	if err != nil {
+		return nil, err
+	}
+	return timeline.On(date), nil
+}
+
+// ListDailyTargets returns all of the user's daily targets in the order they took effect
+func (s *sqlStore) ListDailyTargets(userID int) ([]DailyTargets, error) {
+	rows, err := s.db.Query("SELECT "+dailyTargetsColumns+" FROM daily_targets WHERE user_id = $1 ORDER BY effective_from, id", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []DailyTargets{}
+	for rows.Next() {
+		targets, err := scanDailyTargets(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, targets)
+	}
+	return list, rows.Err()
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanDailyTargets(row rowScanner) (DailyTargets, error) {
+	var targets DailyTargets
+	var effectiveFrom sql.NullString
+	var bodyWeight sql.NullFloat64
+	var carbsMin, carbsMax, fatMin, fatMax, proteinMin, proteinMax, kcalMin, kcalMax sql.NullFloat64
+	var fibreMin, fibreMax, sugarMin, sugarMax, saturatedFatMin, saturatedFatMax, sodiumMin, sodiumMax, alcoholMin, alcoholMax sql.NullFloat64
+	var carbsMinRel, carbsMaxRel, fatMinRel, fatMaxRel, proteinMinRel, proteinMaxRel sql.NullFloat64
+	var createdAt, updatedAt sql.NullString
+
+	err := row.Scan(&targets.ID, &effectiveFrom, &targets.Mode, &bodyWeight,
+		&carbsMin, &carbsMax, &fatMin, &fatMax, &proteinMin, &proteinMax, &kcalMin, &kcalMax,
+		&fibreMin, &fibreMax, &sugarMin, &sugarMax, &saturatedFatMin, &saturatedFatMax, &sodiumMin, &sodiumMax, &alcoholMin, &alcoholMax,
+		&carbsMinRel, &carbsMaxRel, &fatMinRel, &fatMaxRel, &proteinMinRel, &proteinMaxRel,
+		&createdAt, &updatedAt)
+	if err != nil {
+		return targets, err
+	}
+
+	targets.EffectiveFrom = dateString(effectiveFrom.String)
+	if bodyWeight.Valid {
+		targets.BodyWeight = &bodyWeight.Float64
+	}
+	targets.Relative = newRelativeTargets(carbsMinRel, carbsMaxRel, fatMinRel, fatMaxRel, proteinMinRel, proteinMaxRel)
+	targets.Carbs = newMacroTarget(carbsMin, carbsMax)
+	targets.Fat = newMacroTarget(fatMin, fatMax)
+	targets.Protein = newMacroTarget(proteinMin, proteinMax)
+	targets.Kcal = newMacroTarget(kcalMin, kcalMax)
+	targets.Fibre = newMacroTarget(fibreMin, fibreMax)
+	targets.Sugar = newMacroTarget(sugarMin, sugarMax)
+	targets.SaturatedFat = newMacroTarget(saturatedFatMin, saturatedFatMax)
+	targets.Sodium = newMacroTarget(sodiumMin, sodiumMax)
+	targets.Alcohol = newMacroTarget(alcoholMin, alcoholMax)
+	targets.CreatedAt = createdAt.String
+	targets.UpdatedAt = updatedAt.String
+	return targets, nil
+}
+
+// Helper function to reduce a scanned DATE column to YYYY-MM-DD. Drivers return DATE columns as
+// time.Time, which database/sql converts to an RFC 3339 string.
+func dateString(value string) string {
+	if len(value) > len(dateLayout) {
+		return value[:len(dateLayout)]
+	}
+	return value
+}
+
+func (s *sqlStore) CreateDailyTargets(userID int, targets *DailyTargets) error {
+	return insertDailyTargets(s.db, userID, targets)
+}
+
+func insertDailyTargets(q queryer, userID int, targets *DailyTargets) error {
+	return q.QueryRow(`
+		INSERT INTO daily_targets (user_id, effective_from, `+targetValueColumns+`)
+		VALUES ($1, $2, `+placeholders(3, len(targetValues(targets)))+`)
+		RETURNING id
+	`, append([]interface{}{userID, targets.EffectiveFrom}, targetValues(targets)...)...).Scan(&targets.ID)
+}
+
+// targetValueColumns are the columns holding a set of targets, in daily_targets and
+// target_profile_versions alike, in the order targetValues returns their values
+const targetValueColumns = "target_mode, body_weight_kg, " +
+	"carbs_min, carbs_max, fat_min, fat_max, protein_min, protein_max, kcal_min, kcal_max, " +
+	"fibre_min, fibre_max, sugar_min, sugar_max, saturated_fat_min, saturated_fat_max, sodium_min, sodium_max, alcohol_min, alcohol_max, " +
+	"carbs_min_relative, carbs_max_relative, fat_min_relative, fat_max_relative, protein_min_relative, protein_max_relative"
+
+// targetValues returns the values of targetValueColumns for a set of targets
+func targetValues(targets *DailyTargets) []interface{} {
+	return []interface{}{
+		targets.Mode, getFloatOrNil(targets.BodyWeight),
+		getMacroTargetFloat(targets.Carbs, "min"), getMacroTargetFloat(targets.Carbs, "max"),
+		getMacroTargetFloat(targets.Fat, "min"), getMacroTargetFloat(targets.Fat, "max"),
+		getMacroTargetFloat(targets.Protein, "min"), getMacroTargetFloat(targets.Protein, "max"),
+		getMacroTargetFloat(targets.Kcal, "min"), getMacroTargetFloat(targets.Kcal, "max"),
+		getMacroTargetFloat(targets.Fibre, "min"), getMacroTargetFloat(targets.Fibre, "max"),
+		getMacroTargetFloat(targets.Sugar, "min"), getMacroTargetFloat(targets.Sugar, "max"),
+		getMacroTargetFloat(targets.SaturatedFat, "min"), getMacroTargetFloat(targets.SaturatedFat, "max"),
+		getMacroTargetFloat(targets.Sodium, "min"), getMacroTargetFloat(targets.Sodium, "max"),
+		getMacroTargetFloat(targets.Alcohol, "min"), getMacroTargetFloat(targets.Alcohol, "max"),
+		getRelativeTargetFloat(targets.Relative, "carbs", "min"), getRelativeTargetFloat(targets.Relative, "carbs", "max"),
+		getRelativeTargetFloat(targets.Relative, "fat", "min"), getRelativeTargetFloat(targets.Relative, "fat", "max"),
+		getRelativeTargetFloat(targets.Relative, "protein", "min"), getRelativeTargetFloat(targets.Relative, "protein", "max"),
+	}
+}
+
+// Helper function to list count placeholders starting at $first, e.g. "$3, $4, $5"
+func placeholders(first, count int) string {
+	list := make([]string, count)
+	for i := range list {
+		list[i] = fmt.Sprintf("$%d", first+i)
+	}
+	return strings.Join(list, ", ")
+}
+
+// UpdateDailyTargets replaces a daily targets row. An empty EffectiveFrom keeps the stored date.
+func (s *sqlStore) UpdateDailyTargets(userID, id int, targets *DailyTargets) error {
+	var effectiveFrom interface{}
+	if targets.EffectiveFrom != "" {
+		effectiveFrom = targets.EffectiveFrom
+	}
+
+	var stored sql.NullString
+	err := s.db.QueryRow(`
+		UPDATE daily_targets
+		SET effective_from = COALESCE($1, effective_from),
+		    target_mode = $2, body_weight_kg = $3,
+		    carbs_min = $4, carbs_max = $5, fat_min = $6, fat_max = $7,
+		    protein_min = $8, protein_max = $9, kcal_min = $10, kcal_max = $11,
+		    fibre_min = $12, fibre_max = $13, sugar_min = $14, sugar_max = $15, saturated_fat_min = $16, saturated_fat_max = $17,
+		    sodium_min = $18, sodium_max = $19, alcohol_min = $20, alcohol_max = $21,
+		    carbs_min_relative = $22, carbs_max_relative = $23, fat_min_relative = $24, fat_max_relative = $25,
+		    protein_min_relative = $26, protein_max_relative = $27,
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = $28 AND user_id = $29
+		RETURNING effective_from
+	`,
+		effectiveFrom, targets.Mode, getFloatOrNil(targets.BodyWeight),
+		getMacroTargetFloat(targets.Carbs, "min"), getMacroTargetFloat(targets.Carbs, "max"),
+		getMacroTargetFloat(targets.Fat, "min"), getMacroTargetFloat(targets.Fat, "max"),
+		getMacroTargetFloat(targets.Protein, "min"), getMacroTargetFloat(targets.Protein, "max"),
+		getMacroTargetFloat(targets.Kcal, "min"), getMacroTargetFloat(targets.Kcal, "max"),
+		getMacroTargetFloat(targets.Fibre, "min"), getMacroTargetFloat(targets.Fibre, "max"),
+		getMacroTargetFloat(targets.Sugar, "min"), getMacroTargetFloat(targets.Sugar, "max"),
+		getMacroTargetFloat(targets.SaturatedFat, "min"), getMacroTargetFloat(targets.SaturatedFat, "max"),
+		getMacroTargetFloat(targets.Sodium, "min"), getMacroTargetFloat(targets.Sodium, "max"),
+		getMacroTargetFloat(targets.Alcohol, "min"), getMacroTargetFloat(targets.Alcohol, "max"),
+		getRelativeTargetFloat(targets.Relative, "carbs", "min"), getRelativeTargetFloat(targets.Relative, "carbs", "max"),
+		getRelativeTargetFloat(targets.Relative, "fat", "min"), getRelativeTargetFloat(targets.Relative, "fat", "max"),
+		getRelativeTargetFloat(targets.Relative, "protein", "min"), getRelativeTargetFloat(targets.Relative, "protein", "max"),
+		id, userID,
+	).Scan(&stored)
+	if err == sql.ErrNoRows {
+		return errNotFound
+	}
+	if err != nil {
+		return err
+	}
+	targets.ID = id
+	targets.EffectiveFrom = dateString(stored.String)
+	return nil
+}
+
+func (s *sqlStore) DeleteDailyTargets(userID, id int) error {
+	return s.deleteOwned("daily_targets", userID, id)
+}
+
+// Helper function to build a MacroTarget from nullable min/max columns, or nil if neither is set
+func newMacroTarget(min, max sql.NullFloat64) *MacroTarget {
+	if !min.Valid && !max.Valid {
+		return nil
+	}
+	target := &MacroTarget{}
+	if min.Valid {
+		v := min.Float64
+		target.Min = &v
+	}
+	if max.Valid {
+		v := max.Float64
+		target.Max = &v
+	}
+	return target
+}
+
+// deleteOwned deletes a row by ID from a user-scoped table, returning errNotFound if the user has no such row
+func (s *sqlStore) deleteOwned(table string, userID, id int) error {
+	result, err := s.db.Exec("DELETE FROM "+table+" WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return errNotFound
+	}
+	return nil
+}
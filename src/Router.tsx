@@ -1,20 +1,31 @@
-import { Routes, Route } from 'react-router-dom';
+import { ReactNode } from 'react';
+import { Routes, Route, Navigate } from 'react-router-dom';
+import { authToken } from './api';
 import { MealsPage } from './pages/MealsPage';
 import { AddMealPage } from './pages/AddMealPage';
 import { EditMealPage } from './pages/EditMealPage';
 import { TemplatesPage } from './pages/TemplatesPage';
 import { MealTemplatesPage } from './pages/MealTemplatesPage';
 import { DailyTargetsPage } from './pages/DailyTargetsPage';
+import { LoginPage } from './pages/LoginPage';
+
+function RequireAuth({ children }: { children: ReactNode }) {
+  if (!authToken.get()) {
+    return <Navigate to="/login" replace />;
+  }
+  return <>{children}</>;
+}
 
 export function Router() {
   return (
     <Routes>
-      <Route path="/" element={<MealsPage />} />
-      <Route path="/add-meal" element={<AddMealPage />} />
-      <Route path="/edit-meal/:id" element={<EditMealPage />} />
-      <Route path="/templates" element={<TemplatesPage />} />
-      <Route path="/meal-templates" element={<MealTemplatesPage />} />
-      <Route path="/daily-targets" element={<DailyTargetsPage />} />
+      <Route path="/" element={<RequireAuth><MealsPage /></RequireAuth>} />
+      <Route path="/add-meal" element={<RequireAuth><AddMealPage /></RequireAuth>} />
+      <Route path="/edit-meal/:id" element={<RequireAuth><EditMealPage /></RequireAuth>} />
+      <Route path="/templates" element={<RequireAuth><TemplatesPage /></RequireAuth>} />
+      <Route path="/meal-templates" element={<RequireAuth><MealTemplatesPage /></RequireAuth>} />
+      <Route path="/daily-targets" element={<RequireAuth><DailyTargetsPage /></RequireAuth>} />
+      <Route path="/login" element={<LoginPage />} />
     </Routes>
   );
 }
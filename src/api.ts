@@ -1,23 +1,86 @@
-import { Meal, Ingredient, IngredientTemplate, MealTemplate, DailyTargets } from './types';
+import { Meal, Ingredient, IngredientTemplate, MealTemplate, DailyTargets, AuthResponse, LogMealTemplateOptions, Recipe, LogRecipeOptions, IngredientTemplateUsage, IngredientPortion, Product, ReferenceFood, TargetPeriod, TargetProfile, TargetSchedule, TargetOverride, BodyMeasurement, WeightTrend, TDEEEstimate, ApplyTDEEOptions, Report, ImportPreset, ImportMapping, ImportResult, Backup, RestoreMode, RestoreResult } from './types';
 
 const API_BASE = '/api';
+const TOKEN_KEY = 'authToken';
+
+export const authToken = {
+  get: () => localStorage.getItem(TOKEN_KEY),
+  set: (token: string) => localStorage.setItem(TOKEN_KEY, token),
+  clear: () => localStorage.removeItem(TOKEN_KEY),
+};
+
+// fetch wrapper that sends the session token and returns to the login page when it is rejected
+async function apiFetch(input: string, init: RequestInit = {}): Promise<Response> {
+  const headers = new Headers(init.headers);
+  const token = authToken.get();
+  if (token) {
+    headers.set('Authorization', `Bearer ${token}`);
+  }
+
+  const response = await fetch(input, { ...init, headers });
+  if (response.status === 401) {
+    authToken.clear();
+    if (window.location.pathname !== '/login') {
+      window.location.assign('/login');
+    }
+  }
+  return response;
+}
 
 export const api = {
+  // Auth
+  async register(email: string, password: string): Promise<AuthResponse> {
+    const response = await fetch(`${API_BASE}/auth/register`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ email, password }),
+    });
+    if (!response.ok) {
+      const body = await response.json().catch(() => null);
+      throw new Error(body?.error || 'Failed to register');
+    }
+    const auth: AuthResponse = await response.json();
+    authToken.set(auth.token);
+    return auth;
+  },
+
+  async login(email: string, password: string): Promise<AuthResponse> {
+    const response = await fetch(`${API_BASE}/auth/login`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ email, password }),
+    });
+    if (!response.ok) {
+      const body = await response.json().catch(() => null);
+      throw new Error(body?.error || 'Failed to log in');
+    }
+    const auth: AuthResponse = await response.json();
+    authToken.set(auth.token);
+    return auth;
+  },
+
+  async logout(): Promise<void> {
+    await apiFetch(`${API_BASE}/auth/logout`, {
+      method: 'POST',
+    });
+    authToken.clear();
+  },
+
   // Meals
   async getMeals(): Promise<Meal[]> {
-    const response = await fetch(`${API_BASE}/meals`);
+    const response = await apiFetch(`${API_BASE}/meals`);
     if (!response.ok) throw new Error('Failed to fetch meals');
     return response.json();
   },
 
   async getMeal(id: number): Promise<Meal> {
-    const response = await fetch(`${API_BASE}/meals/${id}`);
+    const response = await apiFetch(`${API_BASE}/meals/${id}`);
     if (!response.ok) throw new Error('Failed to fetch meal');
     return response.json();
   },
 
   async createMeal(meal: Omit<Meal, 'id'>): Promise<Meal> {
-    const response = await fetch(`${API_BASE}/meals`, {
+    const response = await apiFetch(`${API_BASE}/meals`, {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify(meal),
@@ -27,7 +90,7 @@ export const api = {
   },
 
   async updateMeal(id: number, meal: Omit<Meal, 'id'>): Promise<Meal> {
-    const response = await fetch(`${API_BASE}/meals/${id}`, {
+    const response = await apiFetch(`${API_BASE}/meals/${id}`, {
       method: 'PUT',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify(meal),
@@ -37,21 +100,31 @@ export const api = {
   },
 
   async deleteMeal(id: number): Promise<void> {
-    const response = await fetch(`${API_BASE}/meals/${id}`, {
+    const response = await apiFetch(`${API_BASE}/meals/${id}`, {
       method: 'DELETE',
     });
     if (!response.ok) throw new Error('Failed to delete meal');
   },
 
+  async saveMealAsTemplate(id: number, options: { name?: string; description?: string } = {}): Promise<MealTemplate> {
+    const response = await apiFetch(`${API_BASE}/meals/${id}/save-as-template`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(options),
+    });
+    if (!response.ok) throw new Error('Failed to save meal as template');
+    return response.json();
+  },
+
   // Ingredients
   async getIngredients(): Promise<Ingredient[]> {
-    const response = await fetch(`${API_BASE}/ingredients`);
+    const response = await apiFetch(`${API_BASE}/ingredients`);
     if (!response.ok) throw new Error('Failed to fetch ingredients');
     return response.json();
   },
 
   async createIngredient(ingredient: Omit<Ingredient, 'id'>): Promise<Ingredient> {
-    const response = await fetch(`${API_BASE}/ingredients`, {
+    const response = await apiFetch(`${API_BASE}/ingredients`, {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify(ingredient),
@@ -62,13 +135,13 @@ export const api = {
 
   // Ingredient Templates
   async getIngredientTemplates(): Promise<IngredientTemplate[]> {
-    const response = await fetch(`${API_BASE}/ingredient-templates`);
+    const response = await apiFetch(`${API_BASE}/ingredient-templates`);
     if (!response.ok) throw new Error('Failed to fetch ingredient templates');
     return response.json();
   },
 
   async createIngredientTemplate(template: Omit<IngredientTemplate, 'id' | 'createdAt' | 'updatedAt'>): Promise<IngredientTemplate> {
-    const response = await fetch(`${API_BASE}/ingredient-templates`, {
+    const response = await apiFetch(`${API_BASE}/ingredient-templates`, {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify(template),
@@ -78,7 +151,7 @@ export const api = {
   },
 
   async updateIngredientTemplate(id: number, template: Omit<IngredientTemplate, 'id' | 'createdAt' | 'updatedAt'>): Promise<IngredientTemplate> {
-    const response = await fetch(`${API_BASE}/ingredient-templates/${id}`, {
+    const response = await apiFetch(`${API_BASE}/ingredient-templates/${id}`, {
       method: 'PUT',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify(template),
@@ -88,27 +161,115 @@ export const api = {
   },
 
   async deleteIngredientTemplate(id: number): Promise<void> {
-    const response = await fetch(`${API_BASE}/ingredient-templates/${id}`, {
+    const response = await apiFetch(`${API_BASE}/ingredient-templates/${id}`, {
       method: 'DELETE',
     });
     if (!response.ok) throw new Error('Failed to delete ingredient template');
   },
 
+  async searchIngredientTemplates(q: string, options: { page?: number; pageSize?: number } = {}): Promise<{ templates: IngredientTemplate[]; total: number }> {
+    const params = new URLSearchParams({ q });
+    if (options.page) params.set('page', String(options.page));
+    if (options.pageSize) params.set('pageSize', String(options.pageSize));
+    const response = await apiFetch(`${API_BASE}/ingredient-templates/search?${params}`);
+    if (!response.ok) throw new Error('Failed to search ingredient templates');
+    const templates: IngredientTemplate[] = await response.json();
+    return { templates, total: Number(response.headers.get('X-Total-Count') ?? templates.length) };
+  },
+
+  async getIngredientTemplateUsage(id: number): Promise<IngredientTemplateUsage[]> {
+    const response = await apiFetch(`${API_BASE}/ingredient-templates/${id}/usage`);
+    if (!response.ok) throw new Error('Failed to fetch ingredient template usage');
+    return response.json();
+  },
+
+  async getIngredientPortions(templateId: number): Promise<IngredientPortion[]> {
+    const response = await apiFetch(`${API_BASE}/ingredient-templates/${templateId}/portions`);
+    if (!response.ok) throw new Error('Failed to fetch portions');
+    return response.json();
+  },
+
+  async createIngredientPortion(templateId: number, portion: Omit<IngredientPortion, 'id'>): Promise<IngredientPortion> {
+    const response = await apiFetch(`${API_BASE}/ingredient-templates/${templateId}/portions`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(portion),
+    });
+    if (!response.ok) throw new Error('Failed to create portion');
+    return response.json();
+  },
+
+  async updateIngredientPortion(templateId: number, id: number, portion: Omit<IngredientPortion, 'id'>): Promise<IngredientPortion> {
+    const response = await apiFetch(`${API_BASE}/ingredient-templates/${templateId}/portions/${id}`, {
+      method: 'PUT',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(portion),
+    });
+    if (!response.ok) throw new Error('Failed to update portion');
+    return response.json();
+  },
+
+  async deleteIngredientPortion(templateId: number, id: number): Promise<void> {
+    const response = await apiFetch(`${API_BASE}/ingredient-templates/${templateId}/portions/${id}`, {
+      method: 'DELETE',
+    });
+    if (!response.ok) throw new Error('Failed to delete portion');
+  },
+
+  // Products
+  async getProductByBarcode(ean: string): Promise<Product> {
+    const response = await apiFetch(`${API_BASE}/products/barcode/${encodeURIComponent(ean)}`);
+    if (!response.ok) throw new Error(response.status === 404 ? 'Product not found' : 'Failed to look up barcode');
+    return response.json();
+  },
+
+  async createIngredientTemplateFromProduct(ean: string, name?: string): Promise<IngredientTemplate> {
+    const response = await apiFetch(`${API_BASE}/products/barcode/${encodeURIComponent(ean)}/ingredient-template`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(name ? { name } : {}),
+    });
+    if (!response.ok) throw new Error('Failed to create ingredient template from product');
+    return response.json();
+  },
+
+  // Reference foods
+  async searchReferenceFoods(q: string, options: { source?: ReferenceFood['source']; page?: number; pageSize?: number } = {}): Promise<{ foods: ReferenceFood[]; total: number }> {
+    const params = new URLSearchParams({ q });
+    if (options.source) params.set('source', options.source);
+    if (options.page) params.set('page', String(options.page));
+    if (options.pageSize) params.set('pageSize', String(options.pageSize));
+    const response = await apiFetch(`${API_BASE}/reference-foods?${params}`);
+    if (!response.ok) throw new Error('Failed to search reference foods');
+    const foods: ReferenceFood[] = await response.json();
+    return { foods, total: Number(response.headers.get('X-Total-Count') ?? foods.length) };
+  },
+
+  async createIngredientTemplateFromReferenceFood(id: number, name?: string): Promise<IngredientTemplate> {
+    const response = await apiFetch(`${API_BASE}/reference-foods/${id}/ingredient-template`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(name ? { name } : {}),
+    });
+    if (!response.ok) throw new Error('Failed to create ingredient template from reference food');
+    return response.json();
+  },
+
   // Meal Templates
   async getMealTemplates(): Promise<MealTemplate[]> {
-    const response = await fetch(`${API_BASE}/meal-templates`);
+    const response = await apiFetch(`${API_BASE}/meal-templates`);
     if (!response.ok) throw new Error('Failed to fetch meal templates');
     return response.json();
   },
 
   async getMealTemplate(id: number): Promise<MealTemplate> {
-    const response = await fetch(`${API_BASE}/meal-templates/${id}`);
+    const response = await apiFetch(`${API_BASE}/meal-templates/${id}`);
     if (!response.ok) throw new Error('Failed to fetch meal template');
     return response.json();
   },
 
   async createMealTemplate(template: Omit<MealTemplate, 'id' | 'createdAt' | 'updatedAt'>): Promise<MealTemplate> {
-    const response = await fetch(`${API_BASE}/meal-templates`, {
+    const response = await apiFetch(`${API_BASE}/meal-templates`, {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify(template),
@@ -118,7 +279,7 @@ export const api = {
   },
 
   async updateMealTemplate(id: number, template: Omit<MealTemplate, 'id' | 'createdAt' | 'updatedAt'>): Promise<MealTemplate> {
-    const response = await fetch(`${API_BASE}/meal-templates/${id}`, {
+    const response = await apiFetch(`${API_BASE}/meal-templates/${id}`, {
       method: 'PUT',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify(template),
@@ -128,15 +289,75 @@ export const api = {
   },
 
   async deleteMealTemplate(id: number): Promise<void> {
-    const response = await fetch(`${API_BASE}/meal-templates/${id}`, {
+    const response = await apiFetch(`${API_BASE}/meal-templates/${id}`, {
       method: 'DELETE',
     });
     if (!response.ok) throw new Error('Failed to delete meal template');
   },
 
+  async logMealTemplate(id: number, options: LogMealTemplateOptions): Promise<Meal> {
+    const response = await apiFetch(`${API_BASE}/meal-templates/${id}/log`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(options),
+    });
+    if (!response.ok) throw new Error('Failed to log meal template');
+    return response.json();
+  },
+
+  // Recipes
+  async getRecipes(): Promise<Recipe[]> {
+    const response = await apiFetch(`${API_BASE}/recipes`);
+    if (!response.ok) throw new Error('Failed to fetch recipes');
+    return response.json();
+  },
+
+  async getRecipe(id: number): Promise<Recipe> {
+    const response = await apiFetch(`${API_BASE}/recipes/${id}`);
+    if (!response.ok) throw new Error('Failed to fetch recipe');
+    return response.json();
+  },
+
+  async createRecipe(recipe: Omit<Recipe, 'id' | 'createdAt' | 'updatedAt'>): Promise<Recipe> {
+    const response = await apiFetch(`${API_BASE}/recipes`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(recipe),
+    });
+    if (!response.ok) throw new Error('Failed to create recipe');
+    return response.json();
+  },
+
+  async updateRecipe(id: number, recipe: Omit<Recipe, 'id' | 'createdAt' | 'updatedAt'>): Promise<Recipe> {
+    const response = await apiFetch(`${API_BASE}/recipes/${id}`, {
+      method: 'PUT',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(recipe),
+    });
+    if (!response.ok) throw new Error('Failed to update recipe');
+    return response.json();
+  },
+
+  async deleteRecipe(id: number): Promise<void> {
+    const response = await apiFetch(`${API_BASE}/recipes/${id}`, {
+      method: 'DELETE',
+    });
+    if (!response.ok) throw new Error('Failed to delete recipe');
+  },
+
+  async logRecipe(id: number, options: LogRecipeOptions): Promise<Meal> {
+    const response = await apiFetch(`${API_BASE}/recipes/${id}/log`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(options),
+    });
+    if (!response.ok) throw new Error('Failed to log recipe');
+    return response.json();
+  },
+
   // Daily Targets
   async getDailyTargets(): Promise<DailyTargets> {
-    const response = await fetch(`${API_BASE}/daily-targets`);
+    const response = await apiFetch(`${API_BASE}/daily-targets`);
     if (!response.ok) {
       if (response.status === 404) {
         throw new Error('Daily targets not found');
@@ -146,8 +367,14 @@ export const api = {
     return response.json();
   },
 
+  async getDailyTargetsTimeline(): Promise<TargetPeriod[]> {
+    const response = await apiFetch(`${API_BASE}/daily-targets/timeline`);
+    if (!response.ok) throw new Error('Failed to fetch daily targets timeline');
+    return response.json();
+  },
+
   async createDailyTargets(targets: Omit<DailyTargets, 'id' | 'createdAt' | 'updatedAt'>): Promise<DailyTargets> {
-    const response = await fetch(`${API_BASE}/daily-targets`, {
+    const response = await apiFetch(`${API_BASE}/daily-targets`, {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify(targets),
@@ -157,7 +384,7 @@ export const api = {
   },
 
   async updateDailyTargets(id: number, targets: Omit<DailyTargets, 'id' | 'createdAt' | 'updatedAt'>): Promise<DailyTargets> {
-    const response = await fetch(`${API_BASE}/daily-targets/${id}`, {
+    const response = await apiFetch(`${API_BASE}/daily-targets/${id}`, {
       method: 'PUT',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify(targets),
@@ -167,9 +394,203 @@ export const api = {
   },
 
   async deleteDailyTargets(id: number): Promise<void> {
-    const response = await fetch(`${API_BASE}/daily-targets/${id}`, {
+    const response = await apiFetch(`${API_BASE}/daily-targets/${id}`, {
       method: 'DELETE',
     });
     if (!response.ok) throw new Error('Failed to delete daily targets');
   },
+
+  // Target Profiles
+  async getTargetProfiles(): Promise<TargetProfile[]> {
+    const response = await apiFetch(`${API_BASE}/target-profiles`);
+    if (!response.ok) throw new Error('Failed to fetch target profiles');
+    return response.json();
+  },
+
+  async createTargetProfile(profile: Omit<TargetProfile, 'id' | 'versions' | 'createdAt' | 'updatedAt'>): Promise<TargetProfile> {
+    const response = await apiFetch(`${API_BASE}/target-profiles`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(profile),
+    });
+    if (!response.ok) throw new Error('Failed to create target profile');
+    return response.json();
+  },
+
+  async updateTargetProfile(id: number, profile: Omit<TargetProfile, 'id' | 'versions' | 'createdAt' | 'updatedAt'>): Promise<TargetProfile> {
+    const response = await apiFetch(`${API_BASE}/target-profiles/${id}`, {
+      method: 'PUT',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(profile),
+    });
+    if (!response.ok) throw new Error('Failed to update target profile');
+    return response.json();
+  },
+
+  async deleteTargetProfile(id: number): Promise<void> {
+    const response = await apiFetch(`${API_BASE}/target-profiles/${id}`, {
+      method: 'DELETE',
+    });
+    if (!response.ok) throw new Error('Failed to delete target profile');
+  },
+
+  async getTargetSchedule(): Promise<TargetSchedule> {
+    const response = await apiFetch(`${API_BASE}/target-schedule`);
+    if (!response.ok) throw new Error('Failed to fetch target schedule');
+    return response.json();
+  },
+
+  async updateTargetSchedule(schedule: TargetSchedule): Promise<TargetSchedule> {
+    const response = await apiFetch(`${API_BASE}/target-schedule`, {
+      method: 'PUT',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(schedule),
+    });
+    if (!response.ok) throw new Error('Failed to update target schedule');
+    return response.json();
+  },
+
+  async getTargetOverrides(from?: string, to?: string): Promise<TargetOverride[]> {
+    const params = new URLSearchParams();
+    if (from) params.set('from', from);
+    if (to) params.set('to', to);
+    const response = await apiFetch(`${API_BASE}/target-overrides?${params}`);
+    if (!response.ok) throw new Error('Failed to fetch target overrides');
+    return response.json();
+  },
+
+  async setTargetOverride(date: string, profileId: number): Promise<TargetOverride> {
+    const response = await apiFetch(`${API_BASE}/target-overrides/${date}`, {
+      method: 'PUT',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ profileId }),
+    });
+    if (!response.ok) throw new Error('Failed to set target override');
+    return response.json();
+  },
+
+  async deleteTargetOverride(date: string): Promise<void> {
+    const response = await apiFetch(`${API_BASE}/target-overrides/${date}`, {
+      method: 'DELETE',
+    });
+    if (!response.ok) throw new Error('Failed to delete target override');
+  },
+
+  // Body Measurements
+  async getMeasurements(from?: string, to?: string): Promise<BodyMeasurement[]> {
+    const params = new URLSearchParams();
+    if (from) params.set('from', from);
+    if (to) params.set('to', to);
+    const response = await apiFetch(`${API_BASE}/measurements?${params}`);
+    if (!response.ok) throw new Error('Failed to fetch measurements');
+    return response.json();
+  },
+
+  async createMeasurement(measurement: Omit<BodyMeasurement, 'id' | 'createdAt' | 'updatedAt'>): Promise<BodyMeasurement> {
+    const response = await apiFetch(`${API_BASE}/measurements`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(measurement),
+    });
+    if (!response.ok) throw new Error('Failed to create measurement');
+    return response.json();
+  },
+
+  async updateMeasurement(id: number, measurement: Omit<BodyMeasurement, 'id' | 'createdAt' | 'updatedAt'>): Promise<BodyMeasurement> {
+    const response = await apiFetch(`${API_BASE}/measurements/${id}`, {
+      method: 'PUT',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(measurement),
+    });
+    if (!response.ok) throw new Error('Failed to update measurement');
+    return response.json();
+  },
+
+  async deleteMeasurement(id: number): Promise<void> {
+    const response = await apiFetch(`${API_BASE}/measurements/${id}`, {
+      method: 'DELETE',
+    });
+    if (!response.ok) throw new Error('Failed to delete measurement');
+  },
+
+  async getWeightTrend(from?: string, to?: string): Promise<WeightTrend> {
+    const params = new URLSearchParams();
+    if (from) params.set('from', from);
+    if (to) params.set('to', to);
+    const response = await apiFetch(`${API_BASE}/measurements/trend?${params}`);
+    if (!response.ok) throw new Error('Failed to fetch weight trend');
+    return response.json();
+  },
+
+  // Insights
+  async getTDEEEstimate(options: { days?: number; to?: string; weeklyRate?: number } = {}): Promise<TDEEEstimate> {
+    const params = new URLSearchParams();
+    if (options.days) params.set('days', String(options.days));
+    if (options.to) params.set('to', options.to);
+    if (options.weeklyRate !== undefined) params.set('weeklyRate', String(options.weeklyRate));
+    const response = await apiFetch(`${API_BASE}/insights/tdee?${params}`);
+    if (!response.ok) throw new Error('Failed to fetch TDEE estimate');
+    return response.json();
+  },
+
+  async applyTDEETarget(options: ApplyTDEEOptions): Promise<DailyTargets> {
+    const response = await apiFetch(`${API_BASE}/insights/tdee/apply`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(options),
+    });
+    if (!response.ok) throw new Error('Failed to apply TDEE target');
+    return response.json();
+  },
+
+  // Reports
+  async getReport(period: 'week' | 'month' = 'week', from?: string, to?: string): Promise<Report> {
+    const params = new URLSearchParams({ period });
+    if (from) params.set('from', from);
+    if (to) params.set('to', to);
+    const response = await apiFetch(`${API_BASE}/reports?${params}`);
+    if (!response.ok) throw new Error('Failed to fetch report');
+    return response.json();
+  },
+
+  // Export
+  async exportMealsCSV(from?: string, to?: string): Promise<Blob> {
+    const params = new URLSearchParams();
+    if (from) params.set('from', from);
+    if (to) params.set('to', to);
+    const response = await apiFetch(`${API_BASE}/export/meals.csv?${params}`);
+    if (!response.ok) throw new Error('Failed to export meals');
+    return response.blob();
+  },
+
+  // Import
+  async importMeals(file: File, options: { preset?: ImportPreset; mapping?: ImportMapping; dryRun?: boolean } = {}): Promise<ImportResult> {
+    const form = new FormData();
+    form.append('file', file);
+    if (options.preset) form.append('preset', options.preset);
+    if (options.mapping) form.append('mapping', JSON.stringify(options.mapping));
+    if (options.dryRun) form.append('dryRun', 'true');
+    const response = await apiFetch(`${API_BASE}/import/meals`, { method: 'POST', body: form });
+    const result = await response.json().catch(() => ({}));
+    // A rejected import still returns the result with its row errors
+    if (!response.ok && !result.errors) throw new Error(result.error || 'Failed to import meals');
+    return result;
+  },
+
+  // Backup
+  async getBackup(): Promise<Backup> {
+    const response = await apiFetch(`${API_BASE}/backup`);
+    if (!response.ok) throw new Error('Failed to fetch backup');
+    return response.json();
+  },
+
+  async restoreBackup(backup: Backup, mode: RestoreMode = 'merge'): Promise<RestoreResult> {
+    const response = await apiFetch(`${API_BASE}/restore?mode=${mode}`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(backup),
+    });
+    if (!response.ok) throw new Error('Failed to restore backup');
+    return response.json();
+  },
 };
@@ -1,9 +1,10 @@
 /** @jsxImportSource @emotion/react */
 import React, { useState } from 'react';
 import { css } from '@emotion/react';
-import { IngredientTemplate } from '../types';
+import { IngredientTemplate, MacroUnit } from '../types';
 import { NumberField } from './NumberField';
 import { Button } from './Button';
+import { macroUnitLabels, pickNutrients } from '../utils/units';
 
 interface IngredientTemplateManagerProps {
   templates: IngredientTemplate[];
@@ -13,14 +14,15 @@ interface IngredientTemplateManagerProps {
   onClose: () => void;
 }
 
-interface EditableTemplate extends Omit<IngredientTemplate, 'carbs' | 'fat' | 'protein' | 'kcal'> {
+interface EditableTemplate extends Omit<IngredientTemplate, 'carbs' | 'fat' | 'protein' | 'kcal' | 'servingSize'> {
   key: string;
   carbs: number | null;
   fat: number | null;
   protein: number | null;
   kcal: number | null;
   defaultQuantity: number | null;
-  macroUnit: 'per_unit' | 'per_100g';
+  macroUnit: MacroUnit;
+  servingSize?: number | null;
 }
 
 export const IngredientTemplateManager: React.FC<IngredientTemplateManagerProps> = ({
@@ -66,6 +68,7 @@ export const IngredientTemplateManager: React.FC<IngredientTemplateManagerProps>
         kcal: newTemplate.kcal,
         defaultQuantity: newTemplate.defaultQuantity || 1,
         macroUnit: newTemplate.macroUnit,
+        servingSize: newTemplate.servingSize || undefined,
       });
       setNewTemplate({
         key: 'new',
@@ -98,6 +101,8 @@ export const IngredientTemplateManager: React.FC<IngredientTemplateManagerProps>
           kcal: editingTemplate.kcal,
           defaultQuantity: editingTemplate.defaultQuantity || 1,
           macroUnit: editingTemplate.macroUnit,
+          servingSize: editingTemplate.servingSize || undefined,
+          ...pickNutrients(editingTemplate),
         });
         setEditingTemplate(null);
       }
@@ -115,6 +120,8 @@ export const IngredientTemplateManager: React.FC<IngredientTemplateManagerProps>
       kcal: template.kcal,
       defaultQuantity: template.defaultQuantity || 1,
       macroUnit: template.macroUnit,
+      servingSize: template.servingSize,
+      ...pickNutrients(template),
     });
   };
 
@@ -191,12 +198,22 @@ export const IngredientTemplateManager: React.FC<IngredientTemplateManagerProps>
                 }
               `}
               value={newTemplate.macroUnit}
-              onChange={(e) => handleNewTemplateChange('macroUnit', e.target.value as 'per_unit' | 'per_100g')}
+              onChange={(e) => handleNewTemplateChange('macroUnit', e.target.value as MacroUnit)}
             >
-              <option value="per_100g">Per 100g</option>
-              <option value="per_unit">Per Unit</option>
+              {(Object.keys(macroUnitLabels) as MacroUnit[]).map(unit => (
+                <option key={unit} value={unit}>{macroUnitLabels[unit]}</option>
+              ))}
             </select>
           </div>
+
+          <NumberField
+            label="Serving Size (g)"
+            value={newTemplate.servingSize ?? null}
+            onChange={(value) => handleNewTemplateChange('servingSize', value)}
+            placeholder="optional"
+            step={0.1}
+            min={0}
+          />
           
           <NumberField
             label="Default Quantity"
@@ -208,7 +225,7 @@ export const IngredientTemplateManager: React.FC<IngredientTemplateManagerProps>
           />
           
           <NumberField
-            label={`Calories ${newTemplate.macroUnit === 'per_100g' ? 'per 100g' : 'per unit'}`}
+            label={`Calories ${macroUnitLabels[newTemplate.macroUnit]}`}
             value={newTemplate.kcal}
             onChange={(value) => handleNewTemplateChange('kcal', value)}
             placeholder="0"
@@ -217,7 +234,7 @@ export const IngredientTemplateManager: React.FC<IngredientTemplateManagerProps>
           />
 
           <NumberField
-            label={`Carbs ${newTemplate.macroUnit === 'per_100g' ? 'per 100g' : 'per unit'}`}
+            label={`Carbs ${macroUnitLabels[newTemplate.macroUnit]}`}
             value={newTemplate.carbs}
             onChange={(value) => handleNewTemplateChange('carbs', value)}
             placeholder="0"
@@ -226,7 +243,7 @@ export const IngredientTemplateManager: React.FC<IngredientTemplateManagerProps>
           />
           
           <NumberField
-            label={`Protein ${newTemplate.macroUnit === 'per_100g' ? 'per 100g' : 'per unit'}`}
+            label={`Protein ${macroUnitLabels[newTemplate.macroUnit]}`}
             value={newTemplate.protein}
             onChange={(value) => handleNewTemplateChange('protein', value)}
             placeholder="0"
@@ -235,7 +252,7 @@ export const IngredientTemplateManager: React.FC<IngredientTemplateManagerProps>
           />
           
           <NumberField
-            label={`Fat ${newTemplate.macroUnit === 'per_100g' ? 'per 100g' : 'per unit'}`}
+            label={`Fat ${macroUnitLabels[newTemplate.macroUnit]}`}
             value={newTemplate.fat}
             onChange={(value) => handleNewTemplateChange('fat', value)}
             placeholder="0"
@@ -367,12 +384,22 @@ export const IngredientTemplateManager: React.FC<IngredientTemplateManagerProps>
                             }
                           `}
                           value={editingTemplate.macroUnit}
-                          onChange={(e) => handleEditingTemplateChange('macroUnit', e.target.value as 'per_unit' | 'per_100g')}
+                          onChange={(e) => handleEditingTemplateChange('macroUnit', e.target.value as MacroUnit)}
                         >
-                          <option value="per_100g">Per 100g</option>
-                          <option value="per_unit">Per Unit</option>
+                          {(Object.keys(macroUnitLabels) as MacroUnit[]).map(unit => (
+                            <option key={unit} value={unit}>{macroUnitLabels[unit]}</option>
+                          ))}
                         </select>
                       </div>
+
+                      <NumberField
+                        label="Serving Size (g)"
+                        value={editingTemplate.servingSize ?? null}
+                        onChange={(value) => handleEditingTemplateChange('servingSize', value)}
+                        placeholder="optional"
+                        step={0.1}
+                        min={0}
+                      />
                       
                       <NumberField
                         label="Default Quantity"
@@ -384,7 +411,7 @@ export const IngredientTemplateManager: React.FC<IngredientTemplateManagerProps>
                       />
                       
                       <NumberField
-                        label={`Calories ${editingTemplate.macroUnit === 'per_100g' ? 'per 100g' : 'per unit'}`}
+                        label={`Calories ${macroUnitLabels[editingTemplate.macroUnit]}`}
                         value={editingTemplate.kcal}
                         onChange={(value) => handleEditingTemplateChange('kcal', value)}
                         placeholder="0"
@@ -393,7 +420,7 @@ export const IngredientTemplateManager: React.FC<IngredientTemplateManagerProps>
                       />
 
                       <NumberField
-                        label={`Carbs ${editingTemplate.macroUnit === 'per_100g' ? 'per 100g' : 'per unit'}`}
+                        label={`Carbs ${macroUnitLabels[editingTemplate.macroUnit]}`}
                         value={editingTemplate.carbs}
                         onChange={(value) => handleEditingTemplateChange('carbs', value)}
                         placeholder="0"
@@ -402,7 +429,7 @@ export const IngredientTemplateManager: React.FC<IngredientTemplateManagerProps>
                       />
                       
                       <NumberField
-                        label={`Protein ${editingTemplate.macroUnit === 'per_100g' ? 'per 100g' : 'per unit'}`}
+                        label={`Protein ${macroUnitLabels[editingTemplate.macroUnit]}`}
                         value={editingTemplate.protein}
                         onChange={(value) => handleEditingTemplateChange('protein', value)}
                         placeholder="0"
@@ -411,7 +438,7 @@ export const IngredientTemplateManager: React.FC<IngredientTemplateManagerProps>
                       />
                       
                       <NumberField
-                        label={`Fat ${editingTemplate.macroUnit === 'per_100g' ? 'per 100g' : 'per unit'}`}
+                        label={`Fat ${macroUnitLabels[editingTemplate.macroUnit]}`}
                         value={editingTemplate.fat}
                         onChange={(value) => handleEditingTemplateChange('fat', value)}
                         placeholder="0"
@@ -452,7 +479,7 @@ export const IngredientTemplateManager: React.FC<IngredientTemplateManagerProps>
                         font-weight: 600;
                         color: #333;
                       `}>{template.name}</div>
-                      <div css={css`text-align: center;`}>{template.macroUnit === 'per_100g' ? 'Per 100g' : 'Per Unit'}</div>
+                      <div css={css`text-align: center;`}>{macroUnitLabels[template.macroUnit]}</div>
                       <div css={css`text-align: center;`}>{template.defaultQuantity || 1}</div>
                       <div css={css`text-align: center;`}>{template.kcal}</div>
                       <div css={css`text-align: center;`}>{template.carbs}g</div>
@@ -1,10 +1,11 @@
 /** @jsxImportSource @emotion/react */
 import React, { useState, useEffect } from 'react';
 import { css } from '@emotion/react';
-import { Meal, Ingredient, IngredientTemplate, MealTemplate } from '../types';
+import { Meal, Ingredient, IngredientTemplate, MealTemplate, MacroUnit, QuantityUnit } from '../types';
 import { NumberField } from './NumberField';
 import { Button } from './Button';
 import { api } from '../api';
+import { macroUnitLabels, quantityUnitLabels, quantityUnitsFor, needsServingSize, pickNutrients } from '../utils/units';
 
 interface MealFormProps {
   initialData?: Meal;
@@ -22,7 +23,7 @@ interface EditableIngredient extends Omit<Ingredient, 'carbs' | 'fat' | 'protein
   fat: number | null;
   protein: number | null;
   kcal: number | null;
-  macroUnit: 'per_unit' | 'per_100g';
+  macroUnit: MacroUnit;
 }
 
 export const MealForm: React.FC<MealFormProps> = ({ 
@@ -71,6 +72,33 @@ export const MealForm: React.FC<MealFormProps> = ({
     })) as EditableIngredient[],
   });
 
+  // Fuzzy search of ingredient templates, ranked by the server (e.g. "chik brst" finds Chicken Breast)
+  const [templateQuery, setTemplateQuery] = useState('');
+  const [templateResults, setTemplateResults] = useState<IngredientTemplate[]>([]);
+  const [templateSearchError, setTemplateSearchError] = useState<string | null>(null);
+
+  useEffect(() => {
+    setTemplateSearchError(null);
+    if (templateQuery.trim() === '') {
+      setTemplateResults([]);
+      return;
+    }
+    let cancelled = false;
+    const timer = setTimeout(() => {
+      api.searchIngredientTemplates(templateQuery, { pageSize: 8 })
+        .then(({ templates }) => {
+          if (!cancelled) setTemplateResults(templates);
+        })
+        .catch(error => {
+          if (!cancelled) setTemplateSearchError(error instanceof Error ? error.message : 'Search failed');
+        });
+    }, 200);
+    return () => {
+      cancelled = true;
+      clearTimeout(timer);
+    };
+  }, [templateQuery]);
+
   // Update form data when initialData prop changes (for editing)
   useEffect(() => {
     console.log('MealForm: useEffect triggered, initialData:', initialData);
@@ -109,6 +137,18 @@ export const MealForm: React.FC<MealFormProps> = ({
     }));
   };
 
+  // A new macro unit may not convert from the current quantity unit, so start from its default
+  const handleMacroUnitChange = (key: string, macroUnit: MacroUnit) => {
+    setFormData(prev => ({
+      ...prev,
+      ingredients: prev.ingredients.map(ingredient =>
+        ingredient.key === key
+          ? { ...ingredient, macroUnit, quantityUnit: quantityUnitsFor(macroUnit)[0] }
+          : ingredient
+      )
+    }));
+  };
+
   const addIngredient = () => {
     const newIngredient: EditableIngredient = {
       key: Math.random().toString(36).substr(2, 9),
@@ -137,6 +177,9 @@ export const MealForm: React.FC<MealFormProps> = ({
       protein: template.protein,
       kcal: template.kcal,
       macroUnit: template.macroUnit,
+      servingSize: template.servingSize,
+      ...pickNutrients(template),
+      ingredientTemplateId: template.id,
     };
     
     setFormData(prev => ({
@@ -155,6 +198,9 @@ export const MealForm: React.FC<MealFormProps> = ({
       protein: ingredient.protein,
       kcal: ingredient.kcal,
       macroUnit: ingredient.macroUnit,
+      servingSize: ingredient.servingSize,
+      ...pickNutrients(ingredient),
+      ingredientTemplateId: ingredient.id,
     }));
     
     setFormData(prev => ({
@@ -189,6 +235,8 @@ export const MealForm: React.FC<MealFormProps> = ({
         protein: ingredient.protein,
         kcal: ingredient.kcal,
         macroUnit: ingredient.macroUnit,
+        servingSize: ingredient.servingSize,
+        ...pickNutrients(ingredient),
       });
     }
   };
@@ -494,15 +542,61 @@ export const MealForm: React.FC<MealFormProps> = ({
                         }
                       `}
                       value={ingredient.macroUnit}
-                      onChange={(e) => handleIngredientChange(ingredient.key, 'macroUnit', e.target.value as 'per_unit' | 'per_100g')}
+                      onChange={(e) => handleMacroUnitChange(ingredient.key, e.target.value as MacroUnit)}
+                    >
+                      {(Object.keys(macroUnitLabels) as MacroUnit[]).map(unit => (
+                        <option key={unit} value={unit}>{macroUnitLabels[unit]}</option>
+                      ))}
+                    </select>
+                  </div>
+
+                  <div css={css`
+                    display: flex;
+                    flex-direction: column;
+                  `}>
+                    <label css={css`
+                      display: block;
+                      margin-bottom: 0.5rem;
+                      font-weight: 600;
+                      color: #333;
+                    `}>Quantity Unit</label>
+                    <select
+                      css={css`
+                        width: 100%;
+                        padding: 0.5rem;
+                        border: 1px solid #ddd;
+                        border-radius: var(--border-radius);
+                        font-size: 0.875rem;
+                        background: white;
+                        
+                        &:focus {
+                          outline: none;
+                          border-color: #007bff;
+                          box-shadow: 0 0 0 2px rgba(0, 123, 255, 0.25);
+                        }
+                      `}
+                      value={ingredient.quantityUnit ?? quantityUnitsFor(ingredient.macroUnit)[0]}
+                      onChange={(e) => handleIngredientChange(ingredient.key, 'quantityUnit', e.target.value)}
                     >
-                      <option value="per_unit">Per Unit</option>
-                      <option value="per_100g">Per 100g</option>
+                      {quantityUnitsFor(ingredient.macroUnit).map(unit => (
+                        <option key={unit} value={unit}>{quantityUnitLabels[unit]}</option>
+                      ))}
                     </select>
                   </div>
+
+                  {needsServingSize(ingredient.macroUnit, ingredient.quantityUnit) && (
+                    <NumberField
+                      label="Serving Size (g)"
+                      value={ingredient.servingSize ?? null}
+                      onChange={(value) => handleIngredientChange(ingredient.key, 'servingSize', value)}
+                      placeholder="0"
+                      step={0.1}
+                      min={0}
+                    />
+                  )}
                   
                   <NumberField
-                    label={`Calories ${ingredient.macroUnit === 'per_100g' ? 'per 100g' : 'per unit'}`}
+                    label={`Calories ${macroUnitLabels[ingredient.macroUnit]}`}
                     value={ingredient.kcal}
                     onChange={(value) => handleIngredientChange(ingredient.key, 'kcal', value)}
                     placeholder="0"
@@ -511,7 +605,7 @@ export const MealForm: React.FC<MealFormProps> = ({
                   />
                   
                   <NumberField
-                    label={`Carbs (g) ${ingredient.macroUnit === 'per_100g' ? 'per 100g' : 'per unit'}`}
+                    label={`Carbs (g) ${macroUnitLabels[ingredient.macroUnit]}`}
                     value={ingredient.carbs}
                     onChange={(value) => handleIngredientChange(ingredient.key, 'carbs', value)}
                     placeholder="0"
@@ -520,7 +614,7 @@ export const MealForm: React.FC<MealFormProps> = ({
                   />
                   
                   <NumberField
-                    label={`Protein (g) ${ingredient.macroUnit === 'per_100g' ? 'per 100g' : 'per unit'}`}
+                    label={`Protein (g) ${macroUnitLabels[ingredient.macroUnit]}`}
                     value={ingredient.protein}
                     onChange={(value) => handleIngredientChange(ingredient.key, 'protein', value)}
                     placeholder="0"
@@ -529,7 +623,7 @@ export const MealForm: React.FC<MealFormProps> = ({
                   />
                   
                   <NumberField
-                    label={`Fat (g) ${ingredient.macroUnit === 'per_100g' ? 'per 100g' : 'per unit'}`}
+                    label={`Fat (g) ${macroUnitLabels[ingredient.macroUnit]}`}
                     value={ingredient.fat}
                     onChange={(value) => handleIngredientChange(ingredient.key, 'fat', value)}
                     placeholder="0"
@@ -596,6 +690,59 @@ export const MealForm: React.FC<MealFormProps> = ({
               font-weight: 500;
               text-align: center;
             `}>Or add from template:</span>
+            <input
+              type="search"
+              placeholder="Search templates..."
+              value={templateQuery}
+              onChange={(e) => setTemplateQuery(e.target.value)}
+              css={css`
+                padding: 0.75rem;
+                border: 1px solid #ddd;
+                border-radius: var(--border-radius);
+                font-size: 1rem;
+              `}
+            />
+            {templateSearchError && (
+              <span css={css`
+                color: #721c24;
+                font-size: 0.9rem;
+              `}>{templateSearchError}</span>
+            )}
+            {templateResults.length > 0 && (
+              <ul css={css`
+                list-style: none;
+                margin: 0;
+                padding: 0;
+                border: 1px solid #ddd;
+                border-radius: var(--border-radius);
+              `}>
+                {templateResults.map(template => (
+                  <li key={template.id}>
+                    <button
+                      type="button"
+                      onClick={() => {
+                        addIngredientFromTemplate(template);
+                        setTemplateQuery('');
+                      }}
+                      css={css`
+                        width: 100%;
+                        padding: 0.5rem 0.75rem;
+                        border: none;
+                        background: white;
+                        text-align: left;
+                        cursor: pointer;
+
+                        &:hover {
+                          background-color: #f0f7ff;
+                        }
+                      `}
+                    >
+                      {template.name}
+                    </button>
+                  </li>
+                ))}
+              </ul>
+            )}
             <select
               css={css`
                 padding: 0.75rem 1.5rem;
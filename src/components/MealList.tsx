@@ -3,6 +3,7 @@ import React, { useState, useEffect } from 'react';
 import { css } from '@emotion/react';
 import { Meal, DailyTargets } from '../types';
 import { calculateMacroProgress, getProgressColor, getProgressText, getProgressBarWidth, shouldShowOverflowIndicator } from '../utils/macroProgress';
+import { macroUnitLabels, quantityUnitLabels } from '../utils/units';
 
 interface MealListProps {
   meals: Meal[];
@@ -109,7 +110,13 @@ export const MealList: React.FC<MealListProps> = ({
       (totals, ingredient) => {
         let actualCarbs, actualFat, actualProtein, actualKcal;
         
-        if (ingredient.macroUnit === 'per_100g' && ingredient.quantity > 0) {
+        if (ingredient.totals) {
+          // Computed by the server, which converts quantity units
+          actualCarbs = ingredient.totals.carbs;
+          actualFat = ingredient.totals.fat;
+          actualProtein = ingredient.totals.protein;
+          actualKcal = ingredient.totals.kcal;
+        } else if (ingredient.macroUnit === 'per_100g' && ingredient.quantity > 0) {
           // For per_100g, scale the macros based on quantity
           const multiplier = ingredient.quantity / 100;
           actualCarbs = ingredient.carbs * multiplier;
@@ -759,7 +766,9 @@ export const MealList: React.FC<MealListProps> = ({
                               overflow-wrap: break-word;
                               font-size: clamp(0.8rem, 2.5vw, 0.9rem);
                             `}>
-                              {ingredient.name} {ingredient.macroUnit === 'per_100g' 
+                              {ingredient.name} {ingredient.quantityUnit && !['g', 'unit'].includes(ingredient.quantityUnit)
+                                ? `(${formatQuantity(ingredient.quantity)} ${quantityUnitLabels[ingredient.quantityUnit]})`
+                                : ingredient.macroUnit === 'per_100g' 
                                 ? `(${parseFloat(ingredient.quantity.toFixed(2))}g)` 
                                 : ingredient.quantity !== 1 
                                   ? ` x ${formatQuantity(ingredient.quantity)}` 
@@ -789,6 +798,9 @@ export const MealList: React.FC<MealListProps> = ({
                               `}>
                                 <span>kcal</span>
                                 <span>{(() => {
+                                  if (ingredient.totals) {
+                                    return ingredient.totals.kcal.toFixed(0);
+                                  }
                                   if (ingredient.macroUnit === 'per_100g' && ingredient.quantity > 0) {
                                     return (ingredient.kcal * ingredient.quantity / 100).toFixed(0);
                                   }
@@ -812,6 +824,9 @@ export const MealList: React.FC<MealListProps> = ({
                               `}>
                                 <span>Carbs</span>
                                 <span>{(() => {
+                                  if (ingredient.totals) {
+                                    return ingredient.totals.carbs.toFixed(1);
+                                  }
                                   if (ingredient.macroUnit === 'per_100g' && ingredient.quantity > 0) {
                                     return (ingredient.carbs * ingredient.quantity / 100).toFixed(1);
                                   }
@@ -835,6 +850,9 @@ export const MealList: React.FC<MealListProps> = ({
                               `}>
                                 <span>Protein</span>
                                 <span>{(() => {
+                                  if (ingredient.totals) {
+                                    return ingredient.totals.protein.toFixed(1);
+                                  }
                                   if (ingredient.macroUnit === 'per_100g' && ingredient.quantity > 0) {
                                     return (ingredient.protein * ingredient.quantity / 100).toFixed(1);
                                   }
@@ -858,6 +876,9 @@ export const MealList: React.FC<MealListProps> = ({
                               `}>
                                 <span>Fat</span>
                                 <span>{(() => {
+                                  if (ingredient.totals) {
+                                    return ingredient.totals.fat.toFixed(1);
+                                  }
                                   if (ingredient.macroUnit === 'per_100g' && ingredient.quantity > 0) {
                                     return (ingredient.fat * ingredient.quantity / 100).toFixed(1);
                                   }
@@ -872,10 +893,7 @@ export const MealList: React.FC<MealListProps> = ({
                                 color: #666;
                                 text-align: center;
                               `}>
-                                {ingredient.macroUnit === 'per_100g' 
-                                  ? `Per 100g: ${ingredient.carbs}g carbs, ${ingredient.fat}g fat, ${ingredient.protein}g protein, ${ingredient.kcal} kcal`
-                                  : `Per unit: ${ingredient.carbs}g carbs, ${ingredient.fat}g fat, ${ingredient.protein}g protein, ${ingredient.kcal} kcal`
-                                }
+                                {`Per ${macroUnitLabels[ingredient.macroUnit].replace(/^per /, '')}: ${ingredient.carbs}g carbs, ${ingredient.fat}g fat, ${ingredient.protein}g protein, ${ingredient.kcal} kcal`}
                               </div>
                             )}
                           </div>
@@ -2,6 +2,7 @@ import { ReactNode } from 'react';
 import { Link, useLocation, useNavigate } from 'react-router-dom';
 import { css } from '@emotion/react';
 import { Button } from './Button';
+import { api } from '../api';
 
 interface SiteWrapperProps {
   children: ReactNode;
@@ -73,9 +74,24 @@ const addMealButton = css`
   }
 `;
 
+const navActions = css`
+  display: flex;
+  gap: 0.5rem;
+  justify-content: center;
+`;
+
 export function SiteWrapper({ children }: SiteWrapperProps) {
   const location = useLocation();
   const navigate = useNavigate();
+
+  const handleLogout = async () => {
+    await api.logout();
+    navigate('/login');
+  };
+
+  if (location.pathname === '/login') {
+    return <div css={siteContainer}>{children}</div>;
+  }
   
   return (
     <div css={siteContainer}>
@@ -112,15 +128,25 @@ export function SiteWrapper({ children }: SiteWrapperProps) {
             </Link>
           </div>
           
-          <Button
-            buttonStyle="solid"
-            color="#28a745"
-            size="regular"
-            onClick={() => navigate('/add-meal')}
-            css={addMealButton}
-          >
-            + Add Meal
-          </Button>
+          <div css={navActions}>
+            <Button
+              buttonStyle="solid"
+              color="#28a745"
+              size="regular"
+              onClick={() => navigate('/add-meal')}
+              css={addMealButton}
+            >
+              + Add Meal
+            </Button>
+            <Button
+              buttonStyle="outline"
+              color="#6c757d"
+              size="regular"
+              onClick={handleLogout}
+            >
+              Log Out
+            </Button>
+          </div>
         </div>
       </nav>
       {children}
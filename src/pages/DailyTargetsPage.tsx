@@ -14,6 +14,15 @@ const errorMessage = css`
   border: 1px solid #f5c6cb;
 `;
 
+const infoMessage = css`
+  background: #d1ecf1;
+  color: #0c5460;
+  padding: var(--container-padding);
+  border-radius: var(--border-radius);
+  margin-bottom: 1rem;
+  border: 1px solid #bee5eb;
+`;
+
 const successMessage = css`
   background: #d4edda;
   color: #155724;
@@ -56,12 +65,14 @@ export function DailyTargetsPage() {
       setError(null);
       setSuccess(null);
       
-      if (targets?.id) {
-        // Update existing targets
+      // Targets that started before today are kept as history, so changes start a new period
+      const today = new Date().toISOString().slice(0, 10);
+      if (targets?.id && targets.effectiveFrom === today) {
+        // Update today's targets
         await api.updateDailyTargets(targets.id, newTargets);
         setSuccess('Daily targets updated successfully!');
       } else {
-        // Create new targets
+        // Create new targets, effective from today
         const createdTargets = await api.createDailyTargets(newTargets);
         setTargets(createdTargets);
         setSuccess('Daily targets created successfully!');
@@ -108,6 +119,11 @@ export function DailyTargetsPage() {
     <PageWrapper title="Daily Targets" subtitle="Set your daily macro targets">
       {error && <div css={errorMessage}>{error}</div>}
       {success && <div css={successMessage}>{success}</div>}
+      {targets?.profileName && (
+        <div css={infoMessage}>
+          Today uses the "{targets.profileName}" target profile. Saving here changes your default targets.
+        </div>
+      )}
       
       <DailyTargetsManager
         targets={targets}
@@ -0,0 +1,128 @@
+import { useState, FormEvent } from 'react';
+import { useNavigate } from 'react-router-dom';
+import { css } from '@emotion/react';
+import { api } from '../api';
+import { Button } from '../components/Button';
+import { PageWrapper } from '../components/PageWrapper';
+
+const card = css`
+  max-width: 400px;
+  margin: 0 auto;
+  background: white;
+  padding: clamp(1.25rem, 5vw, 2rem);
+  border-radius: var(--border-radius);
+  box-shadow: 0 2px 10px rgba(0, 0, 0, 0.1);
+  display: flex;
+  flex-direction: column;
+  gap: 1rem;
+`;
+
+const label = css`
+  display: flex;
+  flex-direction: column;
+  gap: 0.25rem;
+  font-size: clamp(0.8rem, 2.5vw, 0.875rem);
+  font-weight: 600;
+  color: #666;
+`;
+
+const input = css`
+  padding: clamp(0.5rem, 2vw, 0.6rem);
+  border: 1px solid #ddd;
+  border-radius: var(--border-radius);
+  font-size: clamp(0.9rem, 2.5vw, 1rem);
+
+  &:focus {
+    outline: none;
+    border-color: #007bff;
+    box-shadow: 0 0 0 2px rgba(0, 123, 255, 0.25);
+  }
+`;
+
+const errorMessage = css`
+  background: #f8d7da;
+  color: #721c24;
+  padding: var(--container-padding);
+  border-radius: var(--border-radius);
+  border: 1px solid #f5c6cb;
+`;
+
+const switchMode = css`
+  background: none;
+  border: none;
+  color: #007bff;
+  cursor: pointer;
+  font-size: 0.875rem;
+  padding: 0;
+`;
+
+export function LoginPage() {
+  const [mode, setMode] = useState<'login' | 'register'>('login');
+  const [email, setEmail] = useState('');
+  const [password, setPassword] = useState('');
+  const [error, setError] = useState<string | null>(null);
+  const [submitting, setSubmitting] = useState(false);
+  const navigate = useNavigate();
+
+  const handleSubmit = async (e: FormEvent) => {
+    e.preventDefault();
+    try {
+      setSubmitting(true);
+      setError(null);
+      if (mode === 'login') {
+        await api.login(email, password);
+      } else {
+        await api.register(email, password);
+      }
+      navigate('/');
+    } catch (err) {
+      setError(err instanceof Error ? err.message : 'Something went wrong');
+    } finally {
+      setSubmitting(false);
+    }
+  };
+
+  return (
+    <PageWrapper
+      title="Macro Tracker"
+      subtitle={mode === 'login' ? 'Log in to your account' : 'Create an account'}
+    >
+      <form css={card} onSubmit={handleSubmit}>
+        {error && <div css={errorMessage}>{error}</div>}
+        <label css={label}>
+          Email
+          <input
+            css={input}
+            type="email"
+            value={email}
+            onChange={(e) => setEmail(e.target.value)}
+            autoComplete="email"
+            required
+          />
+        </label>
+        <label css={label}>
+          Password
+          <input
+            css={input}
+            type="password"
+            value={password}
+            onChange={(e) => setPassword(e.target.value)}
+            autoComplete={mode === 'login' ? 'current-password' : 'new-password'}
+            minLength={mode === 'register' ? 8 : undefined}
+            required
+          />
+        </label>
+        <Button buttonStyle="solid" color="#007bff" size="large" isSubmit disabled={submitting}>
+          {mode === 'login' ? 'Log In' : 'Register'}
+        </Button>
+        <button
+          type="button"
+          css={switchMode}
+          onClick={() => setMode(mode === 'login' ? 'register' : 'login')}
+        >
+          {mode === 'login' ? "Don't have an account? Register" : 'Already have an account? Log in'}
+        </button>
+      </form>
+    </PageWrapper>
+  );
+}
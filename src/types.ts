@@ -1,24 +1,96 @@
-export interface Ingredient {
+// What macros are given per; see units.go
+export type MacroUnit = 'per_unit' | 'per_100g' | 'per_100ml' | 'per_oz' | 'per_serving';
+
+// What an ingredient quantity is entered in; the server converts it to the macro unit
+export type QuantityUnit = 'unit' | 'g' | 'kg' | 'oz' | 'lb' | 'ml' | 'l' | 'tsp' | 'tbsp' | 'fl_oz' | 'cup' | 'serving';
+
+// Label values tracked besides the four macros, per the macro unit. Sodium is in mg, the rest in g.
+export interface Nutrients {
+  fibre?: number;
+  sugar?: number;
+  saturatedFat?: number;
+  sodium?: number;
+  alcohol?: number;
+}
+
+export interface MacroTotals extends Nutrients {
+  carbs: number;
+  fat: number;
+  protein: number;
+  kcal: number;
+}
+
+export interface Ingredient extends Nutrients {
   id?: number;
   name: string;
   quantity: number;
+  quantityUnit?: QuantityUnit; // Defaults to the macro unit's own (g for per_100g)
   carbs: number;
   fat: number;
   protein: number;
   kcal: number;
-  macroUnit: 'per_unit' | 'per_100g';
+  macroUnit: MacroUnit;
+  servingSize?: number; // Grams per serving, to convert between servings and weights
+  ingredientTemplateId?: number; // Template the ingredient was added from, if any
+  portion?: string; // When sent: quantity is a number of this template portion, resolved to grams by the server
+  recipeId?: number; // When sent: quantity (in quantityUnit, default serving) is of this recipe, resolved to its per_100g macros by the server
+  totals?: MacroTotals; // Computed by the server for the quantity eaten
 }
 
-export interface IngredientTemplate {
+export interface IngredientPortion {
+  id?: number;
+  name: string; // e.g. slice, cup, scoop
+  grams: number; // Weight of one portion
+}
+
+export interface IngredientTemplateUsage {
+  mealId: number;
+  mealName: string;
+  datetime: string;
+  ingredient: Ingredient;
+}
+
+export interface IngredientTemplate extends Nutrients {
   id?: number;
   name: string;
   carbs: number;
   fat: number;
   protein: number;
   kcal: number;
-  macroUnit: 'per_unit' | 'per_100g';
+  macroUnit: MacroUnit;
+  servingSize?: number; // Grams per serving
   defaultQuantity?: number; // Default quantity when used in meals
   quantity?: number; // Quantity when used in meal templates
+  totals?: MacroTotals; // Computed by the server for quantity when used in meal templates
+  portions?: IngredientPortion[];
+}
+
+// A packaged food from the local Open Food Facts import, with macros per 100g
+export interface Product extends Nutrients {
+  code: string;
+  name: string;
+  brands?: string;
+  carbs: number;
+  fat: number;
+  protein: number;
+  kcal: number;
+  macroUnit: MacroUnit;
+  servingSize?: number; // Grams per serving, if the label gives one
+}
+
+// A food from an imported food composition database (USDA FoodData Central or UK CoFID), per 100g
+export interface ReferenceFood extends Nutrients {
+  id: number;
+  source: 'usda' | 'cofid';
+  sourceId: string;
+  name: string;
+  category?: string;
+  carbs: number;
+  fat: number;
+  protein: number;
+  kcal: number;
+  macroUnit: MacroUnit;
+  attribution: string;
 }
 
 export interface MealTemplate {
@@ -26,15 +98,48 @@ export interface MealTemplate {
   name: string;
   description?: string;
   ingredients: IngredientTemplate[];
+  totals?: MacroTotals;
+  createdAt?: string;
+  updatedAt?: string;
+}
+
+export interface LogMealTemplateOptions {
+  datetime: string;
+  name?: string;
+  scale?: number;
+  quantities?: Record<number, number>; // Keyed by ingredient template ID
+}
+
+// A batch-cooked dish: raw ingredients plus the cooked weight and servings, from which the
+// server derives per 100g and per serving macros
+export interface Recipe {
+  id?: number;
+  name: string;
+  description?: string;
+  ingredients: Ingredient[]; // Raw quantities, as weighed before cooking
+  cookedWeight: number; // Grams of the whole dish once cooked
+  servings?: number; // Defaults to 1
+  servingWeight?: number; // Computed grams per serving
+  totals?: MacroTotals; // Computed for the whole dish
+  per100g?: MacroTotals;
+  perServing?: MacroTotals;
   createdAt?: string;
   updatedAt?: string;
 }
 
+export interface LogRecipeOptions {
+  datetime: string;
+  name?: string;
+  quantity?: number; // Defaults to 1
+  quantityUnit?: QuantityUnit; // Defaults to serving, e.g. 180 g
+}
+
 export interface Meal {
   id?: number;
   name: string;
   datetime: string;
   ingredients: Ingredient[];
+  totals?: MacroTotals;
 }
 
 export interface MealFormData {
@@ -43,8 +148,24 @@ export interface MealFormData {
   ingredients: Ingredient[];
 }
 
+// How protein/carbs/fat targets are entered: absolute grams, a percentage of the kcal target,
+// or grams per kg of body weight. The server always resolves them to grams.
+export type TargetMode = 'grams' | 'percent_kcal' | 'g_per_kg';
+
+export interface RelativeTargets {
+  carbs?: { min?: number; max?: number };
+  fat?: { min?: number; max?: number };
+  protein?: { min?: number; max?: number };
+}
+
 export interface DailyTargets {
   id?: number;
+  effectiveFrom?: string; // YYYY-MM-DD; defaults to today when created
+  profileId?: number; // Set when resolved from a target profile for the day
+  profileName?: string;
+  mode?: TargetMode; // Defaults to 'grams'
+  bodyWeight?: number; // kg, used by 'g_per_kg' targets
+  relative?: RelativeTargets; // As entered in a relative mode; carbs/fat/protein hold the resolved grams
   carbs?: {
     min?: number;
     max?: number;
@@ -61,6 +182,11 @@ export interface DailyTargets {
     min?: number;
     max?: number;
   };
+  fibre?: MacroTarget;
+  sugar?: MacroTarget;
+  saturatedFat?: MacroTarget;
+  sodium?: MacroTarget; // mg
+  alcohol?: MacroTarget;
   createdAt?: string;
   updatedAt?: string;
 }
@@ -72,3 +198,198 @@ export interface MacroProgress {
   percentage: number;
   status: 'below_min' | 'above_max' | 'within_range' | 'no_target';
 }
+
+export interface User {
+  id: number;
+  email: string;
+  createdAt?: string;
+}
+
+export interface AuthResponse {
+  token: string;
+  expiresAt: string;
+  user: User;
+}
+
+export interface TargetPeriod extends DailyTargets {
+  effectiveUntil?: string; // Exclusive; absent for the targets currently in effect
+}
+
+export interface MacroTarget {
+  min?: number;
+  max?: number;
+}
+
+// The targets are the version in effect today; edits take effect from effectiveFrom (default today)
+export interface TargetProfile extends Omit<DailyTargets, 'id' | 'profileId' | 'profileName' | 'createdAt' | 'updatedAt'> {
+  id?: number;
+  name: string;
+  versions?: DailyTargets[]; // Oldest first
+  createdAt?: string;
+  updatedAt?: string;
+}
+
+// Lowercase weekday name ("monday") to target profile ID
+export type TargetSchedule = Partial<Record<'sunday' | 'monday' | 'tuesday' | 'wednesday' | 'thursday' | 'friday' | 'saturday', number>>;
+
+export interface TargetOverride {
+  date: string;
+  profileId: number;
+  profileName?: string;
+}
+
+export interface BodyMeasurement {
+  id?: number;
+  date: string; // YYYY-MM-DD, one measurement per day
+  weight?: number; // kg
+  bodyFat?: number; // percent
+  waist?: number; // cm
+  createdAt?: string;
+  updatedAt?: string;
+}
+
+export interface WeightTrendPoint {
+  date: string;
+  weight: number;
+  trend: number; // Exponential moving average of the weight
+}
+
+export interface WeightTrend {
+  alpha: number;
+  points: WeightTrendPoint[];
+  change?: number; // Trend weight change over the range
+}
+
+export interface TDEEEstimate {
+  from: string;
+  to: string;
+  days: number;
+  loggedDays: number;
+  averageIntake: number;
+  startWeight?: number;
+  endWeight?: number;
+  weeklyChange?: number; // kg per week
+  tdee?: number; // Missing when there is not enough data; see reason
+  weeklyRate?: number;
+  suggestedKcal?: number;
+  reason?: string;
+}
+
+export interface ApplyTDEEOptions {
+  weeklyRate: number; // kg per week, negative to lose weight
+  days?: number;
+  to?: string;
+  effectiveFrom?: string;
+  kcalMargin?: number;
+}
+
+export interface MacroAdherence {
+  belowMin: number;
+  withinRange: number;
+  aboveMax: number;
+}
+
+export interface ReportDay {
+  date: string;
+  totals: MacroTotals;
+  withinRange: number;
+  targetedMacros: number;
+}
+
+export interface ReportPeriod {
+  start: string; // Clamped to the report's from and to
+  end: string;
+  loggedDays: number;
+  mealCount: number;
+  totals: MacroTotals;
+  averages: MacroTotals; // Per logged day
+  adherence: {
+    carbs: MacroAdherence;
+    fat: MacroAdherence;
+    protein: MacroAdherence;
+    kcal: MacroAdherence;
+  };
+  bestDay?: ReportDay;
+  worstDay?: ReportDay;
+}
+
+export interface Report {
+  period: 'week' | 'month';
+  from: string;
+  to: string;
+  periods: ReportPeriod[];
+}
+
+export type ImportPreset = 'macro-tracker' | 'myfitnesspal' | 'cronometer';
+
+// CSV column names per field, merged over the preset's
+export interface ImportMapping {
+  datetime?: string;
+  date?: string;
+  time?: string;
+  meal?: string;
+  mealId?: string;
+  ingredient?: string;
+  quantity?: string;
+  macroUnit?: string;
+  carbs?: string;
+  fat?: string;
+  protein?: string;
+  kcal?: string;
+}
+
+export interface ImportedMeal {
+  id?: number; // Not set on a dry run
+  name: string;
+  datetime: string;
+  ingredients: number;
+  totals: MacroTotals;
+}
+
+export interface ImportRowError {
+  row: number;
+  error: string;
+}
+
+export interface ImportResult {
+  dryRun: boolean;
+  rows: number;
+  meals: ImportedMeal[];
+  ingredients: number;
+  errors?: ImportRowError[];
+}
+
+// Version 2 of the document returned by GET /api/backup. IDs only link rows within the document.
+export interface Backup {
+  version: number;
+  createdAt?: string;
+  ingredientTemplates: IngredientTemplate[];
+  mealTemplates: MealTemplate[];
+  recipes?: Recipe[]; // Added in version 2
+  meals: Meal[];
+  dailyTargets: DailyTargets[];
+  targetProfiles: TargetProfile[];
+  targetSchedule: TargetSchedule;
+  targetOverrides: TargetOverride[];
+  measurements: BodyMeasurement[];
+}
+
+export type RestoreMode = 'merge' | 'replace';
+
+export interface RestoreCounts {
+  ingredientTemplates: number;
+  mealTemplates: number;
+  recipes: number;
+  meals: number;
+  dailyTargets: number;
+  targetProfiles: number;
+  scheduleDays: number;
+  targetOverrides: number;
+  measurements: number;
+}
+
+export interface RestoreResult {
+  mode: RestoreMode;
+  created: RestoreCounts;
+  skipped: RestoreCounts; // Rows the account already had, matched by name, date or weekday
+}
@@ -0,0 +1,57 @@
+import { MacroUnit, Nutrients, QuantityUnit } from '../types';
+
+// Mirrors macroUnits and quantityUnits in units.go, which does the conversions
+export const macroUnitLabels: Record<MacroUnit, string> = {
+  per_unit: 'per unit',
+  per_100g: 'per 100g',
+  per_100ml: 'per 100ml',
+  per_oz: 'per oz',
+  per_serving: 'per serving',
+};
+
+export const quantityUnitLabels: Record<QuantityUnit, string> = {
+  unit: 'units',
+  g: 'g',
+  kg: 'kg',
+  oz: 'oz',
+  lb: 'lb',
+  ml: 'ml',
+  l: 'l',
+  tsp: 'tsp',
+  tbsp: 'tbsp',
+  fl_oz: 'fl oz',
+  cup: 'cups',
+  serving: 'servings',
+};
+
+const massUnits: QuantityUnit[] = ['g', 'kg', 'oz', 'lb'];
+const volumeUnits: QuantityUnit[] = ['ml', 'l', 'tsp', 'tbsp', 'fl_oz', 'cup'];
+
+// The quantity units the server can convert to a macro unit; servings and weights convert
+// through the serving size. The first one is the default.
+export function quantityUnitsFor(macroUnit: MacroUnit): QuantityUnit[] {
+  switch (macroUnit) {
+    case 'per_100g':
+      return [...massUnits, 'serving'];
+    case 'per_oz':
+      return ['oz', 'g', 'kg', 'lb', 'serving'];
+    case 'per_100ml':
+      return volumeUnits;
+    case 'per_serving':
+      return ['serving', ...massUnits];
+    default:
+      return ['unit'];
+  }
+}
+
+// Whether a serving size (g) is needed to convert the quantity to the macro unit
+export function needsServingSize(macroUnit: MacroUnit, quantityUnit?: QuantityUnit): boolean {
+  const servingMacros = macroUnit === 'per_serving';
+  const servingQuantity = (quantityUnit ?? quantityUnitsFor(macroUnit)[0]) === 'serving';
+  return servingMacros !== servingQuantity;
+}
+
+// Copies the nutrients besides the four macros, so edits keep them
+export function pickNutrients({ fibre, sugar, saturatedFat, sodium, alcohol }: Nutrients): Nutrients {
+  return { fibre, sugar, saturatedFat, sodium, alcohol };
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
+
+var (
+	errNotFound   = errors.New("not found")
+	errEmailTaken = errors.New("email already registered")
+	errDuplicate  = errors.New("already exists")
+	// errInvalidReference is returned when a request refers to a row the user does not own
+	errInvalidReference = errors.New("invalid reference")
+)
+
+// Store is the persistence layer used by the HTTP handlers. Every method that reads or
+// writes user data is scoped to the given user ID.
+type Store interface {
+	// Users and sessions
+	CreateUser(email, passwordHash string) (User, error)
+	GetUser(id int) (User, error)
+	GetUserByEmail(email string) (User, string, error)
+	CreateSession(userID int, tokenHash string, expiresAt time.Time) error
+	GetSessionUserID(tokenHash string, now time.Time) (int, error)
+	DeleteSession(tokenHash string) error
+
+	// Meals
+	ListMeals(userID int, q mealQuery) ([]Meal, int, error)
+	GetMeal(userID, id int) (*Meal, error)
+	CreateMeal(userID int, meal *Meal) error
+	UpdateMeal(userID, id int, meal *Meal) error
+	DeleteMeal(userID, id int) error
+	CreateMeals(userID int, meals []Meal) error
+	ForEachMealIngredient(userID int, q mealQuery, fn func(meal Meal, ingredient *Ingredient) error) error
+
+	// Ingredients
+	ListIngredients(userID int) ([]Ingredient, error)
+	CreateIngredient(userID int, ingredient *Ingredient) error
+
+	// Ingredient templates
+	ListIngredientTemplates(userID int) ([]IngredientTemplate, error)
+	CreateIngredientTemplate(userID int, template *IngredientTemplate) error
+	UpdateIngredientTemplate(userID, id int, template *IngredientTemplate) error
+	DeleteIngredientTemplate(userID, id int) error
+	ListIngredientTemplateUsage(userID, templateID int) ([]IngredientTemplateUsage, error)
+	GetIngredientTemplate(userID, id int) (*IngredientTemplate, error)
+	SearchIngredientTemplates(userID int, q templateSearchQuery) ([]IngredientTemplate, int, error)
+
+	// Ingredient template portions
+	ListIngredientPortions(userID, templateID int) ([]IngredientPortion, error)
+	CreateIngredientPortion(userID, templateID int, portion *IngredientPortion) error
+	UpdateIngredientPortion(userID, templateID, id int, portion *IngredientPortion) error
+	DeleteIngredientPortion(userID, templateID, id int) error
+
+	// Products imported from Open Food Facts
+	GetProduct(code string) (*Product, error)
+
+	// Reference foods imported from food composition databases
+	ListReferenceFoods(q referenceFoodQuery) ([]ReferenceFood, int, error)
+	GetReferenceFood(id int) (*ReferenceFood, error)
+
+	// Meal templates
+	ListMealTemplates(userID int) ([]MealTemplate, error)
+	GetMealTemplate(userID, id int) (*MealTemplate, error)
+	CreateMealTemplate(userID int, template *MealTemplate) error
+	UpdateMealTemplate(userID, id int, template *MealTemplate) error
+	DeleteMealTemplate(userID, id int) error
+	CreateMealTemplateFromMeal(userID int, meal *Meal, template *MealTemplate) error
+
+	// Recipes
+	ListRecipes(userID int) ([]Recipe, error)
+	GetRecipe(userID, id int) (*Recipe, error)
+	CreateRecipe(userID int, recipe *Recipe) error
+	UpdateRecipe(userID, id int, recipe *Recipe) error
+	DeleteRecipe(userID, id int) error
+
+	// Daily targets
+	GetDailyTargets(userID int, date string) (*DailyTargets, error)
+	ListDailyTargets(userID int) ([]DailyTargets, error)
+	CreateDailyTargets(userID int, targets *DailyTargets) error
+	UpdateDailyTargets(userID, id int, targets *DailyTargets) error
+	DeleteDailyTargets(userID, id int) error
+
+	// Target profiles, weekly schedule and per-date overrides
+	ListTargetProfiles(userID int) ([]TargetProfile, error)
+	CreateTargetProfile(userID int, profile *TargetProfile) error
+	UpdateTargetProfile(userID, id int, profile *TargetProfile) error
+	DeleteTargetProfile(userID, id int) error
+	GetTargetSchedule(userID int) (TargetSchedule, error)
+	SetTargetSchedule(userID int, days map[time.Weekday]int) error
+	ListTargetOverrides(userID int, from, to string) ([]TargetOverride, error)
+	SetTargetOverride(userID int, override *TargetOverride) error
+	DeleteTargetOverride(userID int, date string) error
+	LoadTargetTimeline(userID int, from, to string) (*TargetTimeline, error)
+
+	// Body measurements
+	ListMeasurements(userID int, from, to string) ([]BodyMeasurement, error)
+	LatestWeight(userID int, date string) (*float64, error)
+	GetMeasurement(userID, id int) (*BodyMeasurement, error)
+	CreateMeasurement(userID int, measurement *BodyMeasurement) error
+	UpdateMeasurement(userID, id int, measurement *BodyMeasurement) error
+	DeleteMeasurement(userID, id int) error
+
+	// Insights
+	ListDailyIntake(userID int, from, to string) ([]DailyIntake, error)
+	ListPeriodIntake(userID int, period, from, to string) ([]PeriodIntake, error)
+
+	// Backup
+	Restore(userID int, backup *Backup, replace bool) (RestoreResult, error)
+}
+
+// dialect captures what differs between the SQL databases sqlStore supports
+type dialect struct {
+	name   string // also the migrations/ subdirectory
+	driver string
+	// dateExpr is a fmt format that truncates a timestamp expression to its date
+	dateExpr string
+	// periodExpr holds fmt formats that truncate a timestamp expression to the first day of
+	// its "week" (starting on Monday) or "month"
+	periodExpr map[string]string
+	// foreignKeysOff and foreignKeysOn bracket migrations that rebuild tables, and
+	// foreignKeyCheck lists the references a migration broke; empty where not needed
+	foreignKeysOff, foreignKeysOn, foreignKeyCheck string
+	// wordSimilarityExpr is a fmt format scoring a search word (%[1]s) against the best matching
+	// part of a text (%[2]s) by trigrams; empty where unsupported or pg_trgm is not installed
+	wordSimilarityExpr string
+}
+
+var (
+	postgresDialect = dialect{
+		name:     "postgres",
+		driver:   "postgres",
+		dateExpr: "CAST(%s AS DATE)",
+		periodExpr: map[string]string{
+			"week":  "CAST(DATE_TRUNC('week', CAST(%s AS TIMESTAMP)) AS DATE)",
+			"month": "CAST(DATE_TRUNC('month', CAST(%s AS TIMESTAMP)) AS DATE)",
+		},
+		wordSimilarityExpr: "word_similarity(%[1]s, %[2]s)",
+	}
+	sqliteDialect = dialect{
+		name:     "sqlite",
+		driver:   "sqlite",
+		dateExpr: "DATE(%s)",
+		periodExpr: map[string]string{
+			"week":  "DATE(%s, 'weekday 0', '-6 days')",
+			"month": "DATE(%s, 'start of month')",
+		},
+		foreignKeysOff:  "PRAGMA foreign_keys = OFF",
+		foreignKeysOn:   "PRAGMA foreign_keys = ON",
+		foreignKeyCheck: "PRAGMA foreign_key_check",
+	}
+)
+
+// queryer is implemented by both *sql.DB and *sql.Tx, so statements can run on their own or
+// as part of a transaction
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// sqlStore implements Store on top of database/sql, for both Postgres and SQLite
+type sqlStore struct {
+	db      *sql.DB
+	dialect dialect
+}
+
+// openStore connects to the database named by a DATABASE_URL.
+// postgres:// and postgresql:// URLs use Postgres; sqlite://path (or sqlite://:memory:)
+// uses an embedded SQLite database that needs no database service.
+func openStore(databaseURL string) (*sqlStore, error) {
+	if path, ok := strings.CutPrefix(databaseURL, "sqlite://"); ok {
+		return openSQLiteStore(path)
+	}
+	if !strings.HasPrefix(databaseURL, "postgres://") && !strings.HasPrefix(databaseURL, "postgresql://") {
+		return nil, fmt.Errorf("unsupported DATABASE_URL %q, expected postgres:// or sqlite://", databaseURL)
+	}
+
+	db, err := sql.Open(postgresDialect.driver, databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &sqlStore{db: db, dialect: postgresDialect}, nil
+}
+
+func openSQLiteStore(path string) (*sqlStore, error) {
+	db, err := sql.Open(sqliteDialect.driver, path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer; one connection also keeps :memory: databases from
+	// being created per connection.
+	db.SetMaxOpenConns(1)
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &sqlStore{db: db, dialect: sqliteDialect}, nil
+}
+
+// detectWordSimilarity turns trigram matching off if pg_trgm is not installed, which happens
+// when migrations run as a role that may not create extensions
+func (s *sqlStore) detectWordSimilarity() error {
+	if s.dialect.wordSimilarityExpr == "" {
+		return nil
+	}
+	var installed bool
+	err := s.db.QueryRow("SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'pg_trgm')").Scan(&installed)
+	if err != nil {
+		return err
+	}
+	if !installed {
+		log.Print("pg_trgm is not installed, so ingredient template search will not match misspelt names")
+		s.dialect.wordSimilarityExpr = ""
+	}
+	return nil
+}
+
+func (s *sqlStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Progress statuses, matching MacroProgress['status'] in the frontend
+const (
+	statusBelowMin    = "below_min"
+	statusWithinRange = "within_range"
+	statusAboveMax    = "above_max"
+	statusNoTarget    = "no_target"
+)
+
+const (
+	dateLayout      = "2006-01-02"
+	timestampLayout = "2006-01-02 15:04:05"
+)
+
+type MacroProgress struct {
+	Current    float64  `json:"current"`
+	Min        *float64 `json:"min,omitempty"`
+	Max        *float64 `json:"max,omitempty"`
+	Percentage float64  `json:"percentage"`
+	Status     string   `json:"status"`
+}
+
+type DailyProgress struct {
+	Carbs   MacroProgress `json:"carbs"`
+	Fat     MacroProgress `json:"fat"`
+	Protein MacroProgress `json:"protein"`
+	Kcal    MacroProgress `json:"kcal"`
+
+	Fibre        MacroProgress `json:"fibre"`
+	Sugar        MacroProgress `json:"sugar"`
+	SaturatedFat MacroProgress `json:"saturatedFat"`
+	Sodium       MacroProgress `json:"sodium"`
+	Alcohol      MacroProgress `json:"alcohol"`
+}
+
+type DailySummary struct {
+	Date      string        `json:"date"`
+	MealCount int           `json:"mealCount"`
+	Totals    MacroTotals   `json:"totals"`
+	Targets   *DailyTargets `json:"targets,omitempty"`
+	Progress  DailyProgress `json:"progress"`
+}
+
+// calculateMacroProgress evaluates a macro total against its target.
+// This mirrors calculateMacroProgress in src/utils/macroProgress.ts. A zero
+// bound counts as fully met rather than dividing by zero.
+func calculateMacroProgress(current float64, target *MacroTarget) MacroProgress {
+	progress := MacroProgress{Current: current, Status: statusNoTarget}
+	if target == nil {
+		return progress
+	}
+	progress.Min = target.Min
+	progress.Max = target.Max
+
+	switch {
+	case target.Min != nil && target.Max != nil:
+		min, max := *target.Min, *target.Max
+		if current < min {
+			progress.Percentage = clamp(current/min*100, 0, 100)
+			progress.Status = statusBelowMin
+		} else if current > max {
+			progress.Percentage = 150
+			if max > 0 {
+				progress.Percentage = 100 + minFloat(50, (current-max)/max*100)
+			}
+			progress.Status = statusAboveMax
+		} else {
+			progress.Percentage = 100
+			if max > min {
+				progress.Percentage = clamp((current-min)/(max-min)*100, 0, 100)
+			}
+			progress.Status = statusWithinRange
+		}
+	case target.Min != nil:
+		min := *target.Min
+		if current < min {
+			progress.Percentage = clamp(current/min*100, 0, 100)
+			progress.Status = statusBelowMin
+		} else {
+			progress.Percentage = 100
+			if min > 0 {
+				progress.Percentage = minFloat(120, 100+(current-min)/min*20)
+			}
+			progress.Status = statusWithinRange
+		}
+	case target.Max != nil:
+		max := *target.Max
+		if current > max {
+			progress.Percentage = 150
+			if max > 0 {
+				progress.Percentage = 100 + minFloat(50, (current-max)/max*100)
+			}
+			progress.Status = statusAboveMax
+		} else {
+			progress.Percentage = 100
+			if max > 0 {
+				progress.Percentage = clamp(current/max*100, 0, 100)
+			}
+			progress.Status = statusWithinRange
+		}
+	}
+
+	return progress
+}
+
+func clamp(v, lo, hi float64) float64 {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func getDailySummary(c *gin.Context) {
+	day := time.Now()
+	if dateParam := c.Query("date"); dateParam != "" {
+		var err error
+		day, err = time.Parse(dateLayout, dateParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date, expected YYYY-MM-DD"})
+			return
+		}
+	}
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 1)
+
+	q := mealQuery{From: start.Format(timestampLayout), To: end.Format(timestampLayout), SortBy: mealSortColumns["datetime"]}
+	meals, _, err := store.ListMeals(currentUserID(c), q)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	summary := DailySummary{Date: start.Format(dateLayout), MealCount: len(meals)}
+	for _, meal := range meals {
+		for _, ingredient := range meal.Ingredients {
+			summary.Totals.add(ingredient.macros())
+		}
+	}
+	summary.Totals = summary.Totals.rounded()
+
+	targets, err := store.GetDailyTargets(currentUserID(c), summary.Date)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	summary.Targets = targets
+
+	var carbsTarget, fatTarget, proteinTarget, kcalTarget *MacroTarget
+	var nutrientTargets NutrientTargets
+	if targets != nil {
+		carbsTarget, fatTarget, proteinTarget, kcalTarget = targets.Carbs, targets.Fat, targets.Protein, targets.Kcal
+		nutrientTargets = targets.NutrientTargets
+	}
+	summary.Progress = DailyProgress{
+		Carbs:   calculateMacroProgress(summary.Totals.Carbs, carbsTarget),
+		Fat:     calculateMacroProgress(summary.Totals.Fat, fatTarget),
+		Protein: calculateMacroProgress(summary.Totals.Protein, proteinTarget),
+		Kcal:    calculateMacroProgress(summary.Totals.Kcal, kcalTarget),
+
+		Fibre:        calculateMacroProgress(summary.Totals.Fibre, nutrientTargets.Fibre),
+		Sugar:        calculateMacroProgress(summary.Totals.Sugar, nutrientTargets.Sugar),
+		SaturatedFat: calculateMacroProgress(summary.Totals.SaturatedFat, nutrientTargets.SaturatedFat),
+		Sodium:       calculateMacroProgress(summary.Totals.Sodium, nutrientTargets.Sodium),
+		Alcohol:      calculateMacroProgress(summary.Totals.Alcohol, nutrientTargets.Alcohol),
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Daily target modes. In the relative modes, protein/carbs/fat can be given in Relative as a
+// percentage of the kcal target or as grams per kg of body weight; the server resolves them to
+// grams so everything else (summaries, progress) keeps working with absolute min/max values.
+const (
+	targetModeGrams       = "grams"
+	targetModePercentKcal = "percent_kcal"
+	targetModeGramsPerKg  = "g_per_kg"
+)
+
+// kcal per gram of each macro, used to turn a share of the kcal target into grams
+const (
+	kcalPerGramCarbs   = 4
+	kcalPerGramFat     = 9
+	kcalPerGramProtein = 4
+)
+
+// RelativeTargets holds protein/carbs/fat targets as entered in a relative mode: percentages of
+// kcal for percent_kcal, or grams per kg of body weight for g_per_kg
+type RelativeTargets struct {
+	Carbs   *MacroTarget `json:"carbs,omitempty"`
+	Fat     *MacroTarget `json:"fat,omitempty"`
+	Protein *MacroTarget `json:"protein,omitempty"`
+}
+
+// resolveTargetMode validates the mode of submitted daily targets and fills in the gram targets
+// for every macro given in Relative. Macros without a relative target keep their absolute values.
+func resolveTargetMode(targets *DailyTargets) error {
+	switch targets.Mode {
+	case "", targetModeGrams:
+		targets.Mode = targetModeGrams
+		targets.Relative = nil
+		targets.BodyWeight = nil
+		return nil
+	case targetModePercentKcal:
+		if targets.Kcal == nil || (targets.Kcal.Min == nil && targets.Kcal.Max == nil) {
+			return fmt.Errorf("%s targets need a kcal target", targetModePercentKcal)
+		}
+		targets.BodyWeight = nil
+	case targetModeGramsPerKg:
+		if targets.BodyWeight == nil || *targets.BodyWeight <= 0 {
+			return fmt.Errorf("%s targets need a bodyWeight (kg) greater than 0 or a logged weight", targetModeGramsPerKg)
+		}
+	default:
+		return fmt.Errorf("invalid mode %q, expected %s, %s or %s", targets.Mode, targetModeGrams, targetModePercentKcal, targetModeGramsPerKg)
+	}
+
+	if targets.Relative == nil {
+		return fmt.Errorf("%s targets need relative protein, carbs or fat targets", targets.Mode)
+	}
+	var err error
+	if targets.Carbs, err = resolveRelativeTarget(targets, "carbs", targets.Relative.Carbs, targets.Carbs, kcalPerGramCarbs); err != nil {
+		return err
+	}
+	if targets.Fat, err = resolveRelativeTarget(targets, "fat", targets.Relative.Fat, targets.Fat, kcalPerGramFat); err != nil {
+		return err
+	}
+	if targets.Protein, err = resolveRelativeTarget(targets, "protein", targets.Relative.Protein, targets.Protein, kcalPerGramProtein); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Helper function to resolve one relative macro target to grams. A percentage bound applies to
+// the matching kcal bound, or to the only kcal bound set.
+func resolveRelativeTarget(targets *DailyTargets, macro string, relative, absolute *MacroTarget, kcalPerGram float64) (*MacroTarget, error) {
+	if relative == nil {
+		return absolute, nil
+	}
+
+	resolve := func(value *float64, kcalBound *float64) (*float64, error) {
+		if value == nil {
+			return nil, nil
+		}
+		if *value < 0 {
+			return nil, fmt.Errorf("relative %s target must not be negative", macro)
+		}
+		var grams float64
+		if targets.Mode == targetModePercentKcal {
+			if *value > 100 {
+				return nil, fmt.Errorf("relative %s target must be a percentage between 0 and 100", macro)
+			}
+			grams = *value / 100 * *kcalBound / kcalPerGram
+		} else {
+			grams = *value * *targets.BodyWeight
+		}
+		grams = round2(grams)
+		return &grams, nil
+	}
+
+	var kcalMin, kcalMax *float64
+	if targets.Kcal != nil {
+		kcalMin, kcalMax = targets.Kcal.Min, targets.Kcal.Max
+		if kcalMin == nil {
+			kcalMin = kcalMax
+		}
+		if kcalMax == nil {
+			kcalMax = kcalMin
+		}
+	}
+
+	min, err := resolve(relative.Min, kcalMin)
+	if err != nil {
+		return nil, err
+	}
+	max, err := resolve(relative.Max, kcalMax)
+	if err != nil {
+		return nil, err
+	}
+	if min == nil && max == nil {
+		return absolute, nil
+	}
+	return &MacroTarget{Min: min, Max: max}, nil
+}
+
+// Helper function to build RelativeTargets from the nullable relative columns, or nil if none are set
+func newRelativeTargets(carbsMin, carbsMax, fatMin, fatMax, proteinMin, proteinMax sql.NullFloat64) *RelativeTargets {
+	relative := &RelativeTargets{
+		Carbs:   newMacroTarget(carbsMin, carbsMax),
+		Fat:     newMacroTarget(fatMin, fatMax),
+		Protein: newMacroTarget(proteinMin, proteinMax),
+	}
+	if relative.Carbs == nil && relative.Fat == nil && relative.Protein == nil {
+		return nil
+	}
+	return relative
+}
+
+// Helper function to safely get a relative target value or nil
+func getRelativeTargetFloat(relative *RelativeTargets, macro, field string) interface{} {
+	if relative == nil {
+		return nil
+	}
+	switch macro {
+	case "carbs":
+		return getMacroTargetFloat(relative.Carbs, field)
+	case "fat":
+		return getMacroTargetFloat(relative.Fat, field)
+	case "protein":
+		return getMacroTargetFloat(relative.Protein, field)
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,611 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TargetProfile is a named set of targets, e.g. "training" or "rest", that the weekly schedule
+// and per-date overrides switch between. Edits are versioned by effective date so that past days
+// keep the targets they had: DailyTargets holds the version in effect today (or, on create and
+// update, the version submitted, effective from effectiveFrom or today), Versions all of them.
+type TargetProfile struct {
+	ID   int    `json:"id,omitempty"`
+	Name string `json:"name" binding:"required"`
+	DailyTargets
+	Versions  []DailyTargets `json:"versions,omitempty"` // Oldest first
+	CreatedAt string         `json:"createdAt,omitempty"`
+	UpdatedAt string         `json:"updatedAt,omitempty"`
+}
+
+// versionOn returns the profile's targets in effect on a date: the latest version effective on or
+// before it. It reports false for dates before the profile's first version, which keep the dated
+// targets they had.
+func (p TargetProfile) versionOn(date string) (DailyTargets, bool) {
+	var version DailyTargets
+	found := false
+	for _, v := range p.Versions {
+		if v.EffectiveFrom > date {
+			break
+		}
+		version, found = v, true
+	}
+	return version, found
+}
+
+// TargetTimeline holds everything that decides a user's targets over a range of dates, so that
+// targets for many dates can be resolved without a query per date
+type TargetTimeline struct {
+	dated     []DailyTargets // Ordered by effective_from, id
+	profiles  map[int]TargetProfile
+	schedule  map[time.Weekday]int
+	overrides map[string]int
+}
+
+// On returns the targets in effect on a date (YYYY-MM-DD), or nil if none apply. A target
+// profile chosen for the date by an override or the weekly schedule wins from its first version
+// on; otherwise it is the dated targets with the latest effective_from on or before the date,
+// newest first on ties.
+func (t *TargetTimeline) On(date string) *DailyTargets {
+	profileID, ok := t.overrides[date]
+	if !ok {
+		if day, err := time.Parse(dateLayout, date); err == nil {
+			profileID, ok = t.schedule[day.Weekday()]
+		}
+	}
+	if profile, found := t.profiles[profileID]; ok && found {
+		if targets, applies := profile.versionOn(date); applies {
+			targets.ID = 0
+			targets.ProfileID = &profile.ID
+			targets.ProfileName = profile.Name
+			return &targets
+		}
+	}
+
+	var current *DailyTargets
+	for i := range t.dated {
+		if t.dated[i].EffectiveFrom > date {
+			break
+		}
+		targets := t.dated[i]
+		current = &targets
+	}
+	return current
+}
+
+// TargetSchedule maps lowercase weekday names ("monday") to the profile used on that day.
+// Weekdays without a profile use the dated daily targets.
+type TargetSchedule map[string]int
+
+// TargetOverride uses a profile on one date regardless of the weekly schedule
+type TargetOverride struct {
+	Date        string `json:"date"`
+	ProfileID   int    `json:"profileId" binding:"required"`
+	ProfileName string `json:"profileName,omitempty"`
+}
+
+// Helper function to look up a weekday by its lowercase English name
+func parseWeekday(name string) (time.Weekday, bool) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(day.String(), name) {
+			return day, true
+		}
+	}
+	return 0, false
+}
+
+// Helper function to validate a :date path parameter
+func parseDateParam(c *gin.Context) (string, bool) {
+	date := c.Param("date")
+	if _, err := time.Parse(dateLayout, date); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date, expected YYYY-MM-DD"})
+		return "", false
+	}
+	return date, true
+}
+
+// Helper function to validate the optional ?from=YYYY-MM-DD&to=YYYY-MM-DD query parameters
+func parseDateRange(c *gin.Context) (string, string, bool) {
+	from, to := c.Query("from"), c.Query("to")
+	for _, date := range []string{from, to} {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse(dateLayout, date); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date, expected YYYY-MM-DD"})
+			return "", "", false
+		}
+	}
+	return from, to, true
+}
+
+// Target profile handlers
+func getTargetProfiles(c *gin.Context) {
+	profiles, err := store.ListTargetProfiles(currentUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, profiles)
+}
+
+// Helper function to bind a target profile from the request body and validate its targets
+// like daily targets, defaulting effectiveFrom to today
+func bindTargetProfile(c *gin.Context) (TargetProfile, bool) {
+	var profile TargetProfile
+	if err := c.ShouldBindJSON(&profile); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return profile, false
+	}
+	if profile.EffectiveFrom == "" {
+		profile.EffectiveFrom = time.Now().Format(dateLayout)
+	}
+	profile.Versions = nil
+	return profile, validateDailyTargets(c, &profile.DailyTargets)
+}
+
+func createTargetProfile(c *gin.Context) {
+	profile, ok := bindTargetProfile(c)
+	if !ok {
+		return
+	}
+
+	if err := store.CreateTargetProfile(currentUserID(c), &profile); err != nil {
+		respondStoreError(c, err, "Target profile not found")
+		return
+	}
+
+	c.JSON(http.StatusCreated, profile)
+}
+
+// updateTargetProfile renames a profile and sets its targets from effectiveFrom on, replacing the
+// version with the same effective date if there is one
+func updateTargetProfile(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+	profile, ok := bindTargetProfile(c)
+	if !ok {
+		return
+	}
+
+	if err := store.UpdateTargetProfile(currentUserID(c), id, &profile); err != nil {
+		respondStoreError(c, err, "Target profile not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// deleteTargetProfile deletes a profile along with the schedule days and overrides that use it
+func deleteTargetProfile(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := store.DeleteTargetProfile(currentUserID(c), id); err != nil {
+		respondStoreError(c, err, "Target profile not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Target profile deleted successfully"})
+}
+
+// Target schedule handlers
+func getTargetSchedule(c *gin.Context) {
+	schedule, err := store.GetTargetSchedule(currentUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, schedule)
+}
+
+// updateTargetSchedule replaces the whole weekly schedule
+func updateTargetSchedule(c *gin.Context) {
+	var schedule TargetSchedule
+	if err := c.ShouldBindJSON(&schedule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	days := make(map[time.Weekday]int, len(schedule))
+	for name, profileID := range schedule {
+		day, ok := parseWeekday(name)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid weekday %q", name)})
+			return
+		}
+		days[day] = profileID
+	}
+
+	if err := store.SetTargetSchedule(currentUserID(c), days); err != nil {
+		respondStoreError(c, err, "Target profile not found")
+		return
+	}
+
+	getTargetSchedule(c)
+}
+
+// Target override handlers
+
+// getTargetOverrides lists overrides, optionally limited to ?from=YYYY-MM-DD&to=YYYY-MM-DD (inclusive)
+func getTargetOverrides(c *gin.Context) {
+	from, to, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+
+	overrides, err := store.ListTargetOverrides(currentUserID(c), from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, overrides)
+}
+
+// setTargetOverride creates or replaces the override for a date
+func setTargetOverride(c *gin.Context) {
+	date, ok := parseDateParam(c)
+	if !ok {
+		return
+	}
+	var override TargetOverride
+	if err := c.ShouldBindJSON(&override); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	override.Date = date
+
+	if err := store.SetTargetOverride(currentUserID(c), &override); err != nil {
+		respondStoreError(c, err, "Target profile not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, override)
+}
+
+func deleteTargetOverride(c *gin.Context) {
+	date, ok := parseDateParam(c)
+	if !ok {
+		return
+	}
+
+	if err := store.DeleteTargetOverride(currentUserID(c), date); err != nil {
+		respondStoreError(c, err, "Target override not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Target override deleted successfully"})
+}
+
+// Target profile storage
+
+// ListTargetProfiles returns the user's profiles by name, with their versions
+func (s *sqlStore) ListTargetProfiles(userID int) ([]TargetProfile, error) {
+	rows, err := s.db.Query("SELECT id, name, created_at, updated_at FROM target_profiles WHERE user_id = $1 ORDER BY name", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	profiles := []TargetProfile{}
+	index := map[int]int{}
+	for rows.Next() {
+		var profile TargetProfile
+		var createdAt, updatedAt sql.NullString
+		if err := rows.Scan(&profile.ID, &profile.Name, &createdAt, &updatedAt); err != nil {
+			return nil, err
+		}
+		profile.CreatedAt = createdAt.String
+		profile.UpdatedAt = updatedAt.String
+		index[profile.ID] = len(profiles)
+		profiles = append(profiles, profile)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	versions, err := s.db.Query(`
+		SELECT profile_id, `+dailyTargetsColumns+` FROM target_profile_versions
+		WHERE profile_id IN (SELECT id FROM target_profiles WHERE user_id = $1)
+		ORDER BY effective_from
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer versions.Close()
+
+	for versions.Next() {
+		var profileID int
+		version, err := scanDailyTargets(prefixScanner{versions, []interface{}{&profileID}})
+		if err != nil {
+			return nil, err
+		}
+		profile := &profiles[index[profileID]]
+		profile.Versions = append(profile.Versions, version)
+	}
+
+	// Profiles whose first version starts later show that version
+	today := time.Now().Format(dateLayout)
+	for i := range profiles {
+		var applies bool
+		if profiles[i].DailyTargets, applies = profiles[i].versionOn(today); !applies && len(profiles[i].Versions) > 0 {
+			profiles[i].DailyTargets = profiles[i].Versions[0]
+		}
+	}
+	return profiles, versions.Err()
+}
+
+// prefixScanner scans leading columns into prefix before handing the rest to another scan
+// function, e.g. profile_id ahead of the daily targets columns
+type prefixScanner struct {
+	row    rowScanner
+	prefix []interface{}
+}
+
+func (p prefixScanner) Scan(dest ...interface{}) error {
+	return p.row.Scan(append(p.prefix, dest...)...)
+}
+
+// checkTargetProfileName returns errDuplicate if another of the user's profiles has the name
+func checkTargetProfileName(tx *sql.Tx, userID, id int, name string) error {
+	var exists bool
+	err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM target_profiles WHERE user_id = $1 AND name = $2 AND id <> $3)", userID, name, id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("%w: target profile %q", errDuplicate, name)
+	}
+	return nil
+}
+
+func (s *sqlStore) CreateTargetProfile(userID int, profile *TargetProfile) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = checkTargetProfileName(tx, userID, 0, profile.Name); err != nil {
+		return err
+	}
+	if err = insertTargetProfile(tx, userID, profile); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// insertTargetProfile inserts a profile with its versions, or with DailyTargets as its only
+// version if it has none
+func insertTargetProfile(q queryer, userID int, profile *TargetProfile) error {
+	err := q.QueryRow("INSERT INTO target_profiles (user_id, name) VALUES ($1, $2) RETURNING id", userID, profile.Name).Scan(&profile.ID)
+	if err != nil {
+		return err
+	}
+
+	if len(profile.Versions) == 0 {
+		return setTargetProfileVersion(q, profile.ID, &profile.DailyTargets)
+	}
+	for i := range profile.Versions {
+		if err := setTargetProfileVersion(q, profile.ID, &profile.Versions[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setTargetProfileVersion inserts a profile version, replacing the one with the same effective date
+func setTargetProfileVersion(q queryer, profileID int, targets *DailyTargets) error {
+	if targets.EffectiveFrom == "" {
+		targets.EffectiveFrom = time.Now().Format(dateLayout)
+	}
+	columns := strings.Split(targetValueColumns, ", ")
+	updates := make([]string, len(columns))
+	for i, column := range columns {
+		updates[i] = column + " = excluded." + column
+	}
+
+	values := targetValues(targets)
+	return q.QueryRow(`
+		INSERT INTO target_profile_versions (profile_id, effective_from, `+targetValueColumns+`)
+		VALUES ($1, $2, `+placeholders(3, len(values))+`)
+		ON CONFLICT (profile_id, effective_from) DO UPDATE SET `+strings.Join(updates, ", ")+`, updated_at = CURRENT_TIMESTAMP
+		RETURNING id
+	`, append([]interface{}{profileID, targets.EffectiveFrom}, values...)...).Scan(&targets.ID)
+}
+
+// UpdateTargetProfile renames a profile and sets the version effective from profile.EffectiveFrom
+func (s *sqlStore) UpdateTargetProfile(userID, id int, profile *TargetProfile) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = checkTargetProfileName(tx, userID, id, profile.Name); err != nil {
+		return err
+	}
+	result, err := tx.Exec(`
+		UPDATE target_profiles SET name = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2 AND user_id = $3
+	`, profile.Name, id, userID)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return errNotFound
+	}
+	if err = setTargetProfileVersion(tx, id, &profile.DailyTargets); err != nil {
+		return err
+	}
+	profile.ID = id
+	return tx.Commit()
+}
+
+func (s *sqlStore) DeleteTargetProfile(userID, id int) error {
+	return s.deleteOwned("target_profiles", userID, id)
+}
+
+func (s *sqlStore) GetTargetSchedule(userID int) (TargetSchedule, error) {
+	rows, err := s.db.Query("SELECT weekday, profile_id FROM target_schedule WHERE user_id = $1", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedule := TargetSchedule{}
+	for rows.Next() {
+		var weekday, profileID int
+		if err := rows.Scan(&weekday, &profileID); err != nil {
+			return nil, err
+		}
+		schedule[strings.ToLower(time.Weekday(weekday).String())] = profileID
+	}
+	return schedule, rows.Err()
+}
+
+// SetTargetSchedule replaces the user's weekly schedule
+func (s *sqlStore) SetTargetSchedule(userID int, days map[time.Weekday]int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec("DELETE FROM target_schedule WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+	for day, profileID := range days {
+		result, err := tx.Exec(`
+			INSERT INTO target_schedule (user_id, weekday, profile_id)
+			SELECT $1, $2, id FROM target_profiles WHERE id = $3 AND user_id = $1
+		`, userID, int(day), profileID)
+		if err != nil {
+			return err
+		}
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			return fmt.Errorf("%w: target profile %d not found", errInvalidReference, profileID)
+		}
+	}
+	return tx.Commit()
+}
+
+// ListTargetOverrides returns the user's overrides by date, optionally between from and to (inclusive)
+func (s *sqlStore) ListTargetOverrides(userID int, from, to string) ([]TargetOverride, error) {
+	conditions := []string{"o.user_id = $1"}
+	args := []interface{}{userID}
+	if from != "" {
+		args = append(args, from)
+		conditions = append(conditions, fmt.Sprintf("o.date >= $%d", len(args)))
+	}
+	if to != "" {
+		args = append(args, to)
+		conditions = append(conditions, fmt.Sprintf("o.date <= $%d", len(args)))
+	}
+
+	rows, err := s.db.Query(`
+		SELECT o.date, p.id, p.name
+		FROM target_overrides o
+		JOIN target_profiles p ON p.id = o.profile_id
+		WHERE `+strings.Join(conditions, " AND ")+`
+		ORDER BY o.date
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	overrides := []TargetOverride{}
+	for rows.Next() {
+		var override TargetOverride
+		if err := rows.Scan(&override.Date, &override.ProfileID, &override.ProfileName); err != nil {
+			return nil, err
+		}
+		override.Date = dateString(override.Date)
+		overrides = append(overrides, override)
+	}
+	return overrides, rows.Err()
+}
+
+func (s *sqlStore) SetTargetOverride(userID int, override *TargetOverride) error {
+	var name string
+	err := s.db.QueryRow("SELECT name FROM target_profiles WHERE id = $1 AND user_id = $2", override.ProfileID, userID).Scan(&name)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%w: target profile %d not found", errInvalidReference, override.ProfileID)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`
+		INSERT INTO target_overrides (user_id, date, profile_id) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, date) DO UPDATE SET profile_id = excluded.profile_id
+	`, userID, override.Date, override.ProfileID)
+	if err != nil {
+		return err
+	}
+	override.ProfileName = name
+	return nil
+}
+
+func (s *sqlStore) DeleteTargetOverride(userID int, date string) error {
+	result, err := s.db.Exec("DELETE FROM target_overrides WHERE user_id = $1 AND date = $2", userID, date)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return errNotFound
+	}
+	return nil
+}
+
+// LoadTargetTimeline loads the user's dated targets, profiles, weekly schedule and the overrides
+// between from and to (inclusive, either may be empty)
+func (s *sqlStore) LoadTargetTimeline(userID int, from, to string) (*TargetTimeline, error) {
+	dated, err := s.ListDailyTargets(userID)
+	if err != nil {
+		return nil, err
+	}
+	profiles, err := s.ListTargetProfiles(userID)
+	if err != nil {
+		return nil, err
+	}
+	schedule, err := s.GetTargetSchedule(userID)
+	if err != nil {
+		return nil, err
+	}
+	overrides, err := s.ListTargetOverrides(userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	timeline := &TargetTimeline{
+		dated:     dated,
+		profiles:  make(map[int]TargetProfile, len(profiles)),
+		schedule:  make(map[time.Weekday]int, len(schedule)),
+		overrides: make(map[string]int, len(overrides)),
+	}
+	for _, profile := range profiles {
+		timeline.profiles[profile.ID] = profile
+	}
+	for name, profileID := range schedule {
+		day, _ := parseWeekday(name)
+		timeline.schedule[day] = profileID
+	}
+	for _, override := range overrides {
+		timeline.overrides[override.Date] = override.ProfileID
+	}
+	return timeline, nil
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Dimensions of quantity units. Quantities only convert within a dimension, except that a
+// serving size (in grams) converts between servings and weights. Volumes and weights never
+// convert, as that would take the food's density: ml, cups etc. need per_100ml macros.
+const (
+	dimensionCount   = "count"
+	dimensionMass    = "mass"
+	dimensionVolume  = "volume"
+	dimensionServing = "serving"
+)
+
+// quantityUnit is a unit an ingredient quantity can be entered in, with its size in the base
+// unit of its dimension (g, ml, serving or unit)
+type quantityUnit struct {
+	dimension string
+	size      float64
+}
+
+// quantityUnits are the units an ingredient quantity can be entered in. Volumes are US customary.
+var quantityUnits = map[string]quantityUnit{
+	"unit":    {dimensionCount, 1},
+	"g":       {dimensionMass, 1},
+	"kg":      {dimensionMass, 1000},
+	"oz":      {dimensionMass, 28.349523125},
+	"lb":      {dimensionMass, 453.59237},
+	"ml":      {dimensionVolume, 1},
+	"l":       {dimensionVolume, 1000},
+	"tsp":     {dimensionVolume, 4.92892159375},
+	"tbsp":    {dimensionVolume, 14.78676478125},
+	"fl_oz":   {dimensionVolume, 29.5735295625},
+	"cup":     {dimensionVolume, 236.5882365},
+	"serving": {dimensionServing, 1},
+}
+
+// macroUnit is what an ingredient's macros are given per: an amount of a quantity unit, which is
+// also the unit its quantity is in unless another one is given
+type macroUnit struct {
+	unit   string
+	amount float64
+}
+
+// macroUnits are the values the macro_unit columns accept
+var macroUnits = map[string]macroUnit{
+	"per_unit":    {"unit", 1},
+	"per_100g":    {"g", 100},
+	"per_100ml":   {"ml", 100},
+	"per_oz":      {"oz", 1},
+	"per_serving": {"serving", 1},
+}
+
+// validMacroUnit reports whether a macro unit is one the macro_unit columns accept
+func validMacroUnit(unit string) bool {
+	_, ok := macroUnits[unit]
+	return ok
+}
+
+// Helper function to list the keys of macroUnits or quantityUnits for error messages and SQL,
+// in a stable order
+func unitNames[T any](units map[string]T) []string {
+	names := make([]string, 0, len(units))
+	for name := range units {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Helper function to return the quantity unit a macro unit's quantities are in by default
+func defaultQuantityUnit(macro string) string {
+	return macroUnits[macro].unit
+}
+
+// unitConversion returns how many of the macro unit's amounts one of the quantity unit is, as
+// factor × servingSize^servingPower. servingPower is 1 when servings are eaten of macros given
+// per weight, -1 when a weight is eaten of macros given per serving, and 0 otherwise.
+func unitConversion(quantity, macro string) (factor float64, servingPower int, err error) {
+	from, ok := quantityUnits[quantity]
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid quantity unit %q, expected one of %s", quantity, strings.Join(unitNames(quantityUnits), ", "))
+	}
+	basis, ok := macroUnits[macro]
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid macro unit %q, expected one of %s", macro, strings.Join(unitNames(macroUnits), ", "))
+	}
+	to := quantityUnits[basis.unit]
+	basisSize := to.size * basis.amount
+
+	switch {
+	case from.dimension == to.dimension:
+		return from.size / basisSize, 0, nil
+	case from.dimension == dimensionServing && to.dimension == dimensionMass:
+		return 1 / basisSize, 1, nil
+	case from.dimension == dimensionMass && to.dimension == dimensionServing:
+		return from.size, -1, nil
+	case from.dimension == dimensionVolume && to.dimension == dimensionMass, from.dimension == dimensionMass && to.dimension == dimensionVolume:
+		return 0, 0, fmt.Errorf("cannot convert %s to %s macros without the food's density; enter the quantity as a %s instead", quantity, macro, to.dimension)
+	}
+	return 0, 0, fmt.Errorf("cannot convert %s to %s macros", quantity, macro)
+}
+
+// quantityMultiplier returns what macros given per macroUnit are multiplied by for a quantity in
+// quantityUnit (the macro unit's own unit if empty). servingSize is in grams.
+func quantityMultiplier(quantity float64, quantityUnit, macroUnit string, servingSize float64) (float64, error) {
+	if quantityUnit == "" {
+		quantityUnit = defaultQuantityUnit(macroUnit)
+	}
+	factor, servingPower, err := unitConversion(quantityUnit, macroUnit)
+	if err != nil {
+		return 0, err
+	}
+	if servingPower != 0 && servingSize <= 0 {
+		return 0, fmt.Errorf("a servingSize (g) is needed to convert %s to %s macros", quantityUnit, macroUnit)
+	}
+	return quantity * factor * math.Pow(servingSize, float64(servingPower)), nil
+}
+
+// validateUnits checks that an ingredient's quantity converts to its macro unit, defaulting the
+// quantity unit to the macro unit's own
+func (i *Ingredient) validateUnits() error {
+	if i.ServingSize != nil && *i.ServingSize <= 0 {
+		return errors.New("servingSize must be greater than 0")
+	}
+	if i.QuantityUnit == "" && validMacroUnit(i.MacroUnit) {
+		i.QuantityUnit = defaultQuantityUnit(i.MacroUnit)
+	}
+	_, err := quantityMultiplier(i.Quantity, i.QuantityUnit, i.MacroUnit, servingSizeOrZero(i.ServingSize))
+	return err
+}
+
+// validateUnits checks an ingredient template's macro unit and serving size
+func (t *IngredientTemplate) validateUnits() error {
+	if t.ServingSize != nil && *t.ServingSize <= 0 {
+		return errors.New("servingSize must be greater than 0")
+	}
+	_, _, err := unitConversion(defaultQuantityUnit(t.MacroUnit), t.MacroUnit)
+	return err
+}
+
+// baseQuantity returns the ingredient's quantity converted to its macro unit's own unit, as
+// ingredient templates in meal templates hold it
+func (i Ingredient) baseQuantity() float64 {
+	multiplier, _ := quantityMultiplier(i.Quantity, i.QuantityUnit, i.MacroUnit, servingSizeOrZero(i.ServingSize))
+	return multiplier * macroUnits[i.MacroUnit].amount
+}
+
+func servingSizeOrZero(servingSize *float64) float64 {
+	if servingSize == nil {
+		return 0
+	}
+	return *servingSize
+}
+
+// quantityMultiplierSQL is quantityMultiplier as an SQL expression over the quantity,
+// quantity_unit, macro_unit and serving_size columns of the ingredients table (aliased i)
+var quantityMultiplierSQL = func() string {
+	var b strings.Builder
+	b.WriteString("i.quantity * CASE")
+	for _, macro := range unitNames(macroUnits) {
+		for _, quantity := range unitNames(quantityUnits) {
+			factor, servingPower, err := unitConversion(quantity, macro)
+			if err != nil {
+				continue
+			}
+			// Always a decimal literal, as SQLite divides integers (such as a serving size of 50) as integers
+			literal := strconv.FormatFloat(factor, 'f', -1, 64)
+			if !strings.Contains(literal, ".") {
+				literal += ".0"
+			}
+			fmt.Fprintf(&b, " WHEN i.macro_unit = '%s' AND i.quantity_unit = '%s' THEN %s", macro, quantity, literal)
+			switch servingPower {
+			case 1:
+				b.WriteString(" * i.serving_size")
+			case -1:
+				b.WriteString(" / i.serving_size")
+			}
+		}
+	}
+	b.WriteString(" END")
+	return b.String()
+}()